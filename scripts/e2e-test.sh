@@ -422,6 +422,10 @@ function emitLogs {
         # ignore failures in the dump script
         :
     fi
+    if ! e2e_spdk_dump_dir="$logPath" runGoTest "tools/spdkDump" ; then
+        # ignore failures to dump the SPDK state
+        :
+    fi
     unset logPath
 }
 
@@ -124,6 +124,7 @@ msv_rebuild
 multiple_vols_pod_io
 node_shutdown
 nexus_location
+nvme_ana
 pool_modify
 primitive_data_integrity
 primitive_fault_injection
@@ -135,9 +136,12 @@ pvc_delete
 pvc_readwriteonce
 pvc_stress_fio
 pvc_waitforfirstconsumer
+rebuild_interrupt
+replica_snapshot
 single_msn_shutdown
 stale_msp_after_node_power_failure
 synchronous_replication
+thin_provisioning
 volume_filesystem
 MQ-2307-etcd_inaccessibility
 "
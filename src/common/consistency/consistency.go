@@ -0,0 +1,516 @@
+package consistency
+
+// Cross-layer consistency checking of mayastor resources.
+// Pools, volumes, replicas, nexuses and nexus children are gathered from
+// the custom resources, the control plane and the mayastor data plane (gRPC),
+// cross-referenced by name and uuid, and every disagreement is reported.
+// The layers which are gathered are selected by Sources so that the check can
+// be used by clients which do not have access to all layers.
+// A node on which a listing fails is skipped, findings which could be caused by
+// the resources of a skipped node not being listed are suppressed.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/mayastorclient"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+)
+
+type FindingKind string
+
+const (
+	MissingPool         FindingKind = "MissingPool"
+	PhantomPool         FindingKind = "PhantomPool"
+	PoolStateMismatch   FindingKind = "PoolStateMismatch"
+	PoolSizeMismatch    FindingKind = "PoolSizeMismatch"
+	MissingReplica      FindingKind = "MissingReplica"
+	OrphanReplica       FindingKind = "OrphanReplica"
+	ReplicaSizeMismatch FindingKind = "ReplicaSizeMismatch"
+	MissingNexus        FindingKind = "MissingNexus"
+	PhantomNexus        FindingKind = "PhantomNexus"
+	NexusSizeMismatch   FindingKind = "NexusSizeMismatch"
+	NexusStateMismatch  FindingKind = "NexusStateMismatch"
+	ChildMismatch       FindingKind = "ChildMismatch"
+	ChildStateMismatch  FindingKind = "ChildStateMismatch"
+	MissingVolume       FindingKind = "MissingVolume"
+	PhantomVolume       FindingKind = "PhantomVolume"
+	VolumeStateMismatch FindingKind = "VolumeStateMismatch"
+)
+
+// Finding a single inconsistency
+type Finding struct {
+	Kind FindingKind `json:"kind"`
+	// Id name or uuid of the resource
+	Id string `json:"id"`
+	// Address of the mayastor node, if the resource was found on a node
+	Address string `json:"address,omitempty"`
+	Detail  string `json:"detail"`
+}
+
+func (f Finding) String() string {
+	if f.Address != "" {
+		return fmt.Sprintf("%s %s on %s: %s", f.Kind, f.Id, f.Address, f.Detail)
+	}
+	return fmt.Sprintf("%s %s: %s", f.Kind, f.Id, f.Detail)
+}
+
+// Report the result of a consistency check
+type Report struct {
+	Findings []Finding `json:"findings"`
+	// Errors gathering the state, a layer which could not be listed is not checked
+	// and the findings for resources on a node which could not be listed are suppressed
+	Errors []string `json:"errors,omitempty"`
+}
+
+func (r *Report) add(kind FindingKind, id string, address string, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{Kind: kind, Id: id, Address: address, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "%d inconsistencies", len(r.Findings))
+	for _, f := range r.Findings {
+		_, _ = fmt.Fprintf(&sb, "\n  %v", f)
+	}
+	for _, e := range r.Errors {
+		_, _ = fmt.Fprintf(&sb, "\n  error: %s", e)
+	}
+	return sb.String()
+}
+
+// Err returns an error describing the findings and the errors gathering the state,
+// or nil if there are none
+func (r *Report) Err() error {
+	if len(r.Findings) == 0 && len(r.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("cluster consistency check: %s", r.String())
+}
+
+// Pool pool as described by the custom resources or the control plane
+type Pool struct {
+	Name     string
+	Node     string
+	State    string
+	Capacity uint64
+}
+
+// CrdPools converts a list of pool custom resources to Pools, the custom resources are
+// converted using their JSON representation so that any version of the custom resource
+// types can be converted.
+func CrdPools(crdPools interface{}) ([]Pool, error) {
+	data, err := json.Marshal(crdPools)
+	if err != nil {
+		return nil, err
+	}
+	var crs []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			Node string `json:"node"`
+		} `json:"spec"`
+		Status struct {
+			State    string `json:"state"`
+			Capacity uint64 `json:"capacity"`
+		} `json:"status"`
+	}
+	if err = json.Unmarshal(data, &crs); err != nil {
+		return nil, err
+	}
+	var pools []Pool
+	for _, cr := range crs {
+		pools = append(pools, Pool{
+			Name:     cr.Metadata.Name,
+			Node:     cr.Spec.Node,
+			State:    cr.Status.State,
+			Capacity: cr.Status.Capacity,
+		})
+	}
+	return pools, nil
+}
+
+// Sources functions to list resources from the custom resources and the control plane,
+// a nil function skips the checks against that source.
+// NodeAddresses returns the ip addresses of the mayastor nodes keyed by node name, it is used
+// to find the resources described by node name which are on skipped nodes, if it is nil
+// all such resources are treated as being on a skipped node when a node is skipped.
+type Sources struct {
+	CrdPools      func() ([]Pool, error)
+	CrdVolumes    func() ([]common.MayastorVolume, error)
+	CpPools       func() ([]Pool, error)
+	CpVolumes     func() ([]common.MayastorVolume, error)
+	NodeAddresses func() (map[string]string, error)
+}
+
+// NodeState resources on a mayastor node listed using gRPC
+type NodeState struct {
+	Address  string
+	Pools    []mayastorclient.MayastorPool
+	Replicas []mayastorclient.MayastorReplica
+	Nexuses  []mayastorclient.MayastorNexus
+}
+
+// ClusterState resources gathered from all layers,
+// a nil slice indicates the layer was not gathered
+type ClusterState struct {
+	CrdPools   []Pool
+	CrdVolumes []common.MayastorVolume
+	CpPools    []Pool
+	CpVolumes  []common.MayastorVolume
+	Nodes      []NodeState
+	// Skipped the ip addresses of the nodes on which a listing failed
+	Skipped []string
+	// NodeAddresses the ip addresses of the nodes keyed by node name, nil if not known
+	NodeAddresses map[string]string
+	Errors        []string
+}
+
+// Gather lists resources from the sources and from the mayastor nodes with ip addresses addrs
+func Gather(addrs []string, src Sources) *ClusterState {
+	state := ClusterState{}
+	var err error
+	if src.CrdPools != nil {
+		if state.CrdPools, err = src.CrdPools(); err != nil {
+			state.Errors = append(state.Errors, fmt.Sprintf("list custom resource pools: %v", err))
+			state.CrdPools = nil
+		} else if state.CrdPools == nil {
+			state.CrdPools = []Pool{}
+		}
+	}
+	if src.CrdVolumes != nil {
+		if state.CrdVolumes, err = src.CrdVolumes(); err != nil {
+			state.Errors = append(state.Errors, fmt.Sprintf("list custom resource volumes: %v", err))
+			state.CrdVolumes = nil
+		} else if state.CrdVolumes == nil {
+			state.CrdVolumes = []common.MayastorVolume{}
+		}
+	}
+	if src.CpPools != nil {
+		if state.CpPools, err = src.CpPools(); err != nil {
+			state.Errors = append(state.Errors, fmt.Sprintf("list control plane pools: %v", err))
+			state.CpPools = nil
+		} else if state.CpPools == nil {
+			state.CpPools = []Pool{}
+		}
+	}
+	if src.CpVolumes != nil {
+		if state.CpVolumes, err = src.CpVolumes(); err != nil {
+			state.Errors = append(state.Errors, fmt.Sprintf("list control plane volumes: %v", err))
+			state.CpVolumes = nil
+		} else if state.CpVolumes == nil {
+			state.CpVolumes = []common.MayastorVolume{}
+		}
+	}
+	if src.NodeAddresses != nil {
+		if state.NodeAddresses, err = src.NodeAddresses(); err != nil {
+			state.Errors = append(state.Errors, fmt.Sprintf("list node addresses: %v", err))
+			state.NodeAddresses = nil
+		}
+	}
+	for _, address := range addrs {
+		node := NodeState{Address: address}
+		var errs []string
+		if node.Pools, err = mayastorclient.ListPools([]string{address}); err != nil {
+			errs = append(errs, fmt.Sprintf("list pools on %s: %v", address, err))
+		}
+		if node.Replicas, err = mayastorclient.ListReplicas([]string{address}); err != nil {
+			errs = append(errs, fmt.Sprintf("list replicas on %s: %v", address, err))
+		}
+		if node.Nexuses, err = mayastorclient.ListNexuses([]string{address}); err != nil {
+			errs = append(errs, fmt.Sprintf("list nexuses on %s: %v", address, err))
+		}
+		if len(errs) != 0 {
+			// an incomplete node would result in spurious findings
+			state.Errors = append(state.Errors, errs...)
+			state.Skipped = append(state.Skipped, address)
+			continue
+		}
+		state.Nodes = append(state.Nodes, node)
+	}
+	return &state
+}
+
+// ClusterReport gathers the cluster state and checks its consistency
+func ClusterReport(addrs []string, src Sources) *Report {
+	return Gather(addrs, src).Check()
+}
+
+// ResourceKey returns the replica name or uuid from a replica or nexus child uri,
+// e.g. nvmf://10.1.0.2:8420/nqn.2019-05.io.openebs:<name>?uuid=<uuid> or bdev:///<name>?uuid=<uuid>
+func ResourceKey(uri string) string {
+	key := uri
+	if ix := strings.Index(key, "?"); ix >= 0 {
+		key = key[:ix]
+	}
+	if ix := strings.LastIndex(key, "/"); ix >= 0 {
+		key = key[ix+1:]
+	}
+	if ix := strings.LastIndex(key, ":"); ix >= 0 {
+		key = key[ix+1:]
+	}
+	return key
+}
+
+func grpcPoolState(state mayastorGrpc.PoolState) string {
+	switch state {
+	case mayastorGrpc.PoolState_POOL_ONLINE:
+		return "Online"
+	case mayastorGrpc.PoolState_POOL_DEGRADED:
+		return "Degraded"
+	case mayastorGrpc.PoolState_POOL_FAULTED:
+		return "Faulted"
+	default:
+		return "Unknown"
+	}
+}
+
+func grpcNexusState(state mayastorGrpc.NexusState) string {
+	switch state {
+	case mayastorGrpc.NexusState_NEXUS_ONLINE:
+		return "Online"
+	case mayastorGrpc.NexusState_NEXUS_DEGRADED:
+		return "Degraded"
+	case mayastorGrpc.NexusState_NEXUS_FAULTED:
+		return "Faulted"
+	default:
+		return "Unknown"
+	}
+}
+
+func grpcChildState(state mayastorGrpc.ChildState) string {
+	switch state {
+	case mayastorGrpc.ChildState_CHILD_ONLINE:
+		return "Online"
+	case mayastorGrpc.ChildState_CHILD_DEGRADED:
+		return "Degraded"
+	case mayastorGrpc.ChildState_CHILD_FAULTED:
+		return "Faulted"
+	default:
+		return "Unknown"
+	}
+}
+
+type grpcReplica struct {
+	address string
+	replica mayastorclient.MayastorReplica
+	used    bool
+}
+
+type grpcNexus struct {
+	address string
+	nexus   mayastorclient.MayastorNexus
+	used    bool
+}
+
+// Check cross-references the resources of all layers which were gathered
+func (state *ClusterState) Check() *Report {
+	report := Report{Errors: state.Errors}
+
+	grpcPools := make(map[string]mayastorclient.MayastorPool)
+	grpcPoolAddrs := make(map[string]string)
+	var replicas []*grpcReplica
+	var nexuses []*grpcNexus
+	for _, node := range state.Nodes {
+		for _, pool := range node.Pools {
+			grpcPools[pool.Name] = pool
+			grpcPoolAddrs[pool.Name] = node.Address
+		}
+		for _, replica := range node.Replicas {
+			replicas = append(replicas, &grpcReplica{address: node.Address, replica: replica})
+		}
+		for _, nexus := range node.Nexuses {
+			nexuses = append(nexuses, &grpcNexus{address: node.Address, nexus: nexus})
+		}
+	}
+
+	// resources on skipped nodes were not listed, so are not reported as missing
+	skippedAddrs := make(map[string]bool)
+	for _, address := range state.Skipped {
+		skippedAddrs[address] = true
+	}
+	skippedNode := func(nodeName string) bool {
+		if len(skippedAddrs) == 0 {
+			return false
+		}
+		address, ok := state.NodeAddresses[nodeName]
+		return !ok || skippedAddrs[address]
+	}
+	// local uris, e.g. bdev:///<name>, refer to the node of the nexus which was listed
+	skippedUri := func(uri string) bool {
+		u, err := url.Parse(uri)
+		return err == nil && skippedAddrs[u.Hostname()]
+	}
+
+	findReplica := func(key string, pool string) *grpcReplica {
+		for _, r := range replicas {
+			if (r.replica.Name == key || r.replica.Uuid == key) && (pool == "" || r.replica.Pool == pool) {
+				return r
+			}
+		}
+		return nil
+	}
+
+	// pools
+	checkPools := func(layer string, pools []Pool) map[string]bool {
+		names := make(map[string]bool)
+		for _, pool := range pools {
+			names[pool.Name] = true
+			grpcPool, ok := grpcPools[pool.Name]
+			if !ok {
+				if skippedNode(pool.Node) {
+					continue
+				}
+				report.add(MissingPool, pool.Name, "", "%s pool on node %s not found using gRPC", layer, pool.Node)
+				continue
+			}
+			address := grpcPoolAddrs[pool.Name]
+			if !strings.EqualFold(pool.State, grpcPoolState(grpcPool.State)) {
+				report.add(PoolStateMismatch, pool.Name, address, "%s state %s, gRPC state %v", layer, pool.State, grpcPool.State)
+			}
+			if pool.Capacity != grpcPool.Capacity {
+				report.add(PoolSizeMismatch, pool.Name, address, "%s capacity %d, gRPC capacity %d", layer, pool.Capacity, grpcPool.Capacity)
+			}
+		}
+		return names
+	}
+	if state.CrdPools != nil {
+		crdNames := checkPools("custom resource", state.CrdPools)
+		for name := range grpcPools {
+			if !crdNames[name] {
+				report.add(PhantomPool, name, grpcPoolAddrs[name], "no custom resource for pool")
+			}
+		}
+	}
+	if state.CpPools != nil {
+		cpNames := checkPools("control plane", state.CpPools)
+		for name := range grpcPools {
+			if !cpNames[name] {
+				report.add(PhantomPool, name, grpcPoolAddrs[name], "pool not known to the control plane")
+			}
+		}
+	}
+
+	// volumes, replicas and nexuses are checked against the control plane
+	// if it was gathered, otherwise against the custom resources
+	checkVolumes := func(layer string, volumes []common.MayastorVolume) {
+		for _, msv := range volumes {
+			for _, cpReplica := range msv.Status.Replicas {
+				key := ResourceKey(cpReplica.Uri)
+				r := findReplica(key, cpReplica.Pool)
+				if r == nil {
+					if skippedNode(cpReplica.Node) || skippedUri(cpReplica.Uri) {
+						continue
+					}
+					report.add(MissingReplica, key, "", "replica of volume %s in pool %s not found using gRPC", msv.Name, cpReplica.Pool)
+					continue
+				}
+				r.used = true
+				if r.replica.Size != uint64(msv.Status.Size) {
+					report.add(ReplicaSizeMismatch, key, r.address, "replica size %d, volume %s size %d", r.replica.Size, msv.Name, msv.Status.Size)
+				}
+			}
+			cpNexus := msv.Status.Nexus
+			var n *grpcNexus
+			for _, candidate := range nexuses {
+				if (cpNexus.Uuid != "" && candidate.nexus.Uuid == cpNexus.Uuid) || candidate.nexus.Uuid == msv.Name || candidate.nexus.Name == msv.Name {
+					n = candidate
+					break
+				}
+			}
+			if n == nil {
+				if cpNexus.Node != "" && !skippedNode(cpNexus.Node) {
+					report.add(MissingNexus, cpNexus.Uuid, "", "nexus of volume %s on node %s not found using gRPC", msv.Name, cpNexus.Node)
+				}
+				continue
+			}
+			n.used = true
+			if cpNexus.Node == "" {
+				report.add(PhantomNexus, n.nexus.Uuid, n.address, "volume %s is not published", msv.Name)
+				continue
+			}
+			if n.nexus.Size != uint64(msv.Status.Size) {
+				report.add(NexusSizeMismatch, n.nexus.Uuid, n.address, "nexus size %d, volume %s size %d", n.nexus.Size, msv.Name, msv.Status.Size)
+			}
+			if !strings.EqualFold(cpNexus.State, grpcNexusState(n.nexus.State)) {
+				report.add(NexusStateMismatch, n.nexus.Uuid, n.address, "%s state %s, gRPC state %v", layer, cpNexus.State, n.nexus.State)
+			}
+			grpcChildren := make(map[string]*mayastorGrpc.Child)
+			for _, child := range n.nexus.Children {
+				grpcChildren[ResourceKey(child.Uri)] = child
+			}
+			for _, cpChild := range cpNexus.Children {
+				key := ResourceKey(cpChild.Uri)
+				child, ok := grpcChildren[key]
+				if !ok {
+					report.add(ChildMismatch, n.nexus.Uuid, n.address, "%s child %s not found using gRPC", layer, cpChild.Uri)
+					continue
+				}
+				delete(grpcChildren, key)
+				if !strings.EqualFold(cpChild.State, grpcChildState(child.State)) {
+					report.add(ChildStateMismatch, n.nexus.Uuid, n.address, "child %s %s state %s, gRPC state %v", cpChild.Uri, layer, cpChild.State, child.State)
+				}
+			}
+			for _, child := range grpcChildren {
+				report.add(ChildMismatch, n.nexus.Uuid, n.address, "gRPC child %s not known to the %s", child.Uri, layer)
+			}
+		}
+		for _, r := range replicas {
+			if !r.used && !r.replica.IsSnapshot() {
+				report.add(OrphanReplica, r.replica.Name, r.address, "replica in pool %s does not belong to a volume", r.replica.Pool)
+			}
+		}
+		for _, n := range nexuses {
+			if !n.used {
+				report.add(PhantomNexus, n.nexus.Uuid, n.address, "nexus does not belong to a volume")
+			}
+		}
+	}
+	if state.CrdVolumes != nil && state.CpVolumes != nil {
+		crdVolumes := make(map[string]common.MayastorVolume)
+		for _, msv := range state.CrdVolumes {
+			crdVolumes[msv.Name] = msv
+		}
+		for _, cpVolume := range state.CpVolumes {
+			msv, ok := crdVolumes[cpVolume.Name]
+			if !ok {
+				report.add(MissingVolume, cpVolume.Name, "", "control plane volume has no custom resource")
+				continue
+			}
+			delete(crdVolumes, cpVolume.Name)
+			if !strings.EqualFold(msv.Status.State, cpVolume.Status.State) {
+				report.add(VolumeStateMismatch, msv.Name, "", "custom resource state %s, control plane state %s", msv.Status.State, cpVolume.Status.State)
+			}
+			if msv.Status.Size != cpVolume.Status.Size {
+				report.add(VolumeStateMismatch, msv.Name, "", "custom resource size %d, control plane size %d", msv.Status.Size, cpVolume.Status.Size)
+			}
+		}
+		for name := range crdVolumes {
+			report.add(PhantomVolume, name, "", "volume custom resource not known to the control plane")
+		}
+	}
+	if state.CpVolumes != nil {
+		checkVolumes("control plane", state.CpVolumes)
+	} else if state.CrdVolumes != nil {
+		checkVolumes("custom resource", state.CrdVolumes)
+	}
+
+	// nexus children must refer to existing replicas
+	for _, n := range nexuses {
+		for _, child := range n.nexus.Children {
+			if findReplica(ResourceKey(child.Uri), "") == nil && !skippedUri(child.Uri) {
+				report.add(ChildMismatch, n.nexus.Uuid, n.address, "child %s has no replica", child.Uri)
+			}
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Kind < report.Findings[j].Kind
+	})
+	return &report
+}
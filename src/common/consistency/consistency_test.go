@@ -0,0 +1,239 @@
+package consistency
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/mayastorclient"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+)
+
+const (
+	volUuid   = "0b2f8b2e-7c1a-4b5e-9d3c-1f2e3d4c5b6a"
+	replUuid1 = "5a1c4f6e-2d3b-4c5d-8e9f-0a1b2c3d4e5f"
+	replUuid2 = "6b2d5e7f-3e4c-5d6e-9f0a-1b2c3d4e5f6a"
+	volSize   = 1024 * 1024 * 1024
+)
+
+func consistentState() *ClusterState {
+	return &ClusterState{
+		CrdPools: []Pool{
+			{Name: "pool-on-node-1", Node: "node-1", State: "online", Capacity: 10 * volSize},
+			{Name: "pool-on-node-2", Node: "node-2", State: "online", Capacity: 10 * volSize},
+		},
+		CpVolumes: []common.MayastorVolume{
+			{
+				Name: volUuid,
+				Status: common.MayastorVolumeStatus{
+					Size:  volSize,
+					State: "Online",
+					Nexus: common.Nexus{
+						Uuid:  volUuid,
+						Node:  "node-1",
+						State: "Online",
+						Children: []common.NexusChild{
+							{State: "Online", Uri: "bdev:///" + replUuid1 + "?uuid=" + replUuid1},
+							{State: "Online", Uri: "nvmf://10.1.0.3:8420/nqn.2019-05.io.openebs:" + replUuid2 + "?uuid=" + replUuid2},
+						},
+					},
+					Replicas: []common.Replica{
+						{Node: "node-1", Pool: "pool-on-node-1", Uri: "bdev:///" + replUuid1 + "?uuid=" + replUuid1},
+						{Node: "node-2", Pool: "pool-on-node-2", Uri: "nvmf://10.1.0.3:8420/nqn.2019-05.io.openebs:" + replUuid2 + "?uuid=" + replUuid2},
+					},
+				},
+			},
+		},
+		Nodes: []NodeState{
+			{
+				Address: "10.1.0.2",
+				Pools: []mayastorclient.MayastorPool{
+					{Name: "pool-on-node-1", State: mayastorGrpc.PoolState_POOL_ONLINE, Capacity: 10 * volSize},
+				},
+				Replicas: []mayastorclient.MayastorReplica{
+					{Name: replUuid1, Uuid: replUuid1, Pool: "pool-on-node-1", Size: volSize},
+				},
+				Nexuses: []mayastorclient.MayastorNexus{
+					{
+						Uuid:  volUuid,
+						Size:  volSize,
+						State: mayastorGrpc.NexusState_NEXUS_ONLINE,
+						Children: []*mayastorGrpc.Child{
+							{Uri: "bdev:///" + replUuid1 + "?uuid=" + replUuid1, State: mayastorGrpc.ChildState_CHILD_ONLINE},
+							{Uri: "nvmf://10.1.0.3:8420/nqn.2019-05.io.openebs:" + replUuid2 + "?uuid=" + replUuid2, State: mayastorGrpc.ChildState_CHILD_ONLINE},
+						},
+					},
+				},
+			},
+			{
+				Address: "10.1.0.3",
+				Pools: []mayastorclient.MayastorPool{
+					{Name: "pool-on-node-2", State: mayastorGrpc.PoolState_POOL_ONLINE, Capacity: 10 * volSize},
+				},
+				Replicas: []mayastorclient.MayastorReplica{
+					{Name: replUuid2, Uuid: replUuid2, Pool: "pool-on-node-2", Size: volSize},
+					{Name: replUuid2 + "-snap-1634567890", Uuid: replUuid2 + "-snap-1634567890", Pool: "pool-on-node-2", Size: volSize},
+				},
+			},
+		},
+	}
+}
+
+func findingKinds(report *Report) []FindingKind {
+	var kinds []FindingKind
+	for _, f := range report.Findings {
+		kinds = append(kinds, f.Kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+func TestResourceKey(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"bdev:///" + replUuid1 + "?uuid=" + replUuid1:                                     replUuid1,
+		"nvmf://10.1.0.3:8420/nqn.2019-05.io.openebs:" + replUuid2 + "?uuid=" + replUuid2: replUuid2,
+		"loopback:///" + replUuid1:                                                        replUuid1,
+		replUuid1:                                                                         replUuid1,
+	} {
+		if key := ResourceKey(uri); key != expected {
+			t.Errorf("ResourceKey(%s) = %s, expected %s", uri, key, expected)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(state *ClusterState)
+		expected []FindingKind
+	}{
+		{"consistent", func(state *ClusterState) {}, nil},
+		{"layers not gathered", func(state *ClusterState) {
+			state.CrdPools = nil
+			state.CpVolumes = nil
+		}, nil},
+		{"missing pool", func(state *ClusterState) {
+			state.Nodes[1].Pools = nil
+		}, []FindingKind{MissingPool}},
+		{"phantom pool", func(state *ClusterState) {
+			state.CrdPools = state.CrdPools[:1]
+		}, []FindingKind{PhantomPool}},
+		{"pool state and size", func(state *ClusterState) {
+			state.Nodes[0].Pools[0].State = mayastorGrpc.PoolState_POOL_DEGRADED
+			state.Nodes[1].Pools[0].Capacity = 5 * volSize
+		}, []FindingKind{PoolSizeMismatch, PoolStateMismatch}},
+		{"orphan replica", func(state *ClusterState) {
+			state.Nodes[0].Replicas = append(state.Nodes[0].Replicas,
+				mayastorclient.MayastorReplica{Name: "orphan", Uuid: "orphan", Pool: "pool-on-node-1", Size: volSize})
+		}, []FindingKind{OrphanReplica}},
+		{"missing replica", func(state *ClusterState) {
+			state.Nodes[1].Replicas = state.Nodes[1].Replicas[1:]
+		}, []FindingKind{ChildMismatch, MissingReplica}},
+		{"replica size", func(state *ClusterState) {
+			state.Nodes[1].Replicas[0].Size = volSize / 2
+		}, []FindingKind{ReplicaSizeMismatch}},
+		{"missing nexus", func(state *ClusterState) {
+			state.Nodes[0].Nexuses = nil
+		}, []FindingKind{MissingNexus}},
+		{"phantom nexus", func(state *ClusterState) {
+			state.Nodes[1].Nexuses = []mayastorclient.MayastorNexus{{Uuid: "phantom", Size: volSize}}
+		}, []FindingKind{PhantomNexus}},
+		{"unpublished volume with nexus", func(state *ClusterState) {
+			state.CpVolumes[0].Status.Nexus = common.Nexus{}
+		}, []FindingKind{PhantomNexus}},
+		{"nexus size and state", func(state *ClusterState) {
+			state.Nodes[0].Nexuses[0].Size = volSize / 2
+			state.Nodes[0].Nexuses[0].State = mayastorGrpc.NexusState_NEXUS_DEGRADED
+		}, []FindingKind{NexusSizeMismatch, NexusStateMismatch}},
+		{"child state", func(state *ClusterState) {
+			state.Nodes[0].Nexuses[0].Children[1].State = mayastorGrpc.ChildState_CHILD_FAULTED
+		}, []FindingKind{ChildStateMismatch}},
+		{"extra child", func(state *ClusterState) {
+			state.CpVolumes[0].Status.Nexus.Children = state.CpVolumes[0].Status.Nexus.Children[:1]
+		}, []FindingKind{ChildMismatch}},
+		{"custom resource volumes", func(state *ClusterState) {
+			state.CrdVolumes = append([]common.MayastorVolume{}, state.CpVolumes...)
+		}, nil},
+		{"checked against custom resource volumes", func(state *ClusterState) {
+			state.CrdVolumes = state.CpVolumes
+			state.CpVolumes = nil
+			state.Nodes[0].Nexuses[0].Children[1].State = mayastorGrpc.ChildState_CHILD_FAULTED
+		}, []FindingKind{ChildStateMismatch}},
+		{"missing volume", func(state *ClusterState) {
+			state.CrdVolumes = []common.MayastorVolume{}
+		}, []FindingKind{MissingVolume}},
+		{"phantom volume", func(state *ClusterState) {
+			state.CrdVolumes = append([]common.MayastorVolume{{Name: "phantom"}}, state.CpVolumes...)
+		}, []FindingKind{PhantomVolume}},
+		{"node not listed", func(state *ClusterState) {
+			state.Nodes = state.Nodes[:1]
+			state.Skipped = []string{"10.1.0.3"}
+			state.NodeAddresses = map[string]string{"node-1": "10.1.0.2", "node-2": "10.1.0.3"}
+			state.Errors = []string{"list pools on 10.1.0.3: unavailable"}
+		}, nil},
+		{"node not listed, node addresses not known", func(state *ClusterState) {
+			state.Nodes = state.Nodes[:1]
+			state.Skipped = []string{"10.1.0.3"}
+			state.Errors = []string{"list pools on 10.1.0.3: unavailable"}
+		}, nil},
+		{"node listed, other node not listed", func(state *ClusterState) {
+			state.Nodes[0].Pools = nil
+			state.Skipped = []string{"10.1.0.4"}
+			state.NodeAddresses = map[string]string{"node-1": "10.1.0.2", "node-2": "10.1.0.3", "node-3": "10.1.0.4"}
+			state.Errors = []string{"list pools on 10.1.0.4: unavailable"}
+		}, []FindingKind{MissingPool}},
+		{"volume state and size", func(state *ClusterState) {
+			state.CrdVolumes = append([]common.MayastorVolume{}, state.CpVolumes...)
+			state.CrdVolumes[0].Status.State = "Degraded"
+			state.CrdVolumes[0].Status.Size = volSize / 2
+		}, []FindingKind{VolumeStateMismatch, VolumeStateMismatch}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := consistentState()
+			tt.modify(state)
+			report := state.Check()
+			if kinds := findingKinds(report); !reflect.DeepEqual(kinds, tt.expected) {
+				t.Errorf("findings %v, expected %v\n%s", kinds, tt.expected, report)
+			}
+			if (report.Err() == nil) != (len(tt.expected) == 0 && len(state.Errors) == 0) {
+				t.Errorf("unexpected error %v", report.Err())
+			}
+		})
+	}
+}
+
+func TestCrdPools(t *testing.T) {
+	type crdPool struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Node  string   `json:"node"`
+			Disks []string `json:"disks"`
+		} `json:"spec"`
+		Status struct {
+			State    string `json:"state"`
+			Capacity int64  `json:"capacity"`
+			Used     int64  `json:"used"`
+		} `json:"status"`
+	}
+	var cr crdPool
+	cr.Metadata.Name = "pool-on-node-1"
+	cr.Metadata.Namespace = "mayastor"
+	cr.Spec.Node = "node-1"
+	cr.Spec.Disks = []string{"/dev/sdb"}
+	cr.Status.State = "online"
+	cr.Status.Capacity = 10 * volSize
+	cr.Status.Used = volSize
+	pools, err := CrdPools([]crdPool{cr})
+	if err != nil {
+		t.Fatalf("CrdPools: %v", err)
+	}
+	expected := []Pool{{Name: "pool-on-node-1", Node: "node-1", State: "online", Capacity: 10 * volSize}}
+	if !reflect.DeepEqual(pools, expected) {
+		t.Errorf("pools %v, expected %v", pools, expected)
+	}
+}
@@ -61,6 +61,11 @@ type ControlPlaneInterface interface {
 	IsMsvDeleted(uuid string) bool
 	CheckForMsvs() (bool, error)
 	CheckAllMsvsAreHealthy() error
+	CreateMsv(uuid string, sizeBytes int64, replicaCount int) (*common.MayastorVolume, error)
+	PublishMsv(uuid string, node string, protocol common.ShareProto) (string, error)
+	UnpublishMsv(uuid string) error
+	ShareMsv(uuid string, protocol common.ShareProto) (string, error)
+	UnshareMsv(uuid string) error
 
 	// Mayastor Node abstraction
 
@@ -93,7 +98,15 @@ func getControlPlane() ControlPlaneInterface {
 		}
 		switch major {
 		case 1:
-			ifc = v1.MakeCP(&nodeIpAddresses)
+			access := e2e_config.GetConfig().ControlPlaneAccess
+			switch access {
+			case "", "plugin":
+				ifc = v1.MakeCP(&nodeIpAddresses)
+			case "rest":
+				ifc = v1.MakeRestCP(&nodeIpAddresses)
+			default:
+				panic(fmt.Errorf("unsupported control plane access %v", access))
+			}
 		default:
 			panic(fmt.Errorf("unsupported control plane version %v", version))
 		}
@@ -104,6 +117,13 @@ func getControlPlane() ControlPlaneInterface {
 	return ifc
 }
 
+// SetControlPlaneInterface replaces the control plane interface implementation,
+// for use by unit tests with a fake control plane.
+func SetControlPlaneInterface(cp ControlPlaneInterface) {
+	once.Do(func() {})
+	ifc = cp
+}
+
 func VolStateHealthy() string {
 	return getControlPlane().VolStateHealthy()
 }
@@ -216,6 +236,33 @@ func CheckAllMsvsAreHealthy() error {
 	return getControlPlane().CheckAllMsvsAreHealthy()
 }
 
+// CreateMsv create a mayastor volume directly using the control plane,
+// i.e. without a PVC, the volume is not published.
+func CreateMsv(uuid string, sizeBytes int64, replicaCount int) (*common.MayastorVolume, error) {
+	return getControlPlane().CreateMsv(uuid, sizeBytes, replicaCount)
+}
+
+// PublishMsv publish a mayastor volume on a node using protocol,
+// if node is empty the control plane selects the node.
+// Returns the device uri of the nexus.
+func PublishMsv(uuid string, node string, protocol common.ShareProto) (string, error) {
+	return getControlPlane().PublishMsv(uuid, node, protocol)
+}
+
+func UnpublishMsv(uuid string) error {
+	return getControlPlane().UnpublishMsv(uuid)
+}
+
+// ShareMsv share the nexus of a published mayastor volume using protocol,
+// returns the share uri.
+func ShareMsv(uuid string, protocol common.ShareProto) (string, error) {
+	return getControlPlane().ShareMsv(uuid, protocol)
+}
+
+func UnshareMsv(uuid string) error {
+	return getControlPlane().UnshareMsv(uuid)
+}
+
 //FIXME: MSN These functions are only guaranteed to
 // work correctly if invoked from k8stest/msn.go
 // which ensures that necessary setup functions
@@ -0,0 +1,632 @@
+package fake
+
+// In-memory implementation of the control plane interface for testing
+// helper functions without a cluster.
+// Volumes, nodes and pools are added and modified by the test,
+// state transitions can be queued against volumes and errors and latency
+// can be injected for any method.
+
+import (
+	"errors"
+	"fmt"
+	"mayastor-e2e/common"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is recognised by IsTimeoutError, inject it to simulate a control plane timeout
+var ErrTimeout = errors.New("fake control plane: request timed out")
+
+// MsvTransition modifies a volume, transitions are applied in order,
+// one for each time the volume is observed through the control plane interface
+type MsvTransition func(msv *common.MayastorVolume)
+
+type ControlPlane struct {
+	// Major version returned by MajorVersion
+	Major int
+
+	mu          sync.Mutex
+	volumes     map[string]*common.MayastorVolume
+	nodes       map[string]*common.MayastorNode
+	pools       map[string]*common.MayastorPool
+	transitions map[string][]MsvTransition
+	errs        map[string][]error
+	latency     time.Duration
+	calls       map[string]int
+}
+
+func NewControlPlane() *ControlPlane {
+	return &ControlPlane{
+		Major:       1,
+		volumes:     make(map[string]*common.MayastorVolume),
+		nodes:       make(map[string]*common.MayastorNode),
+		pools:       make(map[string]*common.MayastorPool),
+		transitions: make(map[string][]MsvTransition),
+		errs:        make(map[string][]error),
+		calls:       make(map[string]int),
+	}
+}
+
+// == Scripting ======================
+
+// InjectError queues errors to be returned by the next calls to the method,
+// method is the name of the interface method for example "GetMSV"
+func (cp *ControlPlane) InjectError(method string, errs ...error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.errs[method] = append(cp.errs[method], errs...)
+}
+
+// SetLatency sets the delay applied to every call
+func (cp *ControlPlane) SetLatency(latency time.Duration) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.latency = latency
+}
+
+// Calls returns the number of times method has been called
+func (cp *ControlPlane) Calls(method string) int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.calls[method]
+}
+
+func (cp *ControlPlane) AddMsv(msv common.MayastorVolume) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.volumes[msv.Name] = copyMsv(&msv)
+}
+
+// UpdateMsv applies update to the volume
+func (cp *ControlPlane) UpdateMsv(uuid string, update MsvTransition) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	msv, ok := cp.volumes[uuid]
+	if !ok {
+		return fmt.Errorf("volume %s not found", uuid)
+	}
+	update(msv)
+	return nil
+}
+
+func (cp *ControlPlane) SetMsvState(uuid string, state string) error {
+	return cp.UpdateMsv(uuid, func(msv *common.MayastorVolume) {
+		msv.Status.State = state
+	})
+}
+
+func (cp *ControlPlane) SetMsvNexusState(uuid string, state string) error {
+	return cp.UpdateMsv(uuid, func(msv *common.MayastorVolume) {
+		msv.Status.Nexus.State = state
+	})
+}
+
+func (cp *ControlPlane) SetMsvNexusChildren(uuid string, children []common.NexusChild) error {
+	return cp.UpdateMsv(uuid, func(msv *common.MayastorVolume) {
+		msv.Status.Nexus.Children = append([]common.NexusChild{}, children...)
+	})
+}
+
+func (cp *ControlPlane) SetMsvReplicas(uuid string, replicas []common.Replica) error {
+	return cp.UpdateMsv(uuid, func(msv *common.MayastorVolume) {
+		msv.Status.Replicas = append([]common.Replica{}, replicas...)
+	})
+}
+
+// QueueMsvTransitions queues state transitions for a volume,
+// a transition is applied each time the volume is observed.
+func (cp *ControlPlane) QueueMsvTransitions(uuid string, transitions ...MsvTransition) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.transitions[uuid] = append(cp.transitions[uuid], transitions...)
+}
+
+// MsvStateTransition returns a transition which sets the volume, nexus and child states,
+// child states are only set if children is not nil.
+func MsvStateTransition(volState string, nexusState string, childStates []string) MsvTransition {
+	return func(msv *common.MayastorVolume) {
+		msv.Status.State = volState
+		msv.Status.Nexus.State = nexusState
+		for ix, state := range childStates {
+			if ix < len(msv.Status.Nexus.Children) {
+				msv.Status.Nexus.Children[ix].State = state
+			}
+		}
+	}
+}
+
+func (cp *ControlPlane) AddMsn(msn common.MayastorNode) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	node := msn
+	cp.nodes[msn.Name] = &node
+}
+
+func (cp *ControlPlane) SetMsNodeStatus(nodeName string, status string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	msn, ok := cp.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("node %s not found", nodeName)
+	}
+	msn.State.Status = status
+	return nil
+}
+
+func (cp *ControlPlane) AddMsPool(msp common.MayastorPool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	pool := msp
+	pool.Spec.Disks = append([]string{}, msp.Spec.Disks...)
+	pool.Status.Disks = append([]string{}, msp.Status.Disks...)
+	cp.pools[msp.Name] = &pool
+}
+
+func (cp *ControlPlane) SetMsPoolState(poolName string, state string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	msp, ok := cp.pools[poolName]
+	if !ok {
+		return fmt.Errorf("pool %s not found", poolName)
+	}
+	msp.Status.State = state
+	return nil
+}
+
+// == Internals ======================
+
+// enter records the call, applies latency and returns the next injected error if any.
+// On return the lock is held, the caller must unlock.
+func (cp *ControlPlane) enter(method string) error {
+	cp.mu.Lock()
+	latency := cp.latency
+	cp.calls[method]++
+	var err error
+	if errs := cp.errs[method]; len(errs) != 0 {
+		err = errs[0]
+		cp.errs[method] = errs[1:]
+	}
+	cp.mu.Unlock()
+	if latency != 0 {
+		time.Sleep(latency)
+	}
+	cp.mu.Lock()
+	return err
+}
+
+// observeMsv applies the next queued transition and returns a copy of the volume.
+// Must be called with the lock held.
+func (cp *ControlPlane) observeMsv(uuid string) (*common.MayastorVolume, bool) {
+	msv, ok := cp.volumes[uuid]
+	if !ok {
+		return nil, false
+	}
+	if transitions := cp.transitions[uuid]; len(transitions) != 0 {
+		transitions[0](msv)
+		cp.transitions[uuid] = transitions[1:]
+	}
+	return copyMsv(msv), true
+}
+
+func copyMsv(msv *common.MayastorVolume) *common.MayastorVolume {
+	cpy := *msv
+	cpy.Status.Nexus.Children = append([]common.NexusChild{}, msv.Status.Nexus.Children...)
+	cpy.Status.Replicas = append([]common.Replica{}, msv.Status.Replicas...)
+	return &cpy
+}
+
+func notFound(kind string, name string) error {
+	return fmt.Errorf("%s %s: NotFound", kind, name)
+}
+
+// == Version ======================
+
+func (cp *ControlPlane) MajorVersion() int {
+	return cp.Major
+}
+
+func (cp *ControlPlane) Version() string {
+	return fmt.Sprintf("%d.0.0", cp.Major)
+}
+
+func (cp *ControlPlane) IsTimeoutError(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// == Resource state strings ======================
+
+func (cp *ControlPlane) VolStateHealthy() string    { return "Online" }
+func (cp *ControlPlane) VolStateDegraded() string   { return "Degraded" }
+func (cp *ControlPlane) ChildStateUnknown() string  { return "Unknown" }
+func (cp *ControlPlane) ChildStateOnline() string   { return "Online" }
+func (cp *ControlPlane) ChildStateDegraded() string { return "Degraded" }
+func (cp *ControlPlane) ChildStateFaulted() string  { return "Faulted" }
+func (cp *ControlPlane) NexusStateUnknown() string  { return "Unknown" }
+func (cp *ControlPlane) NexusStateOnline() string   { return "Online" }
+func (cp *ControlPlane) NexusStateDegraded() string { return "Degraded" }
+func (cp *ControlPlane) NexusStateFaulted() string  { return "Faulted" }
+func (cp *ControlPlane) MspStateOnline() string     { return "Online" }
+func (cp *ControlPlane) NodeStateOnline() string    { return "Online" }
+func (cp *ControlPlane) NodeStateOffline() string   { return "Offline" }
+func (cp *ControlPlane) NodeStateUnknown() string   { return "Unknown" }
+func (cp *ControlPlane) NodeStateEmpty() string     { return "" }
+
+func (cp *ControlPlane) MspGrpcStateToCrdState(mspState int) string {
+	switch mspState {
+	case 0:
+		return "Pending"
+	case 1:
+		return "Online"
+	case 2:
+		return "Degraded"
+	case 3:
+		return "Faulted"
+	default:
+		return "Offline"
+	}
+}
+
+// == MSV ======================
+
+func (cp *ControlPlane) GetMSV(uuid string) (*common.MayastorVolume, error) {
+	err := cp.enter("GetMSV")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	msv, ok := cp.observeMsv(uuid)
+	if !ok {
+		return nil, notFound("volume", uuid)
+	}
+	return msv, nil
+}
+
+func (cp *ControlPlane) GetMsvNodes(uuid string) (string, []string) {
+	err := cp.enter("GetMsvNodes")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return "", nil
+	}
+	msv, ok := cp.observeMsv(uuid)
+	if !ok {
+		return "", nil
+	}
+	var replicaNodes []string
+	for _, replica := range msv.Status.Replicas {
+		replicaNodes = append(replicaNodes, replica.Node)
+	}
+	return msv.Status.Nexus.Node, replicaNodes
+}
+
+func (cp *ControlPlane) DeleteMsv(volName string) error {
+	err := cp.enter("DeleteMsv")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if _, ok := cp.volumes[volName]; !ok {
+		return notFound("volume", volName)
+	}
+	delete(cp.volumes, volName)
+	delete(cp.transitions, volName)
+	return nil
+}
+
+func (cp *ControlPlane) ListMsvs() ([]common.MayastorVolume, error) {
+	err := cp.enter("ListMsvs")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var msvs []common.MayastorVolume
+	for uuid := range cp.volumes {
+		msv, _ := cp.observeMsv(uuid)
+		msvs = append(msvs, *msv)
+	}
+	return msvs, nil
+}
+
+func (cp *ControlPlane) SetMsvReplicaCount(uuid string, replicaCount int) error {
+	err := cp.enter("SetMsvReplicaCount")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	msv, ok := cp.volumes[uuid]
+	if !ok {
+		return notFound("volume", uuid)
+	}
+	msv.Spec.ReplicaCount = replicaCount
+	return nil
+}
+
+func (cp *ControlPlane) GetMsvState(uuid string) (string, error) {
+	err := cp.enter("GetMsvState")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	msv, ok := cp.observeMsv(uuid)
+	if !ok {
+		return "", notFound("volume", uuid)
+	}
+	return msv.Status.State, nil
+}
+
+func (cp *ControlPlane) GetMsvReplicas(volName string) ([]common.Replica, error) {
+	err := cp.enter("GetMsvReplicas")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	msv, ok := cp.observeMsv(volName)
+	if !ok {
+		return nil, notFound("volume", volName)
+	}
+	return msv.Status.Replicas, nil
+}
+
+func (cp *ControlPlane) GetMsvNexusChildren(volName string) ([]common.NexusChild, error) {
+	err := cp.enter("GetMsvNexusChildren")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	msv, ok := cp.observeMsv(volName)
+	if !ok {
+		return nil, notFound("volume", volName)
+	}
+	return msv.Status.Nexus.Children, nil
+}
+
+func (cp *ControlPlane) GetMsvNexusState(uuid string) (string, error) {
+	err := cp.enter("GetMsvNexusState")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	msv, ok := cp.observeMsv(uuid)
+	if !ok {
+		return "", notFound("volume", uuid)
+	}
+	return msv.Status.Nexus.State, nil
+}
+
+func (cp *ControlPlane) IsMsvPublished(uuid string) bool {
+	err := cp.enter("IsMsvPublished")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return false
+	}
+	msv, ok := cp.observeMsv(uuid)
+	return ok && msv.Status.Nexus.Node != ""
+}
+
+func (cp *ControlPlane) IsMsvDeleted(uuid string) bool {
+	err := cp.enter("IsMsvDeleted")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return false
+	}
+	_, ok := cp.volumes[uuid]
+	return !ok
+}
+
+func (cp *ControlPlane) CheckForMsvs() (bool, error) {
+	err := cp.enter("CheckForMsvs")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	return len(cp.volumes) != 0, nil
+}
+
+func (cp *ControlPlane) CheckAllMsvsAreHealthy() error {
+	err := cp.enter("CheckAllMsvsAreHealthy")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for uuid := range cp.volumes {
+		msv, _ := cp.observeMsv(uuid)
+		if msv.Status.State != cp.VolStateHealthy() {
+			return fmt.Errorf("CheckAllMsvsAreHealthy: all MSVs were not healthy")
+		}
+	}
+	return nil
+}
+
+// CreateMsv creates a volume with one replica on each of the first replicaCount pools
+// ordered by name.
+func (cp *ControlPlane) CreateMsv(uuid string, sizeBytes int64, replicaCount int) (*common.MayastorVolume, error) {
+	err := cp.enter("CreateMsv")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cp.volumes[uuid]; ok {
+		return nil, fmt.Errorf("volume %s: AlreadyExists", uuid)
+	}
+	var poolNames []string
+	for name := range cp.pools {
+		poolNames = append(poolNames, name)
+	}
+	if replicaCount < 1 || replicaCount > len(poolNames) {
+		return nil, fmt.Errorf("volume %s: ResourceExhausted, replicas=%d pools=%d", uuid, replicaCount, len(poolNames))
+	}
+	sort.Strings(poolNames)
+	msv := common.MayastorVolume{
+		Name: uuid,
+		Spec: common.MayastorVolumeSpec{
+			ReplicaCount:  replicaCount,
+			RequiredBytes: int(sizeBytes),
+		},
+		Status: common.MayastorVolumeStatus{
+			Size:  sizeBytes,
+			State: cp.VolStateHealthy(),
+		},
+	}
+	for _, name := range poolNames[:replicaCount] {
+		pool := cp.pools[name]
+		msv.Status.Replicas = append(msv.Status.Replicas, common.Replica{
+			Node: pool.Spec.Node,
+			Pool: name,
+			Uri:  fmt.Sprintf("bdev:///%s?uuid=%s", uuid, name),
+		})
+	}
+	cp.volumes[uuid] = copyMsv(&msv)
+	return &msv, nil
+}
+
+// PublishMsv creates the nexus, on the first replica node if node is empty.
+func (cp *ControlPlane) PublishMsv(uuid string, node string, protocol common.ShareProto) (string, error) {
+	err := cp.enter("PublishMsv")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	msv, ok := cp.volumes[uuid]
+	if !ok {
+		return "", notFound("volume", uuid)
+	}
+	if msv.Status.Nexus.Node != "" {
+		return "", fmt.Errorf("volume %s: AlreadyPublished on %s", uuid, msv.Status.Nexus.Node)
+	}
+	if node == "" && len(msv.Status.Replicas) != 0 {
+		node = msv.Status.Replicas[0].Node
+	}
+	msv.Spec.Protocol = string(protocol)
+	msv.Status.Nexus = common.Nexus{
+		DeviceUri: fmt.Sprintf("%s://%s:8420/nqn.2019-05.io.openebs:%s", protocol, node, uuid),
+		Node:      node,
+		State:     cp.NexusStateOnline(),
+		Uuid:      uuid,
+	}
+	for _, replica := range msv.Status.Replicas {
+		msv.Status.Nexus.Children = append(msv.Status.Nexus.Children, common.NexusChild{
+			State: cp.ChildStateOnline(),
+			Uri:   replica.Uri,
+		})
+	}
+	return msv.Status.Nexus.DeviceUri, nil
+}
+
+func (cp *ControlPlane) UnpublishMsv(uuid string) error {
+	err := cp.enter("UnpublishMsv")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	msv, ok := cp.volumes[uuid]
+	if !ok {
+		return notFound("volume", uuid)
+	}
+	msv.Spec.Protocol = ""
+	msv.Status.Nexus = common.Nexus{}
+	return nil
+}
+
+func (cp *ControlPlane) ShareMsv(uuid string, protocol common.ShareProto) (string, error) {
+	err := cp.enter("ShareMsv")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	msv, ok := cp.volumes[uuid]
+	if !ok {
+		return "", notFound("volume", uuid)
+	}
+	if msv.Status.Nexus.Node == "" {
+		return "", fmt.Errorf("volume %s: NotPublished", uuid)
+	}
+	msv.Spec.Protocol = string(protocol)
+	msv.Status.Nexus.DeviceUri = fmt.Sprintf("%s://%s:8420/nqn.2019-05.io.openebs:%s", protocol, msv.Status.Nexus.Node, uuid)
+	return msv.Status.Nexus.DeviceUri, nil
+}
+
+func (cp *ControlPlane) UnshareMsv(uuid string) error {
+	err := cp.enter("UnshareMsv")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	msv, ok := cp.volumes[uuid]
+	if !ok {
+		return notFound("volume", uuid)
+	}
+	msv.Spec.Protocol = ""
+	msv.Status.Nexus.DeviceUri = ""
+	return nil
+}
+
+// == MSN ======================
+
+func (cp *ControlPlane) GetMSN(nodeName string) (*common.MayastorNode, error) {
+	err := cp.enter("GetMSN")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	msn, ok := cp.nodes[nodeName]
+	if !ok {
+		return nil, notFound("node", nodeName)
+	}
+	node := *msn
+	return &node, nil
+}
+
+func (cp *ControlPlane) ListMsns() ([]common.MayastorNode, error) {
+	err := cp.enter("ListMsns")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var msns []common.MayastorNode
+	for _, msn := range cp.nodes {
+		msns = append(msns, *msn)
+	}
+	return msns, nil
+}
+
+func (cp *ControlPlane) GetMsNodeStatus(nodeName string) (string, error) {
+	err := cp.enter("GetMsNodeStatus")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	msn, ok := cp.nodes[nodeName]
+	if !ok {
+		return "", notFound("node", nodeName)
+	}
+	return msn.State.Status, nil
+}
+
+// == MSP ======================
+
+func (cp *ControlPlane) GetMsPool(poolName string) (*common.MayastorPool, error) {
+	err := cp.enter("GetMsPool")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	msp, ok := cp.pools[poolName]
+	if !ok {
+		return nil, notFound("pool", poolName)
+	}
+	pool := *msp
+	return &pool, nil
+}
+
+func (cp *ControlPlane) ListMsPools() ([]common.MayastorPool, error) {
+	err := cp.enter("ListMsPools")
+	defer cp.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var msps []common.MayastorPool
+	for _, msp := range cp.pools {
+		msps = append(msps, *msp)
+	}
+	return msps, nil
+}
@@ -7,6 +7,8 @@ import (
 
 type CPv1 struct {
 	nodeIPAddresses *[]string
+	// rest is used for the operations which the kubectl plugin does not support
+	rest restClient
 }
 
 func (cp CPv1) Version() string {
@@ -95,6 +97,7 @@ func (cp CPv1) MspGrpcStateToCrdState(mspState int) string {
 func MakeCP(addr *[]string) CPv1 {
 	return CPv1{
 		nodeIPAddresses: addr,
+		rest:            makeRestClient(addr),
 	}
 }
 
@@ -0,0 +1,19 @@
+package v1
+
+// CPv1Rest is an implementation of the v1 control plane interface which
+// talks directly to the control plane REST server instead of invoking
+// the kubectl plugin.
+// Resource state strings are the same as CPv1.
+type CPv1Rest struct {
+	CPv1
+}
+
+func MakeRestCP(addr *[]string) CPv1Rest {
+	return CPv1Rest{
+		CPv1: MakeCP(addr),
+	}
+}
+
+func (cp CPv1Rest) IsTimeoutError(err error) bool {
+	return isRestTimeoutError(err) || cp.CPv1.IsTimeoutError(err)
+}
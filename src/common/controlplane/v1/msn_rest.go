@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"fmt"
+	"mayastor-e2e/common"
+)
+
+// GetMSN Get pointer to a mayastor control plane node
+func (cp CPv1Rest) GetMSN(nodeName string) (*common.MayastorNode, error) {
+	cpMsn, err := cp.rest.getNode(nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("GetMSN: %w", err)
+	}
+	msn := cpNodeToMsn(cpMsn)
+	return &msn, nil
+}
+
+func (cp CPv1Rest) ListMsns() ([]common.MayastorNode, error) {
+	var msns []common.MayastorNode
+	list, err := cp.rest.listNodes()
+	if err == nil {
+		for _, item := range list {
+			msns = append(msns, cpNodeToMsn(&item))
+		}
+	}
+	return msns, err
+}
+
+func (cp CPv1Rest) GetMsNodeStatus(nodeName string) (string, error) {
+	cpMsn, err := cp.rest.getNode(nodeName)
+	if err != nil {
+		return "", fmt.Errorf("GetMsNodeStatus: %w", err)
+	}
+	return cpMsn.State.Status, nil
+}
@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"fmt"
+	"mayastor-e2e/common"
+)
+
+// GetMsPool Get pointer to a mayastor control plane pool
+func (cp CPv1Rest) GetMsPool(poolName string) (*common.MayastorPool, error) {
+	cpMsp, err := cp.rest.getPool(poolName)
+	if err != nil {
+		return nil, fmt.Errorf("GetMsPool: %w", err)
+	}
+	msp := cpMspToMsp(cpMsp)
+	return &msp, nil
+}
+
+func (cp CPv1Rest) ListMsPools() ([]common.MayastorPool, error) {
+	var msps []common.MayastorPool
+	list, err := cp.rest.listPools()
+	if err == nil {
+		for _, item := range list {
+			msps = append(msps, cpMspToMsp(&item))
+		}
+	}
+	return msps, err
+}
@@ -248,7 +248,7 @@ func CheckAllMayastorVolumesAreHealthy() error {
 	return err
 }
 
-func cpVolumeToMsv(cpMsv *MayastorCpVolume, address []string) common.MayastorVolume {
+func cpVolumeToMsv(cpMsv *MayastorCpVolume, getReplica func(string) (mayastorCpReplica, error)) common.MayastorVolume {
 	var nexusChildren []common.NexusChild
 
 	for _, children := range cpMsv.State.Target.Children {
@@ -259,7 +259,7 @@ func cpVolumeToMsv(cpMsv *MayastorCpVolume, address []string) common.MayastorVol
 	}
 	var replicas []common.Replica
 	for uuid := range cpMsv.State.Replica_topology {
-		replica, err := getMayastorCpReplica(uuid, address)
+		replica, err := getReplica(uuid)
 		if err != nil {
 			logf.Log.Info("Failed to get replicas", "uuid", uuid, "error", err)
 			return common.MayastorVolume{}
@@ -321,7 +321,7 @@ func (cp CPv1) GetMSV(uuid string) (*common.MayastorVolume, error) {
 		return nil, fmt.Errorf("GetMSV: msv.Spec.Num_replicas=\"%v\"", cpMsv.Spec.Num_replicas)
 	}
 
-	msv := cpVolumeToMsv(cpMsv, *cp.nodeIPAddresses)
+	msv := cpVolumeToMsv(cpMsv, cp.getReplica)
 	return &msv, nil
 }
 
@@ -352,7 +352,7 @@ func (cp CPv1) ListMsvs() ([]common.MayastorVolume, error) {
 	list, err := ListMayastorCpVolumes()
 	if err == nil {
 		for _, item := range list {
-			msvs = append(msvs, cpVolumeToMsv(&item, *cp.nodeIPAddresses))
+			msvs = append(msvs, cpVolumeToMsv(&item, cp.getReplica))
 		}
 	}
 	return msvs, err
@@ -411,6 +411,66 @@ func (cp CPv1) CheckAllMsvsAreHealthy() error {
 	return CheckAllMayastorVolumesAreHealthy()
 }
 
+// Volume lifecycle operations are not supported by the kubectl plugin,
+// so these are always sent to the control plane REST server.
+
 func (cp CPv1) DeleteMsv(volName string) error {
-	return fmt.Errorf("delete of mayastor volume not supported %v", volName)
+	err := cp.rest.deleteVolume(volName)
+	if err != nil {
+		return fmt.Errorf("DeleteMsv: %w", err)
+	}
+	logf.Log.Info("DeleteMsv", "uuid", volName)
+	return nil
+}
+
+// CreateMsv create a mayastor volume with the given uuid, size and replica count,
+// the volume is not published.
+func (cp CPv1) CreateMsv(uuid string, sizeBytes int64, replicaCount int) (*common.MayastorVolume, error) {
+	cpMsv, err := cp.rest.createVolume(uuid, sizeBytes, replicaCount)
+	if err != nil {
+		return nil, fmt.Errorf("CreateMsv: %w", err)
+	}
+	logf.Log.Info("CreateMsv", "uuid", uuid, "size", sizeBytes, "Num_replicas", replicaCount)
+	msv := cpVolumeToMsv(cpMsv, cp.rest.getReplica)
+	return &msv, nil
+}
+
+// PublishMsv create the nexus for the volume on the given node and share it
+// using protocol, if node is empty the control plane selects the node.
+// Returns the device uri of the nexus.
+func (cp CPv1) PublishMsv(uuid string, node string, protocol common.ShareProto) (string, error) {
+	cpMsv, err := cp.rest.publishVolume(uuid, node, string(protocol))
+	if err != nil {
+		return "", fmt.Errorf("PublishMsv: %w", err)
+	}
+	logf.Log.Info("PublishMsv", "uuid", uuid, "node", cpMsv.State.Target.Node, "deviceUri", cpMsv.State.Target.DeviceUri)
+	return cpMsv.State.Target.DeviceUri, nil
+}
+
+// UnpublishMsv destroy the nexus for the volume
+func (cp CPv1) UnpublishMsv(uuid string) error {
+	err := cp.rest.unpublishVolume(uuid)
+	if err != nil {
+		return fmt.Errorf("UnpublishMsv: %w", err)
+	}
+	return nil
+}
+
+// ShareMsv share the nexus of a published volume using protocol,
+// returns the share uri.
+func (cp CPv1) ShareMsv(uuid string, protocol common.ShareProto) (string, error) {
+	shareUri, err := cp.rest.shareVolume(uuid, string(protocol))
+	if err != nil {
+		return "", fmt.Errorf("ShareMsv: %w", err)
+	}
+	return shareUri, nil
+}
+
+// UnshareMsv unshare the nexus of a published volume
+func (cp CPv1) UnshareMsv(uuid string) error {
+	err := cp.rest.unshareVolume(uuid)
+	if err != nil {
+		return fmt.Errorf("UnshareMsv: %w", err)
+	}
+	return nil
 }
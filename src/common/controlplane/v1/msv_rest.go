@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"fmt"
+	"mayastor-e2e/common"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// GetMSV Get pointer to a mayastor control plane volume
+// returns nil and no error if the msv is in pending state.
+func (cp CPv1Rest) GetMSV(uuid string) (*common.MayastorVolume, error) {
+	cpMsv, err := cp.rest.getVolume(uuid)
+	if err != nil {
+		return nil, fmt.Errorf("GetMSV: %w", err)
+	}
+	if cpMsv.Spec.Uuid == "" {
+		logf.Log.Info("Msv not found", "uuid", uuid)
+		return nil, nil
+	}
+
+	// pending means still being created
+	if cpMsv.State.Status == "pending" {
+		return nil, nil
+	}
+
+	if cpMsv.State.Status == "" {
+		return nil, fmt.Errorf("GetMSV: state not defined, got msv.Status=\"%v\"", cpMsv.State)
+	}
+
+	if cpMsv.Spec.Num_replicas < 1 {
+		return nil, fmt.Errorf("GetMSV: msv.Spec.Num_replicas=\"%v\"", cpMsv.Spec.Num_replicas)
+	}
+
+	msv := cpVolumeToMsv(cpMsv, cp.rest.getReplica)
+	return &msv, nil
+}
+
+// GetMsvNodes Retrieve the nexus node hosting the Mayastor Volume,
+// and the names of the replica nodes
+func (cp CPv1Rest) GetMsvNodes(uuid string) (string, []string) {
+	msv, err := cp.GetMSV(uuid)
+	if err != nil || msv == nil {
+		logf.Log.Info("failed to get mayastor volume", "uuid", uuid, "error", err)
+		return "", nil
+	}
+	node := msv.Status.Nexus.Node
+	replicas := make([]string, msv.Spec.ReplicaCount)
+	for ix, r := range msv.Status.Replicas {
+		if ix < len(replicas) {
+			replicas[ix] = r.Node
+		}
+	}
+	return node, replicas
+}
+
+func (cp CPv1Rest) ListMsvs() ([]common.MayastorVolume, error) {
+	var msvs []common.MayastorVolume
+	list, err := cp.rest.listVolumes()
+	if err == nil {
+		for _, item := range list {
+			msvs = append(msvs, cpVolumeToMsv(&item, cp.rest.getReplica))
+		}
+	}
+	return msvs, err
+}
+
+func (cp CPv1Rest) SetMsvReplicaCount(uuid string, replicaCount int) error {
+	err := cp.rest.scaleVolume(uuid, replicaCount)
+	logf.Log.Info("ScaleMayastorVolume", "Num_replicas", replicaCount)
+	return err
+}
+
+func (cp CPv1Rest) GetMsvState(uuid string) (string, error) {
+	msv, err := cp.rest.getVolume(uuid)
+	if err != nil {
+		return "", err
+	}
+	return msv.State.Status, nil
+}
+
+func (cp CPv1Rest) GetMsvReplicas(volName string) ([]common.Replica, error) {
+	vol, err := cp.GetMSV(volName)
+	if err != nil {
+		logf.Log.Info("Failed to get replicas", "uuid", volName, "error", err)
+		return nil, err
+	}
+	if vol == nil {
+		return nil, fmt.Errorf("GetMsvReplicas: msv %s is pending", volName)
+	}
+	return vol.Status.Replicas, nil
+}
+
+func (cp CPv1Rest) GetMsvNexusChildren(volName string) ([]common.NexusChild, error) {
+	var children []common.NexusChild
+	msv, err := cp.rest.getVolume(volName)
+	if err == nil {
+		for _, child := range msv.State.Target.Children {
+			children = append(children, common.NexusChild{
+				State: child.State,
+				Uri:   child.Uri,
+			})
+		}
+	}
+	return children, err
+}
+
+func (cp CPv1Rest) GetMsvNexusState(uuid string) (string, error) {
+	msv, err := cp.rest.getVolume(uuid)
+	if err != nil {
+		return "", err
+	}
+	return msv.State.Target.State, nil
+}
+
+func (cp CPv1Rest) IsMsvPublished(uuid string) bool {
+	msv, err := cp.rest.getVolume(uuid)
+	if err == nil {
+		return msv.Spec.Target.Node != ""
+	}
+	return false
+}
+
+func (cp CPv1Rest) IsMsvDeleted(uuid string) bool {
+	msv, err := cp.rest.getVolume(uuid)
+	if err != nil {
+		if IsRestNotFoundError(err) {
+			return true
+		}
+		logf.Log.Error(err, "IsMsvDeleted msv is nil")
+		return false
+	}
+	if msv.Spec.Uuid == "" {
+		return true
+	}
+	logf.Log.Info("IsMsvDeleted", "msv", msv)
+	return false
+}
+
+func (cp CPv1Rest) CheckForMsvs() (bool, error) {
+	logf.Log.Info("CheckForMsvs")
+	foundResources := false
+
+	msvs, err := cp.rest.listVolumes()
+	if err == nil && len(msvs) != 0 {
+		logf.Log.Info("CheckForMsvs: found MayastorVolumes",
+			"MayastorVolumes", msvs)
+		foundResources = true
+	}
+	return foundResources, err
+}
+
+func (cp CPv1Rest) CheckAllMsvsAreHealthy() error {
+	allHealthy := true
+	msvs, err := cp.rest.listVolumes()
+	if err == nil {
+		for _, msv := range msvs {
+			if msv.State.Status != cp.VolStateHealthy() {
+				logf.Log.Info("CheckAllMsvsAreHealthy",
+					"msv.State.Status", msv.State.Status,
+					"msv.Spec", msv.Spec,
+					"msv.State", msv.State,
+				)
+				allHealthy = false
+			}
+		}
+	}
+
+	if !allHealthy {
+		return fmt.Errorf("CheckAllMsvsAreHealthy: all MSVs were not healthy")
+	}
+	return err
+}
@@ -60,3 +60,7 @@ func getMayastorCpReplica(replicaUuid string, address []string) (mayastorCpRepli
 	}
 	return response, nil
 }
+
+func (cp CPv1) getReplica(replicaUuid string) (mayastorCpReplica, error) {
+	return getMayastorCpReplica(replicaUuid, *cp.nodeIPAddresses)
+}
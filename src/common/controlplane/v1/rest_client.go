@@ -0,0 +1,258 @@
+package v1
+
+// Minimal client for the control plane REST server.
+// The REST server is exposed as a NodePort service (Product.ControlPlaneRestServer),
+// the port is Product.KubectlPluginPort, requests are sent to the master nodes
+// in turn until one of them responds.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mayastor-e2e/common/e2e_config"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RestJsonError is the error body returned by the control plane REST server
+type RestJsonError struct {
+	Details string `json:"details"`
+	Kind    string `json:"kind"`
+	// HTTP status code of the response, not part of the body
+	StatusCode int `json:"-"`
+}
+
+func (e *RestJsonError) Error() string {
+	return fmt.Sprintf("RestJsonError: status=%d kind=%s details=%s", e.StatusCode, e.Kind, e.Details)
+}
+
+// IsRestNotFoundError returns true if err is a RestJsonError of kind NotFound
+func IsRestNotFoundError(err error) bool {
+	var restErr *RestJsonError
+	if errors.As(err, &restErr) {
+		return restErr.Kind == "NotFound" || restErr.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// isRestTimeoutError returns true if err is a timeout reported by the REST server
+// or a timeout on the request itself.
+func isRestTimeoutError(err error) bool {
+	var restErr *RestJsonError
+	if errors.As(err, &restErr) {
+		return restErr.Kind == "Timeout" || restErr.Kind == "DeadlineExceeded" ||
+			restErr.StatusCode == http.StatusRequestTimeout ||
+			restErr.StatusCode == http.StatusGatewayTimeout
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// restHttpClient is shared by all REST clients so that connections are reused
+var restHttpClient *http.Client
+var restHttpClientOnce sync.Once
+
+type restClient struct {
+	nodeIPAddresses *[]string
+	port            string
+	timeout         time.Duration
+	client          *http.Client
+}
+
+func makeRestClient(addr *[]string) restClient {
+	timeout := time.Duration(e2e_config.GetConfig().ControlPlaneRestTimeoutSecs) * time.Second
+	restHttpClientOnce.Do(func() {
+		restHttpClient = &http.Client{Timeout: timeout}
+	})
+	return restClient{
+		nodeIPAddresses: addr,
+		port:            e2e_config.GetConfig().Product.KubectlPluginPort,
+		timeout:         timeout,
+		client:          restHttpClient,
+	}
+}
+
+// do sends a request to the REST server, trying each node address in turn until a
+// response is received. The body of a successful response is unmarshalled into out,
+// unless out is nil. A response with a non 2xx status code is returned as a *RestJsonError
+func (rc restClient) do(method string, path string, in interface{}, out interface{}) error {
+	if rc.nodeIPAddresses == nil || len(*rc.nodeIPAddresses) == 0 {
+		return fmt.Errorf("control plane REST server: no node addresses")
+	}
+	var reqBody []byte
+	if in != nil {
+		var err error
+		reqBody, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for _, addr := range *rc.nodeIPAddresses {
+		reqUrl := fmt.Sprintf("http://%s:%s/v0%s", addr, rc.port, path)
+		var status int
+		var body []byte
+		status, body, err = rc.send(method, reqUrl, reqBody)
+		if err != nil {
+			// failed to reach the server, try the next address
+			logf.Log.Info("REST request failed", "method", method, "url", reqUrl, "error", err)
+			continue
+		}
+		if status < 200 || status > 299 {
+			restErr := RestJsonError{StatusCode: status}
+			if json.Unmarshal(body, &restErr) != nil || restErr.Kind == "" {
+				restErr.Details = string(body)
+			}
+			return &restErr
+		}
+		if out != nil && len(body) != 0 {
+			if err = json.Unmarshal(body, out); err != nil {
+				logf.Log.Info("Failed to unmarshal REST response", "url", reqUrl, "body", string(body))
+			}
+		}
+		return err
+	}
+	return err
+}
+
+func (rc restClient) send(method string, reqUrl string, reqBody []byte) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rc.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if reqBody != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+func (rc restClient) getVolume(uuid string) (*MayastorCpVolume, error) {
+	var vol MayastorCpVolume
+	err := rc.do(http.MethodGet, "/volumes/"+url.PathEscape(uuid), nil, &vol)
+	if err != nil {
+		return nil, err
+	}
+	return &vol, nil
+}
+
+func (rc restClient) listVolumes() ([]MayastorCpVolume, error) {
+	var vols []MayastorCpVolume
+	err := rc.do(http.MethodGet, "/volumes", nil, &vols)
+	return vols, err
+}
+
+func (rc restClient) deleteVolume(uuid string) error {
+	return rc.do(http.MethodDelete, "/volumes/"+url.PathEscape(uuid), nil, nil)
+}
+
+func (rc restClient) scaleVolume(uuid string, replicaCount int) error {
+	return rc.do(http.MethodPut, fmt.Sprintf("/volumes/%s/replica_count/%d", url.PathEscape(uuid), replicaCount), nil, nil)
+}
+
+func (rc restClient) getReplica(uuid string) (mayastorCpReplica, error) {
+	var replica mayastorCpReplica
+	err := rc.do(http.MethodGet, "/replicas/"+url.PathEscape(uuid), nil, &replica)
+	return replica, err
+}
+
+func (rc restClient) getNode(nodeName string) (*MayastorCpNode, error) {
+	var node MayastorCpNode
+	err := rc.do(http.MethodGet, "/nodes/"+url.PathEscape(nodeName), nil, &node)
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+func (rc restClient) listNodes() ([]MayastorCpNode, error) {
+	var nodes []MayastorCpNode
+	err := rc.do(http.MethodGet, "/nodes", nil, &nodes)
+	return nodes, err
+}
+
+func (rc restClient) getPool(poolName string) (*MayastorCpPool, error) {
+	var pool MayastorCpPool
+	err := rc.do(http.MethodGet, "/pools/"+url.PathEscape(poolName), nil, &pool)
+	if err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func (rc restClient) listPools() ([]MayastorCpPool, error) {
+	var pools []MayastorCpPool
+	err := rc.do(http.MethodGet, "/pools", nil, &pools)
+	return pools, err
+}
+
+type createVolumeBody struct {
+	Policy   policy `json:"policy"`
+	Replicas int    `json:"replicas"`
+	Size     int64  `json:"size"`
+}
+
+func (rc restClient) createVolume(uuid string, sizeBytes int64, replicaCount int) (*MayastorCpVolume, error) {
+	body := createVolumeBody{
+		Policy:   policy{Self_heal: true},
+		Replicas: replicaCount,
+		Size:     sizeBytes,
+	}
+	var vol MayastorCpVolume
+	err := rc.do(http.MethodPut, "/volumes/"+url.PathEscape(uuid), body, &vol)
+	if err != nil {
+		return nil, err
+	}
+	return &vol, nil
+}
+
+func (rc restClient) publishVolume(uuid string, node string, protocol string) (*MayastorCpVolume, error) {
+	path := fmt.Sprintf("/volumes/%s/target?protocol=%s", url.PathEscape(uuid), url.QueryEscape(protocol))
+	if node != "" {
+		path += "&node=" + url.QueryEscape(node)
+	}
+	var vol MayastorCpVolume
+	err := rc.do(http.MethodPut, path, nil, &vol)
+	if err != nil {
+		return nil, err
+	}
+	return &vol, nil
+}
+
+func (rc restClient) unpublishVolume(uuid string) error {
+	return rc.do(http.MethodDelete, fmt.Sprintf("/volumes/%s/target", url.PathEscape(uuid)), nil, nil)
+}
+
+func (rc restClient) shareVolume(uuid string, protocol string) (string, error) {
+	var shareUri string
+	err := rc.do(http.MethodPut, fmt.Sprintf("/volumes/%s/share/%s", url.PathEscape(uuid), url.PathEscape(protocol)), nil, &shareUri)
+	return shareUri, err
+}
+
+func (rc restClient) unshareVolume(uuid string) error {
+	return rc.do(http.MethodDelete, fmt.Sprintf("/volumes/%s/share", url.PathEscape(uuid)), nil, nil)
+}
@@ -0,0 +1,333 @@
+package controlplane
+
+// Event streams of MSV, MSN and MSP state transitions.
+// The watch functions in this file poll the control plane interface and
+// emit an event for every change in state observed between polls.
+// Informer backed watches on the custom resources are in the custom_resources package,
+// both use the trackers defined here to generate events.
+
+import (
+	"context"
+	"fmt"
+	"mayastor-e2e/common"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type EventType string
+
+const (
+	EventAdded    EventType = "Added"
+	EventModified EventType = "Modified"
+	EventDeleted  EventType = "Deleted"
+)
+
+// MsvEvent a change in the state of a volume, its nexus or nexus children
+type MsvEvent struct {
+	Type      EventType
+	Timestamp time.Time
+	Uuid      string
+
+	PrevState      string
+	State          string
+	PrevNexusState string
+	NexusState     string
+	PrevChildren   []common.NexusChild
+	Children       []common.NexusChild
+	// Msv is nil for EventDeleted
+	Msv *common.MayastorVolume
+}
+
+func (ev MsvEvent) String() string {
+	return fmt.Sprintf("%s %s %s: state %q->%q nexus %q->%q children %v->%v",
+		ev.Timestamp.Format("15:04:05.000"), ev.Type, ev.Uuid,
+		ev.PrevState, ev.State, ev.PrevNexusState, ev.NexusState,
+		childStates(ev.PrevChildren), childStates(ev.Children))
+}
+
+// MsnEvent a change in the status of a mayastor node
+type MsnEvent struct {
+	Type       EventType
+	Timestamp  time.Time
+	Name       string
+	PrevStatus string
+	Status     string
+}
+
+func (ev MsnEvent) String() string {
+	return fmt.Sprintf("%s %s %s: status %q->%q",
+		ev.Timestamp.Format("15:04:05.000"), ev.Type, ev.Name, ev.PrevStatus, ev.Status)
+}
+
+// MspEvent a change in the state of a mayastor pool
+type MspEvent struct {
+	Type      EventType
+	Timestamp time.Time
+	Name      string
+	PrevState string
+	State     string
+}
+
+func (ev MspEvent) String() string {
+	return fmt.Sprintf("%s %s %s: state %q->%q",
+		ev.Timestamp.Format("15:04:05.000"), ev.Type, ev.Name, ev.PrevState, ev.State)
+}
+
+func childStates(children []common.NexusChild) []string {
+	states := make([]string, len(children))
+	for ix, child := range children {
+		states[ix] = child.State
+	}
+	return states
+}
+
+func childrenEqual(a []common.NexusChild, b []common.NexusChild) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ix := range a {
+		if a[ix] != b[ix] {
+			return false
+		}
+	}
+	return true
+}
+
+// == Trackers ======================
+
+// MsvTracker records the last observed state of volumes and
+// generates events for changes in state
+type MsvTracker struct {
+	msvs map[string]common.MayastorVolume
+}
+
+func NewMsvTracker() *MsvTracker {
+	return &MsvTracker{msvs: make(map[string]common.MayastorVolume)}
+}
+
+// Update records the volume, returns an event and true if the state has changed
+func (tr *MsvTracker) Update(msv common.MayastorVolume, ts time.Time) (MsvEvent, bool) {
+	prev, seen := tr.msvs[msv.Name]
+	tr.msvs[msv.Name] = msv
+	ev := MsvEvent{
+		Type:       EventAdded,
+		Timestamp:  ts,
+		Uuid:       msv.Name,
+		State:      msv.Status.State,
+		NexusState: msv.Status.Nexus.State,
+		Children:   msv.Status.Nexus.Children,
+		Msv:        &msv,
+	}
+	if seen {
+		if prev.Status.State == msv.Status.State &&
+			prev.Status.Nexus.State == msv.Status.Nexus.State &&
+			childrenEqual(prev.Status.Nexus.Children, msv.Status.Nexus.Children) {
+			return ev, false
+		}
+		ev.Type = EventModified
+		ev.PrevState = prev.Status.State
+		ev.PrevNexusState = prev.Status.Nexus.State
+		ev.PrevChildren = prev.Status.Nexus.Children
+	}
+	return ev, true
+}
+
+// Delete removes the volume, returns an event and true if the volume was being tracked
+func (tr *MsvTracker) Delete(uuid string, ts time.Time) (MsvEvent, bool) {
+	prev, seen := tr.msvs[uuid]
+	if !seen {
+		return MsvEvent{}, false
+	}
+	delete(tr.msvs, uuid)
+	return MsvEvent{
+		Type:           EventDeleted,
+		Timestamp:      ts,
+		Uuid:           uuid,
+		PrevState:      prev.Status.State,
+		PrevNexusState: prev.Status.Nexus.State,
+		PrevChildren:   prev.Status.Nexus.Children,
+	}, true
+}
+
+// Sync updates the tracker from a complete list of volumes,
+// volumes not in the list are deleted.
+func (tr *MsvTracker) Sync(msvs []common.MayastorVolume, ts time.Time) []MsvEvent {
+	var events []MsvEvent
+	present := make(map[string]bool)
+	for _, msv := range msvs {
+		present[msv.Name] = true
+		if ev, changed := tr.Update(msv, ts); changed {
+			events = append(events, ev)
+		}
+	}
+	for uuid := range tr.msvs {
+		if !present[uuid] {
+			if ev, deleted := tr.Delete(uuid, ts); deleted {
+				events = append(events, ev)
+			}
+		}
+	}
+	return events
+}
+
+// StateTracker records the last observed state of named resources
+// and generates events for changes in state, used for nodes and pools.
+type StateTracker struct {
+	states map[string]string
+}
+
+func NewStateTracker() *StateTracker {
+	return &StateTracker{states: make(map[string]string)}
+}
+
+// Update records the state, returns the event type, previous state and true if the state has changed
+func (tr *StateTracker) Update(name string, state string) (EventType, string, bool) {
+	prev, seen := tr.states[name]
+	tr.states[name] = state
+	if !seen {
+		return EventAdded, "", true
+	}
+	return EventModified, prev, prev != state
+}
+
+// Delete removes the resource, returns the previous state and true if it was being tracked
+func (tr *StateTracker) Delete(name string) (string, bool) {
+	prev, seen := tr.states[name]
+	delete(tr.states, name)
+	return prev, seen
+}
+
+// Sync updates the tracker from a complete map of name to state,
+// calls emit for every change
+func (tr *StateTracker) Sync(states map[string]string, emit func(EventType, string, string, string)) {
+	for name, state := range states {
+		if evType, prev, changed := tr.Update(name, state); changed {
+			emit(evType, name, prev, state)
+		}
+	}
+	for name := range tr.states {
+		if _, ok := states[name]; !ok {
+			if prev, deleted := tr.Delete(name); deleted {
+				emit(EventDeleted, name, prev, "")
+			}
+		}
+	}
+}
+
+// == Watches ======================
+
+// WatchMsvs polls the control plane every pollPeriod and emits an event for every
+// change in volume, nexus or nexus child state. Volumes which exist when the
+// watch starts are emitted as EventAdded. The channel is closed when ctx is done.
+func WatchMsvs(ctx context.Context, pollPeriod time.Duration) <-chan MsvEvent {
+	events := make(chan MsvEvent, 64)
+	go func() {
+		defer close(events)
+		tracker := NewMsvTracker()
+		ticker := time.NewTicker(pollPeriod)
+		defer ticker.Stop()
+		for {
+			msvs, err := getControlPlane().ListMsvs()
+			if err != nil {
+				logf.Log.Info("WatchMsvs: failed to list volumes", "error", err)
+			} else {
+				for _, ev := range tracker.Sync(msvs, time.Now()) {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// WatchMsns polls the control plane every pollPeriod and emits an event for every
+// change in node status. The channel is closed when ctx is done.
+func WatchMsns(ctx context.Context, pollPeriod time.Duration) <-chan MsnEvent {
+	events := make(chan MsnEvent, 64)
+	go func() {
+		defer close(events)
+		tracker := NewStateTracker()
+		ticker := time.NewTicker(pollPeriod)
+		defer ticker.Stop()
+		for {
+			msns, err := getControlPlane().ListMsns()
+			if err != nil {
+				logf.Log.Info("WatchMsns: failed to list nodes", "error", err)
+			} else {
+				states := make(map[string]string)
+				for _, msn := range msns {
+					states[msn.Name] = msn.State.Status
+				}
+				ts := time.Now()
+				var batch []MsnEvent
+				tracker.Sync(states, func(evType EventType, name string, prev string, state string) {
+					batch = append(batch, MsnEvent{Type: evType, Timestamp: ts, Name: name, PrevStatus: prev, Status: state})
+				})
+				for _, ev := range batch {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// WatchMsPools polls the control plane every pollPeriod and emits an event for every
+// change in pool state. The channel is closed when ctx is done.
+func WatchMsPools(ctx context.Context, pollPeriod time.Duration) <-chan MspEvent {
+	events := make(chan MspEvent, 64)
+	go func() {
+		defer close(events)
+		tracker := NewStateTracker()
+		ticker := time.NewTicker(pollPeriod)
+		defer ticker.Stop()
+		for {
+			msps, err := getControlPlane().ListMsPools()
+			if err != nil {
+				logf.Log.Info("WatchMsPools: failed to list pools", "error", err)
+			} else {
+				states := make(map[string]string)
+				for _, msp := range msps {
+					states[msp.Name] = msp.Status.State
+				}
+				ts := time.Now()
+				var batch []MspEvent
+				tracker.Sync(states, func(evType EventType, name string, prev string, state string) {
+					batch = append(batch, MspEvent{Type: evType, Timestamp: ts, Name: name, PrevState: prev, State: state})
+				})
+				for _, ev := range batch {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
@@ -0,0 +1,73 @@
+package controlplane
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/controlplane/fake"
+)
+
+func TestWatchMsvs(t *testing.T) {
+	cp := fake.NewControlPlane()
+	SetControlPlaneInterface(cp)
+	cp.AddMsv(common.MayastorVolume{
+		Name: "vol-1",
+		Status: common.MayastorVolumeStatus{
+			Nexus: common.Nexus{
+				State:    "Online",
+				Children: []common.NexusChild{{State: "Online", Uri: "a"}, {State: "Online", Uri: "b"}},
+			},
+			State: "Online",
+		},
+	})
+	// the first list observes the initial transition
+	cp.QueueMsvTransitions("vol-1",
+		fake.MsvStateTransition("Online", "Online", nil),
+		fake.MsvStateTransition("Degraded", "Faulted", []string{"Online", "Faulted"}),
+		fake.MsvStateTransition("Degraded", "Degraded", []string{"Online", "Degraded"}),
+		fake.MsvStateTransition("Degraded", "Degraded", []string{"Online", "Degraded"}),
+		fake.MsvStateTransition("Online", "Online", []string{"Online", "Online"}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var nexusStates []string
+	for ev := range WatchMsvs(ctx, 10*time.Millisecond) {
+		nexusStates = append(nexusStates, ev.NexusState)
+		if ev.Type == EventModified && ev.State == "Online" {
+			cancel()
+		}
+	}
+	expected := []string{"Online", "Faulted", "Degraded", "Online"}
+	if len(nexusStates) != len(expected) {
+		t.Fatalf("expected nexus states %v got %v", expected, nexusStates)
+	}
+	for ix := range expected {
+		if nexusStates[ix] != expected[ix] {
+			t.Fatalf("expected nexus states %v got %v", expected, nexusStates)
+		}
+	}
+}
+
+func TestStateTrackerSync(t *testing.T) {
+	tracker := NewStateTracker()
+	var events []EventType
+	emit := func(evType EventType, name string, prev string, state string) {
+		events = append(events, evType)
+	}
+	tracker.Sync(map[string]string{"pool-1": "Online"}, emit)
+	tracker.Sync(map[string]string{"pool-1": "Online"}, emit)
+	tracker.Sync(map[string]string{"pool-1": "Degraded"}, emit)
+	tracker.Sync(map[string]string{}, emit)
+	expected := []EventType{EventAdded, EventModified, EventDeleted}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v got %v", expected, events)
+	}
+	for ix := range expected {
+		if events[ix] != expected[ix] {
+			t.Fatalf("expected events %v got %v", expected, events)
+		}
+	}
+}
@@ -31,7 +31,9 @@ func init() {
 		}
 		config, err := testEnv.Start()
 		if err != nil {
-			fmt.Printf("Error %v", err)
+			// no cluster, clientsets are left unset
+			fmt.Printf("Error %v\n", err)
+			return
 		}
 		_ = v1alpha1Api.PoolAddToScheme(scheme.Scheme)
 		_ = v1alpha1Api.NodeAddToScheme(scheme.Scheme)
@@ -211,6 +213,19 @@ func CRD_ListMsVols() ([]v1alpha1Api.MayastorVolume, error) {
 	return volList.Items, nil
 }
 
+// ListMsVols returns the MayastorVolume custom resources as mayastor volumes
+func ListMsVols() ([]common.MayastorVolume, error) {
+	crdMsvs, err := CRD_ListMsVols()
+	if err != nil {
+		return nil, err
+	}
+	var msvs []common.MayastorVolume
+	for i := range crdMsvs {
+		msvs = append(msvs, crdMsvToMsv(&crdMsvs[i]))
+	}
+	return msvs, nil
+}
+
 // Helper functions
 
 // GetMsVolState convenience function to retrieve the volume state.
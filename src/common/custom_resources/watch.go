@@ -0,0 +1,211 @@
+package custom_resources
+
+// Informer backed event streams for Mayastor custom resources,
+// events are the same as those generated by the control plane watch functions.
+
+import (
+	"context"
+	"time"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/controlplane"
+	v1alpha1Api "mayastor-e2e/common/custom_resources/api/types/v1alpha1"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// runInformer runs an informer until ctx is done, events are serialised
+// so handlers need not be thread safe, done is called when the informer stops.
+func runInformer(ctx context.Context, lw *cache.ListWatch, objType runtime.Object,
+	onUpdate func(obj interface{}), onDelete func(obj interface{}), done func()) {
+	_, informer := cache.NewInformer(lw, objType, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    onUpdate,
+		UpdateFunc: func(_, obj interface{}) { onUpdate(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			onDelete(obj)
+		},
+	})
+	go func() {
+		defer done()
+		informer.Run(ctx.Done())
+	}()
+}
+
+func crdMsvToMsv(crdMsv *v1alpha1Api.MayastorVolume) common.MayastorVolume {
+	var children []common.NexusChild
+	for _, child := range crdMsv.Status.Nexus.Children {
+		children = append(children, common.NexusChild{State: child.State, Uri: child.Uri})
+	}
+	var replicas []common.Replica
+	for _, replica := range crdMsv.Status.Replicas {
+		replicas = append(replicas, common.Replica{
+			Node:    replica.Node,
+			Offline: replica.Offline,
+			Pool:    replica.Pool,
+			Uri:     replica.Uri,
+		})
+	}
+	return common.MayastorVolume{
+		Name: crdMsv.Name,
+		Spec: common.MayastorVolumeSpec{
+			Protocol:      crdMsv.Spec.Protocol,
+			ReplicaCount:  crdMsv.Spec.ReplicaCount,
+			RequiredBytes: crdMsv.Spec.RequiredBytes,
+		},
+		Status: common.MayastorVolumeStatus{
+			Nexus: common.Nexus{
+				Children:  children,
+				DeviceUri: crdMsv.Status.Nexus.DeviceUri,
+				Node:      crdMsv.Status.Nexus.Node,
+				State:     crdMsv.Status.Nexus.State,
+				Uuid:      crdMsv.Name,
+			},
+			Reason:   crdMsv.Status.Reason,
+			Replicas: replicas,
+			Size:     crdMsv.Status.Size,
+			State:    crdMsv.Status.State,
+		},
+	}
+}
+
+// WatchMsVols emits an event for every change in state of a MayastorVolume custom resource,
+// the channel is closed when ctx is done.
+func WatchMsVols(ctx context.Context) <-chan controlplane.MsvEvent {
+	events := make(chan controlplane.MsvEvent, 64)
+	tracker := controlplane.NewMsvTracker()
+	send := func(ev controlplane.MsvEvent) {
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+		}
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metaV1.ListOptions) (runtime.Object, error) {
+			return volClientSet.MayastorVolumes().List(ctx, opts)
+		},
+		WatchFunc: func(opts metaV1.ListOptions) (watch.Interface, error) {
+			return volClientSet.MayastorVolumes().Watch(ctx, opts)
+		},
+	}
+	runInformer(ctx, lw, &v1alpha1Api.MayastorVolume{},
+		func(obj interface{}) {
+			if crdMsv, ok := obj.(*v1alpha1Api.MayastorVolume); ok {
+				if ev, changed := tracker.Update(crdMsvToMsv(crdMsv), time.Now()); changed {
+					send(ev)
+				}
+			}
+		},
+		func(obj interface{}) {
+			if crdMsv, ok := obj.(*v1alpha1Api.MayastorVolume); ok {
+				if ev, deleted := tracker.Delete(crdMsv.Name, time.Now()); deleted {
+					send(ev)
+				}
+			}
+		},
+		func() { close(events) },
+	)
+	return events
+}
+
+// WatchMsNodes emits an event for every change in status of a MayastorNode custom resource,
+// the channel is closed when ctx is done.
+func WatchMsNodes(ctx context.Context) <-chan controlplane.MsnEvent {
+	events := make(chan controlplane.MsnEvent, 64)
+	tracker := controlplane.NewStateTracker()
+	send := func(ev controlplane.MsnEvent) {
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+		}
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metaV1.ListOptions) (runtime.Object, error) {
+			return nodeClientSet.MayastorNodes().List(ctx, opts)
+		},
+		WatchFunc: func(opts metaV1.ListOptions) (watch.Interface, error) {
+			return nodeClientSet.MayastorNodes().Watch(ctx, opts)
+		},
+	}
+	runInformer(ctx, lw, &v1alpha1Api.MayastorNode{},
+		func(obj interface{}) {
+			if msn, ok := obj.(*v1alpha1Api.MayastorNode); ok {
+				if evType, prev, changed := tracker.Update(msn.Name, msn.Status); changed {
+					send(controlplane.MsnEvent{Type: evType, Timestamp: time.Now(), Name: msn.Name, PrevStatus: prev, Status: msn.Status})
+				}
+			}
+		},
+		func(obj interface{}) {
+			if msn, ok := obj.(*v1alpha1Api.MayastorNode); ok {
+				if prev, deleted := tracker.Delete(msn.Name); deleted {
+					send(controlplane.MsnEvent{Type: controlplane.EventDeleted, Timestamp: time.Now(), Name: msn.Name, PrevStatus: prev})
+				}
+			}
+		},
+		func() { close(events) },
+	)
+	return events
+}
+
+// WatchMsPools emits an event for every change in state of a MayastorPool custom resource,
+// the channel is closed when ctx is done.
+func WatchMsPools(ctx context.Context) <-chan controlplane.MspEvent {
+	events := make(chan controlplane.MspEvent, 64)
+	tracker := controlplane.NewStateTracker()
+	send := func(ev controlplane.MspEvent) {
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+		}
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metaV1.ListOptions) (runtime.Object, error) {
+			return poolClientSet.MayastorPools().List(ctx, opts)
+		},
+		WatchFunc: func(opts metaV1.ListOptions) (watch.Interface, error) {
+			return poolClientSet.MayastorPools().Watch(ctx, opts)
+		},
+	}
+	runInformer(ctx, lw, &v1alpha1Api.MayastorPool{},
+		func(obj interface{}) {
+			if msp, ok := obj.(*v1alpha1Api.MayastorPool); ok {
+				if evType, prev, changed := tracker.Update(msp.Name, msp.Status.State); changed {
+					send(controlplane.MspEvent{Type: evType, Timestamp: time.Now(), Name: msp.Name, PrevState: prev, State: msp.Status.State})
+				}
+			}
+		},
+		func(obj interface{}) {
+			if msp, ok := obj.(*v1alpha1Api.MayastorPool); ok {
+				if prev, deleted := tracker.Delete(msp.Name); deleted {
+					send(controlplane.MspEvent{Type: controlplane.EventDeleted, Timestamp: time.Now(), Name: msp.Name, PrevState: prev})
+				}
+			}
+		},
+		func() { close(events) },
+	)
+	return events
+}
+
+// MsVolsServed returns true if MayastorVolume custom resources are served by the cluster,
+// volumes are only reflected in custom resources by control planes which use them.
+func MsVolsServed() bool {
+	if volClientSet == nil {
+		return false
+	}
+	_, err := volClientSet.MayastorVolumes().List(context.TODO(), metaV1.ListOptions{Limit: 1})
+	return err == nil
+}
+
+// MsNodesServed returns true if MayastorNode custom resources are served by the cluster.
+func MsNodesServed() bool {
+	if nodeClientSet == nil {
+		return false
+	}
+	_, err := nodeClientSet.MayastorNodes().List(context.TODO(), metaV1.ListOptions{Limit: 1})
+	return err == nil
+}
@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -13,9 +17,20 @@ import (
 // RestPort is the port on which e2e-agent is listening
 const RestPort = "10012"
 
+// AuthSecretEnv the environment variable which holds the secret shared with the e2e-agent,
+// requests are not authenticated if it is not set
+const AuthSecretEnv = "e2e_agent_secret"
+
+// AuthSecret returns the secret shared with the e2e-agent
+func AuthSecret() string {
+	return os.Getenv(AuthSecretEnv)
+}
+
 // NodeList is the list of nodes to be passed to e2e-agent
 type NodeList struct {
 	Nodes []string `json:"nodes"`
+	// TtlSecs the lease of the fault, the agent default is used if not set
+	TtlSecs int `json:"ttlSecs,omitempty"`
 }
 
 type CmdList struct {
@@ -23,13 +38,125 @@ type CmdList struct {
 }
 
 type Device struct {
-	Device string `json:"device"`
-	Table  string `json:"table"`
+	Device  string `json:"device"`
+	Table   string `json:"table"`
+	TtlSecs int    `json:"ttlSecs,omitempty"`
 }
 
 type ControlledDevice struct {
-	Device string `json:"device"`
-	State  string `json:"state"`
+	Device  string `json:"device"`
+	State   string `json:"state"`
+	TtlSecs int    `json:"ttlSecs,omitempty"`
+}
+
+// Fault a fault applied by the agent, faults are reverted by the agent
+// when their lease expires
+type Fault struct {
+	Id   string `json:"id"`
+	Kind string `json:"kind"`
+	// Target the node, device or port(s) which are faulted
+	Target  string    `json:"target"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// NetworkFault degrades the traffic sent by a node to Peers and/or to and from Ports,
+// see ApplyNetworkFault
+type NetworkFault struct {
+	Id    string   `json:"id"`
+	Peers []string `json:"peers"`
+	Ports []int    `json:"ports"`
+	// Device the network device of the node, if not set the agent uses the device of the route to the peers
+	Device         string  `json:"device"`
+	DelayMs        int     `json:"delayMs"`
+	JitterMs       int     `json:"jitterMs"`
+	LossPercent    float64 `json:"lossPercent"`
+	CorruptPercent float64 `json:"corruptPercent"`
+	// ReorderPercent requires a delay
+	ReorderPercent float64 `json:"reorderPercent"`
+	RateKbit       int     `json:"rateKbit"`
+	// DurationSecs after which the fault is removed by the agent
+	DurationSecs int       `json:"durationSecs"`
+	Expires      time.Time `json:"expires"`
+}
+
+// ExecRequest a command to be executed by the e2e-agent, Argv is not interpreted by a shell
+type ExecRequest struct {
+	Argv []string `json:"argv"`
+	// Env variables in the form KEY=VALUE
+	Env   []string `json:"env,omitempty"`
+	Stdin string   `json:"stdin,omitempty"`
+	// Dir the working directory
+	Dir string `json:"dir,omitempty"`
+	// TimeoutSecs after which the command is killed, the agent default is used if not set
+	TimeoutSecs int `json:"timeoutSecs,omitempty"`
+}
+
+// ExecResult the result of a command executed by the e2e-agent,
+// ExitCode is -1 if the command timed out or was killed by a signal
+type ExecResult struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exitCode"`
+	DurationMs int64  `json:"durationMs"`
+	TimedOut   bool   `json:"timedOut"`
+}
+
+// AgentError the e2e-agent failed to handle a request
+type AgentError struct {
+	Url        string
+	StatusCode int
+	Message    string
+}
+
+func (e *AgentError) Error() string {
+	return fmt.Sprintf("request %s returned code %d: %s", e.Url, e.StatusCode, strings.TrimSpace(e.Message))
+}
+
+// ExecError a command executed by the e2e-agent exited with a non zero exit code or timed out
+type ExecError struct {
+	Argv   []string
+	Result ExecResult
+}
+
+func (e *ExecError) Error() string {
+	if e.Result.TimedOut {
+		return fmt.Sprintf("command %q timed out after %dms, stderr: %s", e.Argv, e.Result.DurationMs, strings.TrimSpace(e.Result.Stderr))
+	}
+	return fmt.Sprintf("command %q exited with code %d, stderr: %s", e.Argv, e.Result.ExitCode, strings.TrimSpace(e.Result.Stderr))
+}
+
+const (
+	ServiceKubelet = "kubelet"
+	// ServiceContainerRuntime the active container runtime of the node, containerd, crio or docker
+	ServiceContainerRuntime = "container-runtime"
+)
+
+const (
+	ServiceStart   = "start"
+	ServiceStop    = "stop"
+	ServiceRestart = "restart"
+)
+
+// ServiceRequest starts, stops or restarts a service
+type ServiceRequest struct {
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	TtlSecs int    `json:"ttlSecs,omitempty"`
+}
+
+// SignalRequest sends a signal to the processes with a name
+type SignalRequest struct {
+	Name string `json:"name"`
+	// Signal name e.g. KILL or SIGKILL, or number
+	Signal  string `json:"signal"`
+	TtlSecs int    `json:"ttlSecs,omitempty"`
+}
+
+// SignalResponse the processes which were signalled, and the fault if they were stopped
+type SignalResponse struct {
+	Pids  []int  `json:"pids"`
+	Fault *Fault `json:"fault,omitempty"`
 }
 
 func sendRequest(reqType, url string, data interface{}) error {
@@ -49,15 +176,18 @@ func sendRequestGetResponse(reqType, url string, data interface{}, verbose bool)
 	}
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Content-Type", "application/json")
+	if secret := AuthSecret(); secret != "" {
+		req.Header.Add("Authorization", "Bearer "+secret)
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("request returned code %d", resp.StatusCode)
+		return "", &AgentError{Url: url, StatusCode: resp.StatusCode, Message: string(bodyBytes)}
 	}
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
 	}
@@ -80,8 +210,9 @@ func IsAgentReachable(serverAddr string) error {
 	return sendRequest("GET", url, nil)
 }
 
-// GracefulReboot reboots the host gracefully
-// It is not yet supported
+// GracefulReboot reboots the host gracefully, services are stopped and
+// file systems are unmounted before the host reboots.
+// Returns an error if the agent could not start the reboot.
 func GracefulReboot(serverAddr string) error {
 	logf.Log.Info("Gracefully rebooting node", "addr", serverAddr)
 	url := "http://" + serverAddr + ":" + RestPort + "/gracefulReboot"
@@ -109,13 +240,49 @@ func AcceptConnectionsFromNodes(serverAddr string, nodes []string) error {
 	return sendRequest("POST", url, data)
 }
 
+// the nvme subsystems of the host in sysfs
+const nvmeSubsystems = "/sys/class/nvme-subsystem"
+
+// matches the block devices of the namespaces of a subsystem e.g. nvme0n1
+var nvmeNamespaceRe = regexp.MustCompile(`^nvme[0-9]+n[0-9]+$`)
+
+// FindNvmeNamespaces returns the sysfs directories of the namespaces of the nvme subsystem
+// whose nqn contains nqn on the node of the e2e-agent, for example
+// /sys/class/nvme-subsystem/nvme-subsys0/nvme0n1. Returns no directories if there is no
+// such subsystem. Only simple commands are executed, so no shell is required on the node.
+func FindNvmeNamespaces(serverAddr string, nqn string) ([]string, error) {
+	out, err := Exec(serverAddr, "ls "+nvmeSubsystems)
+	if err != nil {
+		return nil, err
+	}
+	for _, subsys := range strings.Fields(out) {
+		subsysDir := nvmeSubsystems + "/" + subsys
+		subsysNqn, err := Exec(serverAddr, "cat "+subsysDir+"/subsysnqn")
+		if err != nil {
+			return nil, err
+		}
+		if !strings.Contains(subsysNqn, nqn) {
+			continue
+		}
+		entries, err := Exec(serverAddr, "ls "+subsysDir)
+		if err != nil {
+			return nil, err
+		}
+		var namespaces []string
+		for _, entry := range strings.Fields(entries) {
+			if nvmeNamespaceRe.MatchString(entry) {
+				namespaces = append(namespaces, subsysDir+"/"+entry)
+			}
+		}
+		return namespaces, nil
+	}
+	return nil, nil
+}
+
 // DiskPartition performs operation related to disk prtitioning
 func DiskPartition(serverAddr string, cmd string) error {
-	url := "http://" + serverAddr + ":" + RestPort + "/exec"
-	data := CmdList{
-		Cmd: cmd,
-	}
-	return sendRequest("POST", url, data)
+	_, err := Exec(serverAddr, cmd)
+	return err
 }
 
 // CreateFaultyDevice creates a device which returns an error on write IOs
@@ -128,14 +295,44 @@ func CreateFaultyDevice(serverAddr, device, table string) error {
 	return sendRequest("POST", url, data)
 }
 
-// Exec sends the shell command to the e2e-agent
+// ExecCommand executes a command on the node of the e2e-agent.
+// Returns an *ExecError with the result if the command exits with
+// a non zero exit code or times out, and an *AgentError if the agent
+// fails to handle the request e.g. because the command does not exist.
+func ExecCommand(serverAddr string, req ExecRequest) (*ExecResult, error) {
+	logf.Log.Info("Executing command on node", "argv", req.Argv, "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/v2/exec"
+	resp, err := sendRequestGetResponse("POST", url, req, false)
+	if err != nil {
+		return nil, err
+	}
+	var result ExecResult
+	if err = json.Unmarshal([]byte(resp), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode exec result %s, error: %v", resp, err)
+	}
+	if result.ExitCode != 0 || result.TimedOut {
+		return &result, &ExecError{Argv: req.Argv, Result: result}
+	}
+	return &result, nil
+}
+
+// shellSyntax the characters which would be interpreted by a shell
+const shellSyntax = "|&;<>()$`\\\"'*?[]#~{}"
+
+// Exec executes the command on the node of the e2e-agent, and returns its standard output,
+// the standard error is returned in the *ExecError if the command fails.
+// The command is split into arguments on white space, it is not interpreted by a shell
+// so that it is subject to the allow-list of the agent, commands which use shell syntax
+// e.g. pipes, redirection or quoting are rejected. See ExecCommand for the errors returned.
 func Exec(serverAddr string, command string) (string, error) {
-	logf.Log.Info("Executing command on node", "command", command, "addr", serverAddr)
-	url := "http://" + serverAddr + ":" + RestPort + "/exec"
-	data := CmdList{
-		Cmd: command,
+	if strings.ContainsAny(command, shellSyntax) {
+		return "", fmt.Errorf("command %q requires a shell, Exec does not run commands in a shell", command)
 	}
-	return sendRequestGetResponse("POST", url, data, false)
+	result, err := ExecCommand(serverAddr, ExecRequest{Argv: strings.Fields(command)})
+	if result == nil {
+		return "", err
+	}
+	return result.Stdout, err
 }
 
 // ControlDevice sets the specified to the specified state
@@ -157,3 +354,120 @@ func KillMayastor(serverAddr string) (string, error) {
 	url := "http://" + serverAddr + ":" + RestPort + "/killmayastor"
 	return sendRequestGetResponse("POST", url, nil, true)
 }
+
+// ApplyNetworkFault applies a tc netem fault to the traffic sent by the node,
+// to the peers and/or to and from the ports of fault, e.g. only NVMe-oF (8420)
+// or only mayastor gRPC (10124). At least one peer or port, one impairment
+// and a duration must be set. The fault is removed by the agent when the duration
+// expires. Returns the id of the fault.
+// The fault only applies to traffic sent by the node, to degrade both directions
+// apply the fault on the peers as well.
+func ApplyNetworkFault(serverAddr string, fault NetworkFault) (string, error) {
+	logf.Log.Info("Applying network fault", "fault", fault, "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/networkFault"
+	resp, err := sendRequestGetResponse("POST", url, fault, false)
+	if err != nil {
+		return "", err
+	}
+	var applied NetworkFault
+	if err = json.Unmarshal([]byte(resp), &applied); err != nil {
+		return "", fmt.Errorf("failed to decode network fault %s, error: %v", resp, err)
+	}
+	return applied.Id, nil
+}
+
+// RemoveNetworkFault removes a network fault before it expires
+func RemoveNetworkFault(serverAddr string, id string) error {
+	logf.Log.Info("Removing network fault", "id", id, "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/removeNetworkFault"
+	return sendRequest("POST", url, NetworkFault{Id: id})
+}
+
+// RemoveNetworkFaults removes all network faults applied on the node
+func RemoveNetworkFaults(serverAddr string) error {
+	logf.Log.Info("Removing network faults", "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/removeNetworkFault"
+	return sendRequest("POST", url, NetworkFault{})
+}
+
+// ListNetworkFaults returns the active network faults on the node
+func ListNetworkFaults(serverAddr string) ([]NetworkFault, error) {
+	url := "http://" + serverAddr + ":" + RestPort + "/networkFaults"
+	resp, err := sendRequestGetResponse("GET", url, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	var faults []NetworkFault
+	if err = json.Unmarshal([]byte(resp), &faults); err != nil {
+		return nil, fmt.Errorf("failed to decode network faults %s, error: %v", resp, err)
+	}
+	return faults, nil
+}
+
+// ListFaults returns the active faults on the node, most recent first
+func ListFaults(serverAddr string) ([]Fault, error) {
+	url := "http://" + serverAddr + ":" + RestPort + "/faults"
+	resp, err := sendRequestGetResponse("GET", url, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	var faults []Fault
+	if err = json.Unmarshal([]byte(resp), &faults); err != nil {
+		return nil, fmt.Errorf("failed to decode faults %s, error: %v", resp, err)
+	}
+	return faults, nil
+}
+
+// RevertFault reverts a fault before its lease expires
+func RevertFault(serverAddr string, id string) error {
+	logf.Log.Info("Reverting fault", "id", id, "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/faults/" + id
+	return sendRequest("DELETE", url, nil)
+}
+
+// RevertFaults reverts all faults on the node
+func RevertFaults(serverAddr string) error {
+	logf.Log.Info("Reverting faults", "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/faults"
+	return sendRequest("DELETE", url, nil)
+}
+
+// ControlService starts, stops or restarts a service on the node, the service is
+// ServiceKubelet, ServiceContainerRuntime or the name of a container runtime unit.
+// A stopped service is started by the agent when the lease of the fault expires,
+// ttlSecs is the lease, 0 uses the agent default.
+// The agent does not wait for the container runtime to be restarted or stopped,
+// as that may restart the agent itself.
+func ControlService(serverAddr string, name string, action string, ttlSecs int) error {
+	logf.Log.Info("Controlling service", "service", name, "action", action, "ttlSecs", ttlSecs, "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/service"
+	data := ServiceRequest{
+		Name:    name,
+		Action:  action,
+		TtlSecs: ttlSecs,
+	}
+	return sendRequest("POST", url, data)
+}
+
+// SignalProcess sends a signal e.g. "KILL", "TERM" or "STOP" to all processes with the
+// name on the node, and returns their pids. Stopped processes are continued by the
+// agent when the lease of the fault expires, or by sending "CONT".
+// ttlSecs is the lease, 0 uses the agent default.
+func SignalProcess(serverAddr string, name string, signal string, ttlSecs int) ([]int, error) {
+	logf.Log.Info("Signalling process", "name", name, "signal", signal, "ttlSecs", ttlSecs, "addr", serverAddr)
+	url := "http://" + serverAddr + ":" + RestPort + "/signalProcess"
+	data := SignalRequest{
+		Name:    name,
+		Signal:  signal,
+		TtlSecs: ttlSecs,
+	}
+	resp, err := sendRequestGetResponse("POST", url, data, false)
+	if err != nil {
+		return nil, err
+	}
+	var signalled SignalResponse
+	if err = json.Unmarshal([]byte(resp), &signalled); err != nil {
+		return nil, fmt.Errorf("failed to decode signal response %s, error: %v", resp, err)
+	}
+	return signalled.Pids, nil
+}
@@ -16,6 +16,67 @@ import (
 const ConfigDir = "/configurations"
 const PlatformConfigDir = "/configurations/platforms/"
 
+// FioProfile parameters of a fio workload, options which are not set are not passed to fio
+type FioProfile struct {
+	Rw       string `yaml:"rw" env-default:"randrw"`
+	Bs       string `yaml:"bs" env-default:"4k"`
+	IoDepth  int    `yaml:"ioDepth" env-default:"16"`
+	NumJobs  int    `yaml:"numJobs" env-default:"1"`
+	IoEngine string `yaml:"ioEngine" env-default:"libaio"`
+	// Buffered use buffered IO, fio uses direct IO otherwise
+	Buffered  bool     `yaml:"buffered"`
+	Verify    string   `yaml:"verify" env-default:"crc32"`
+	ExtraArgs []string `yaml:"extraArgs"`
+}
+
+// DefaultFioProfile returns the fio profile with the default parameters
+func DefaultFioProfile() FioProfile {
+	var profile FioProfile
+	_ = cleanenv.ReadEnv(&profile)
+	return profile
+}
+
+// UnmarshalYAML overlays the fields set in yaml on the default fio profile,
+// so that named profiles only need to specify the parameters which differ.
+func (profile *FioProfile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain FioProfile
+	*profile = DefaultFioProfile()
+	return unmarshal((*plain)(profile))
+}
+
+// Options returns the fio options of the profile, options passed
+// before the first job name apply to all jobs
+func (profile FioProfile) Options() []string {
+	var args []string
+	if profile.Rw != "" {
+		args = append(args, "--rw="+profile.Rw)
+	}
+	if profile.Bs != "" {
+		args = append(args, "--bs="+profile.Bs)
+	}
+	if profile.IoDepth != 0 {
+		args = append(args, fmt.Sprintf("--iodepth=%d", profile.IoDepth))
+	}
+	if profile.NumJobs != 0 {
+		args = append(args, fmt.Sprintf("--numjobs=%d", profile.NumJobs))
+	}
+	if profile.IoEngine != "" {
+		args = append(args, "--ioengine="+profile.IoEngine)
+	}
+	if !profile.Buffered {
+		args = append(args, "--direct=1")
+	}
+	if profile.Verify != "" {
+		args = append(args, "--verify="+profile.Verify, "--verify_fatal=1", "--verify_async=2")
+	}
+	return append(args, profile.ExtraArgs...)
+}
+
+// Args returns the fio arguments of a single job running the profile
+func (profile FioProfile) Args() []string {
+	return append([]string{"--name=benchtest"}, profile.Options()...)
+}
+
 // E2EConfig is a application configuration structure
 type E2EConfig struct {
 	ConfigName  string `yaml:"configName" env-default:"default"`
@@ -73,12 +134,33 @@ type E2EConfig struct {
 	// gRPC connection to the mayastor is mandatory for the test run
 	// With few exceptions, all CI configurations MUST set this to true
 	GrpcMandated bool `yaml:"grpcMandated" env-default:"false"`
+	// gRPC connections to mayastor, TLS is used if CaCertFile is set,
+	// mTLS if ClientCertFile and ClientKeyFile are also set.
+	Grpc struct {
+		Port                 int    `yaml:"port" env:"e2e_grpc_port" env-default:"10124"`
+		CaCertFile           string `yaml:"caCertFile" env:"e2e_grpc_ca_cert_file"`
+		ClientCertFile       string `yaml:"clientCertFile" env:"e2e_grpc_client_cert_file"`
+		ClientKeyFile        string `yaml:"clientKeyFile" env:"e2e_grpc_client_key_file"`
+		ServerName           string `yaml:"serverName" env:"e2e_grpc_server_name"`
+		KeepaliveSecs        int    `yaml:"keepaliveSecs" env-default:"30"`
+		KeepaliveTimeoutSecs int    `yaml:"keepaliveTimeoutSecs" env-default:"10"`
+		CallTimeoutSecs      int    `yaml:"callTimeoutSecs" env-default:"30"`
+		// Interval at which a cached connection is health checked before it is reused, 0 disables the check
+		HealthCheckSecs int `yaml:"healthCheckSecs" env-default:"30"`
+		// Maximum number of nodes called concurrently by cluster-wide listings
+		MaxConcurrency int `yaml:"maxConcurrency" env:"e2e_grpc_max_concurrency" env-default:"16"`
+	} `yaml:"grpc"`
 	// Generic configuration files used for CI and automation should not define MayastorRootDir and E2eRootDir
 	MayastorRootDir  string `yaml:"mayastorRootDir" env:"e2e_mayastor_root_dir"`
 	E2eRootDir       string `yaml:"e2eRootDir"`
 	SessionDir       string `yaml:"sessionDir" env:"e2e_session_dir"`
 	MayastorVersion  string `yaml:"mayastorVersion" env:"e2e_mayastor_version"`
 	KubectlPluginDir string `yaml:"kubectlPluginDir" env:"e2e_kubectl_plugin_dir"`
+	// ControlPlaneAccess selects how the control plane is accessed,
+	// "plugin" invokes the kubectl plugin, "rest" sends requests directly to the REST server
+	ControlPlaneAccess string `yaml:"controlPlaneAccess" env:"e2e_control_plane_access" env-default:"plugin"`
+	// ControlPlaneRestTimeoutSecs timeout for each request to the control plane REST server
+	ControlPlaneRestTimeoutSecs int `yaml:"controlPlaneRestTimeoutSecs" env:"e2e_control_plane_rest_timeout_secs" env-default:"30"`
 
 	// Operational parameters
 	Cores int `yaml:"cores,omitempty"`
@@ -88,7 +170,22 @@ type E2EConfig struct {
 	//	CIRegistry string `yaml:"ciRegistry" env:"e2e_ci_docker_registry" env-default:"ci-registry.mayastor-ci.mayadata.io"`
 	ImageTag string `yaml:"imageTag" env:"e2e_image_tag"`
 	// FIXME: handle empty poolDevice
-	PoolDevice  string `yaml:"poolDevice" env:"e2e_pool_device"`
+	PoolDevice string `yaml:"poolDevice" env:"e2e_pool_device"`
+	// PoolPlacement selects the devices used for pools,
+	// "device" creates a pool on PoolDevice on every mayastor node,
+	// "discover" creates a pool on every unused device on each mayastor node which matches PoolDiscovery
+	PoolPlacement string `yaml:"poolPlacement" env:"e2e_pool_placement" env-default:"device"`
+	PoolDiscovery struct {
+		// Devices smaller than MinSizeMb or larger than MaxSizeMb are not used, 0 means no limit
+		MinSizeMb uint64 `yaml:"minSizeMb" env-default:"0"`
+		MaxSizeMb uint64 `yaml:"maxSizeMb" env-default:"0"`
+		// Regular expressions, if set the device model or path must match,
+		// the path pattern is matched against the device name and udev links
+		ModelPattern string `yaml:"modelPattern" env:"e2e_pool_discovery_model"`
+		PathPattern  string `yaml:"pathPattern" env:"e2e_pool_discovery_path"`
+		// Maximum number of pools created on a node, 0 means no limit
+		MaxDevicesPerNode int `yaml:"maxDevicesPerNode" env-default:"0"`
+	} `yaml:"poolDiscovery"`
 	E2eFioImage string `yaml:"e2eFioImage" env-default:"mayadata/e2e-fio" env:"e2e_fio_image"`
 	E2eFsxImage string `yaml:"e2eFsxImage" env-default:"mayadata/e2e-fsx" env:"e2e_fsx_image"`
 	// This is an advisory setting for individual tests
@@ -111,6 +208,42 @@ type E2EConfig struct {
 	// Run configuration
 	ReportsDir string `yaml:"reportsDir" env:"e2e_reports_dir"`
 	SelfTest   bool   `yaml:"selfTest" env:"e2e_self_test" env-default:"false"`
+	// Period at which mayastor statistics are sampled by tests which collect them
+	StatsCollectionPeriod string `yaml:"statsCollectionPeriod" env:"e2e_stats_collection_period" env-default:"30s"`
+	// Directory to which tools/spdkDump writes the SPDK state of mayastor nodes, defaults to ReportsDir
+	SpdkDumpDir string `yaml:"spdkDumpDir" env:"e2e_spdk_dump_dir"`
+	// Fail the AfterEach check if the cluster consistency report has findings
+	FailOnInconsistency bool `yaml:"failOnInconsistency" env:"e2e_fail_on_inconsistency" env-default:"false"`
+	// Remove replicas and nexuses classified as leaked by the resource check
+	ReapLeakedResources bool `yaml:"reapLeakedResources" env:"e2e_reap_leaked_resources" env-default:"false"`
+	// Period after which replicas and nexuses without a volume are listed again when classifying them
+	OrphanSettleSecs int `yaml:"orphanSettleSecs" env:"e2e_orphan_settle_secs" env-default:"10"`
+
+	// Fio results are written to ReportsDir/fio
+	FioResults struct {
+		// BaselineDir directory of fio summaries of a baseline run, if empty results are not compared
+		BaselineDir string `yaml:"baselineDir" env:"e2e_fio_baseline_dir"`
+		// TolerancePercent worse performance than the baseline which is not a regression
+		TolerancePercent int `yaml:"tolerancePercent" env:"e2e_fio_tolerance_percent" env-default:"20"`
+		// FailOnRegression fail fio runs which regress, regressions are only logged otherwise
+		FailOnRegression bool `yaml:"failOnRegression" env:"e2e_fio_fail_on_regression" env-default:"false"`
+	} `yaml:"fioResults"`
+	// IO workloads run by tests, selected by name see k8stest.NewWorkload
+	Workloads struct {
+		// Fio the profile of the fio workload
+		Fio FioProfile `yaml:"fio"`
+		// FioProfiles named fio profiles, selected by the workload name fio:<profile name>
+		FioProfiles map[string]FioProfile `yaml:"fioProfiles"`
+		Fsx         struct {
+			FsType     string `yaml:"fsType" env-default:"ext4"`
+			Operations int    `yaml:"operations" env-default:"10000"`
+		} `yaml:"fsx"`
+		DdVerify struct {
+			// SizeMb size of the data written, if not specified by the test
+			SizeMb      int `yaml:"sizeMb" env-default:"100"`
+			BlockSizeKb int `yaml:"blockSizeKb" env-default:"1024"`
+		} `yaml:"ddVerify"`
+	} `yaml:"workloads"`
 
 	// Individual Test parameters
 	PVCStress struct {
@@ -156,6 +289,8 @@ type E2EConfig struct {
 		VolSizeMb int `yaml:"volSizeMb" env-default:"500"`
 		// FsVolSizeMb Units are MiB
 		FsVolSizeMb int `yaml:"fsVolSizeMb" env-default:"450"`
+		// Workload name of the IO workload
+		Workload string `yaml:"workload" env-default:"fio"`
 	} `yaml:"basicVolumeIO"`
 	CISmokeTest struct {
 		// FioTimeout is in seconds
@@ -289,6 +424,43 @@ type E2EConfig struct {
 		DurationSecs   int    `yaml:"durationSecs" env-default:"180"`
 		SleepSecs      int    `yaml:"sleepSecs" env-default:"3"`
 	} `yaml:"msvRebuild"`
+	RebuildInterrupt struct {
+		VolMb        int    `yaml:"volMb" env-default:"2048"`
+		FioSizeMb    int    `yaml:"fioSizeMb" env-default:"1536"`
+		DurationSecs int    `yaml:"durationSecs" env-default:"600"`
+		PauseSecs    int    `yaml:"pauseSecs" env-default:"30"`
+		Timeout      string `yaml:"timeout" env-default:"300s"`
+		PollPeriod   string `yaml:"pollPeriod" env-default:"1s"`
+	} `yaml:"rebuildInterrupt"`
+	NvmeAna struct {
+		VolMb        int `yaml:"volMb" env-default:"1024"`
+		FioSizeMb    int `yaml:"fioSizeMb" env-default:"512"`
+		DurationSecs int `yaml:"durationSecs" env-default:"300"`
+		// time for which IO is observed in each ANA state,
+		// must be less than the nvme controller loss timeout
+		ObserveSecs int    `yaml:"observeSecs" env-default:"20"`
+		Timeout     string `yaml:"timeout" env-default:"120s"`
+		PollPeriod  string `yaml:"pollPeriod" env-default:"1s"`
+	} `yaml:"nvmeAna"`
+	ReplicaSnapshot struct {
+		VolMb        int    `yaml:"volMb" env-default:"1024"`
+		FioSizeMb    int    `yaml:"fioSizeMb" env-default:"512"`
+		DurationSecs int    `yaml:"durationSecs" env-default:"300"`
+		Replicas     int    `yaml:"replicas" env-default:"2"`
+		Snapshots    int    `yaml:"snapshots" env-default:"2"`
+		IntervalSecs int    `yaml:"intervalSecs" env-default:"30"`
+		Timeout      string `yaml:"timeout" env-default:"120s"`
+		PollPeriod   string `yaml:"pollPeriod" env-default:"1s"`
+	} `yaml:"replicaSnapshot"`
+	ThinProvisioning struct {
+		// total logical size of the thin provisioned replicas as a percentage of the pool capacity
+		OvercommitPercent int `yaml:"overcommitPercent" env-default:"150"`
+		Replicas          int `yaml:"replicas" env-default:"2"`
+		StepMb            int `yaml:"stepMb" env-default:"64"`
+		Steps             int `yaml:"steps" env-default:"4"`
+		// permitted difference between the pool usage and the data written, for metadata and cluster rounding
+		SlackMb int `yaml:"slackMb" env-default:"16"`
+	} `yaml:"thinProvisioning"`
 	PrimitiveMsvFuzz struct {
 		VolMb               int    `yaml:"volMb" env-default:"64"`
 		VolumeCountPerPool  int    `yaml:"volumeCountPerPool" env-default:"2"`
@@ -0,0 +1,48 @@
+package e2e_config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestFioProfileArgs(t *testing.T) {
+	profile := FioProfile{
+		Rw: "randrw", Bs: "4k", IoDepth: 16, NumJobs: 1, IoEngine: "libaio", Verify: "crc32",
+		ExtraArgs: []string{"--rate=1m"},
+	}
+	expected := []string{"--name=benchtest", "--rw=randrw", "--bs=4k", "--iodepth=16", "--numjobs=1",
+		"--ioengine=libaio", "--direct=1", "--verify=crc32", "--verify_fatal=1", "--verify_async=2", "--rate=1m"}
+	if args := profile.Args(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("args %v, expected %v", args, expected)
+	}
+	if args := (FioProfile{Rw: "write", Buffered: true}).Args(); !reflect.DeepEqual(args, []string{"--name=benchtest", "--rw=write"}) {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestDefaultFioProfile(t *testing.T) {
+	expected := []string{"--rw=randrw", "--bs=4k", "--iodepth=16", "--numjobs=1",
+		"--ioengine=libaio", "--direct=1", "--verify=crc32", "--verify_fatal=1", "--verify_async=2"}
+	if args := DefaultFioProfile().Options(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("options %v, expected %v", args, expected)
+	}
+}
+
+func TestFioProfileUnmarshal(t *testing.T) {
+	var profiles map[string]FioProfile
+	if err := yaml.Unmarshal([]byte("seq:\n  rw: write\n  bs: 1m\nbuffered:\n  buffered: true\n"), &profiles); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"--rw=write", "--bs=1m", "--iodepth=16", "--numjobs=1",
+		"--ioengine=libaio", "--direct=1", "--verify=crc32", "--verify_fatal=1", "--verify_async=2"}
+	if args := profiles["seq"].Options(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("options %v, expected %v", args, expected)
+	}
+	expected = []string{"--rw=randrw", "--bs=4k", "--iodepth=16", "--numjobs=1",
+		"--ioengine=libaio", "--verify=crc32", "--verify_fatal=1", "--verify_async=2"}
+	if args := profiles["buffered"].Options(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("options %v, expected %v", args, expected)
+	}
+}
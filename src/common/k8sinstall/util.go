@@ -300,7 +300,7 @@ func InstallMayastor() error {
 	if len(e2eCfg.ImageTag) == 0 {
 		return fmt.Errorf("mayastor image tag not defined")
 	}
-	if len(e2eCfg.PoolDevice) == 0 {
+	if !k8stest.PoolsConfigured() {
 		return fmt.Errorf("configuration error pools are not defined.")
 	}
 
@@ -0,0 +1,240 @@
+package k8stest
+
+// Parsing of fio json output, and comparison with a baseline.
+// Results are written to <ReportsDir>/fio, one summary per fio run named
+// <test name>-<pod name>.json and the full fio output as <test name>-<pod name>.fio.json.
+// A directory of summaries from a previous run can be configured as the baseline
+// (FioResults.BaselineDir), performance worse than the baseline by more than
+// FioResults.TolerancePercent is flagged as a regression.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/e2e_config"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// FioLatency latency statistics in nanoseconds
+type FioLatency struct {
+	Min        float64            `json:"min"`
+	Max        float64            `json:"max"`
+	Mean       float64            `json:"mean"`
+	Percentile map[string]float64 `json:"percentile,omitempty"`
+}
+
+// FioIoStats statistics of one direction of IO of a fio job
+type FioIoStats struct {
+	IoBytes int64 `json:"io_bytes"`
+	// Bw bandwidth in KiB/s
+	Bw     int64      `json:"bw"`
+	Iops   float64    `json:"iops"`
+	ClatNs FioLatency `json:"clat_ns"`
+	LatNs  FioLatency `json:"lat_ns"`
+}
+
+// FioJobResult result of a fio job, a non zero Error includes verify errors
+type FioJobResult struct {
+	JobName string     `json:"jobname"`
+	Error   int        `json:"error"`
+	Read    FioIoStats `json:"read"`
+	Write   FioIoStats `json:"write"`
+}
+
+// FioResult fio json output
+type FioResult struct {
+	Version string         `json:"fio version"`
+	Jobs    []FioJobResult `json:"jobs"`
+}
+
+// FioSummary performance of all jobs of a fio run,
+// latencies are the completion latencies of the slowest job
+type FioSummary struct {
+	ReadIops      float64 `json:"readIops"`
+	WriteIops     float64 `json:"writeIops"`
+	ReadBwKiBs    int64   `json:"readBwKiBs"`
+	WriteBwKiBs   int64   `json:"writeBwKiBs"`
+	ReadLatP50Us  float64 `json:"readLatP50Us"`
+	ReadLatP99Us  float64 `json:"readLatP99Us"`
+	WriteLatP50Us float64 `json:"writeLatP50Us"`
+	WriteLatP99Us float64 `json:"writeLatP99Us"`
+	// Errors number of jobs which failed
+	Errors int `json:"errors"`
+}
+
+func (s FioSummary) String() string {
+	return fmt.Sprintf("read: iops=%.0f bw=%dKiB/s p50=%.0fus p99=%.0fus; write: iops=%.0f bw=%dKiB/s p50=%.0fus p99=%.0fus; errors=%d",
+		s.ReadIops, s.ReadBwKiBs, s.ReadLatP50Us, s.ReadLatP99Us,
+		s.WriteIops, s.WriteBwKiBs, s.WriteLatP50Us, s.WriteLatP99Us, s.Errors)
+}
+
+// ParseFioJson returns the last fio json result in output, output may contain other text
+// and multiple results if fio was run with --status-interval.
+func ParseFioJson(output string) (*FioResult, error) {
+	var last *FioResult
+	for ix := 0; ix < len(output); {
+		start := strings.Index(output[ix:], "{")
+		if start < 0 {
+			break
+		}
+		start += ix
+		// a json result starts at the beginning of a line
+		if start != 0 && output[start-1] != '\n' {
+			ix = start + 1
+			continue
+		}
+		var result FioResult
+		decoder := json.NewDecoder(strings.NewReader(output[start:]))
+		if err := decoder.Decode(&result); err == nil && len(result.Jobs) != 0 {
+			last = &result
+			ix = start + int(decoder.InputOffset())
+		} else {
+			ix = start + 1
+		}
+	}
+	if last == nil {
+		return nil, fmt.Errorf("no fio json result found")
+	}
+	return last, nil
+}
+
+func percentileUs(lat FioLatency, percentile string) float64 {
+	return lat.Percentile[percentile] / 1000
+}
+
+// Summary returns the summary of all jobs
+func (r *FioResult) Summary() FioSummary {
+	var s FioSummary
+	max := func(a *float64, b float64) {
+		if b > *a {
+			*a = b
+		}
+	}
+	for _, job := range r.Jobs {
+		if job.Error != 0 {
+			s.Errors++
+		}
+		s.ReadIops += job.Read.Iops
+		s.WriteIops += job.Write.Iops
+		s.ReadBwKiBs += job.Read.Bw
+		s.WriteBwKiBs += job.Write.Bw
+		max(&s.ReadLatP50Us, percentileUs(job.Read.ClatNs, "50.000000"))
+		max(&s.ReadLatP99Us, percentileUs(job.Read.ClatNs, "99.000000"))
+		max(&s.WriteLatP50Us, percentileUs(job.Write.ClatNs, "50.000000"))
+		max(&s.WriteLatP99Us, percentileUs(job.Write.ClatNs, "99.000000"))
+	}
+	return s
+}
+
+// CompareFioSummaries returns the regressions of summary with respect to baseline,
+// throughput lower or latency higher than the baseline by more than tolerancePercent.
+func CompareFioSummaries(summary FioSummary, baseline FioSummary, tolerancePercent int) []string {
+	var regressions []string
+	tolerance := float64(tolerancePercent) / 100
+	lower := func(name string, value float64, base float64) {
+		if base > 0 && value < base*(1-tolerance) {
+			regressions = append(regressions, fmt.Sprintf("%s %.0f is lower than baseline %.0f", name, value, base))
+		}
+	}
+	higher := func(name string, value float64, base float64) {
+		if base > 0 && value > base*(1+tolerance) {
+			regressions = append(regressions, fmt.Sprintf("%s %.0f is higher than baseline %.0f", name, value, base))
+		}
+	}
+	lower("read iops", summary.ReadIops, baseline.ReadIops)
+	lower("write iops", summary.WriteIops, baseline.WriteIops)
+	lower("read bandwidth KiB/s", float64(summary.ReadBwKiBs), float64(baseline.ReadBwKiBs))
+	lower("write bandwidth KiB/s", float64(summary.WriteBwKiBs), float64(baseline.WriteBwKiBs))
+	higher("read p99 latency us", summary.ReadLatP99Us, baseline.ReadLatP99Us)
+	higher("write p99 latency us", summary.WriteLatP99Us, baseline.WriteLatP99Us)
+	return regressions
+}
+
+// errFioRegression is wrapped by the errors returned for fio performance regressions
+var errFioRegression = errors.New("fio performance regression")
+
+// IsFioRegression returns true if the error returned by RecordFioResult is a performance regression
+func IsFioRegression(err error) bool {
+	return errors.Is(err, errFioRegression)
+}
+
+func fioResultName(podName string) string {
+	if testName == "" {
+		return podName
+	}
+	return testName + "-" + podName
+}
+
+// RecordFioResult writes the fio result of the pod podName to the reports directory
+// and compares it with the baseline. Returns an error describing the regressions
+// if FioResults.FailOnRegression is set, regressions are logged otherwise.
+func RecordFioResult(podName string, result *FioResult) error {
+	cfg := e2e_config.GetConfig()
+	name := fioResultName(podName)
+	summary := result.Summary()
+	logf.Log.Info("RecordFioResult", "name", name, "summary", summary.String())
+
+	if cfg.ReportsDir != "" {
+		dir := filepath.Join(cfg.ReportsDir, "fio")
+		if err := writeJsonFile(dir, name+".json", summary); err != nil {
+			logf.Log.Info("Failed to write fio summary", "name", name, "error", err)
+		}
+		if err := writeJsonFile(dir, name+".fio.json", result); err != nil {
+			logf.Log.Info("Failed to write fio result", "name", name, "error", err)
+		}
+	}
+
+	if cfg.FioResults.BaselineDir == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(cfg.FioResults.BaselineDir, name+".json"))
+	if err != nil {
+		logf.Log.Info("No fio baseline", "name", name, "error", err)
+		return nil
+	}
+	var baseline FioSummary
+	if err = json.Unmarshal(data, &baseline); err != nil {
+		return fmt.Errorf("invalid fio baseline for %s, error: %v", name, err)
+	}
+	regressions := CompareFioSummaries(summary, baseline, cfg.FioResults.TolerancePercent)
+	if len(regressions) == 0 {
+		return nil
+	}
+	logf.Log.Info("Fio performance regression", "name", name, "regressions", regressions)
+	if cfg.FioResults.FailOnRegression {
+		return fmt.Errorf("%w %s: %s", errFioRegression, name, strings.Join(regressions, "; "))
+	}
+	return nil
+}
+
+// RecordFioPodResult parses the fio json result in the log of the pod podName
+// in the default namespace and records it.
+func RecordFioPodResult(podName string) error {
+	log, err := GetPodLog(podName, common.NSDefault)
+	if err != nil {
+		return fmt.Errorf("failed to get log of pod %s, error: %v", podName, err)
+	}
+	result, err := ParseFioJson(log)
+	if err != nil {
+		return fmt.Errorf("pod %s: %v", podName, err)
+	}
+	return RecordFioResult(podName, result)
+}
+
+func writeJsonFile(dir string, fileName string, v interface{}) error {
+	if err := os.MkdirAll(dir, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fileName), data, 0644)
+}
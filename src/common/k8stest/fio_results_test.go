@@ -0,0 +1,97 @@
+package k8stest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fioJson = `{
+  "fio version" : "fio-3.25",
+  "timestamp" : 1634551200,
+  "global options" : {
+    "filename" : "/dev/sdm"
+  },
+  "jobs" : [
+    {
+      "jobname" : "benchtest",
+      "groupid" : 0,
+      "error" : 0,
+      "read" : {
+        "io_bytes" : 283901952,
+        "bw" : 9242,
+        "iops" : 2310.5,
+        "clat_ns" : {
+          "min" : 10000,
+          "max" : 9000000,
+          "mean" : 420000.0,
+          "percentile" : {
+            "50.000000" : 380928,
+            "99.000000" : 1335296
+          }
+        }
+      },
+      "write" : {
+        "io_bytes" : 283607040,
+        "bw" : 9233,
+        "iops" : 2308.2,
+        "clat_ns" : {
+          "min" : 20000,
+          "max" : 12000000,
+          "mean" : 480000.0,
+          "percentile" : {
+            "50.000000" : 440320,
+            "99.000000" : 1531904
+          }
+        }
+      }
+    }
+  ]
+}
+`
+
+func TestParseFioJson(t *testing.T) {
+	// status interval output followed by the final result
+	interim := strings.Replace(fioJson, `"iops" : 2310.5`, `"iops" : 1000.0`, 1)
+	output := "Unable to use a TTY - input is not a terminal or the right kind of file\n" + interim + fioJson
+	result, err := ParseFioJson(output)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Version != "fio-3.25" || len(result.Jobs) != 1 || result.Jobs[0].Read.Iops != 2310.5 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	expected := FioSummary{
+		ReadIops:      2310.5,
+		WriteIops:     2308.2,
+		ReadBwKiBs:    9242,
+		WriteBwKiBs:   9233,
+		ReadLatP50Us:  380.928,
+		ReadLatP99Us:  1335.296,
+		WriteLatP50Us: 440.32,
+		WriteLatP99Us: 1531.904,
+	}
+	if summary := result.Summary(); !reflect.DeepEqual(summary, expected) {
+		t.Errorf("summary %+v, expected %+v", summary, expected)
+	}
+	if _, err = ParseFioJson("benchtest: (groupid=0, jobs=1): err= 0: pid=12\n{ not json\n"); err == nil {
+		t.Errorf("expected error for output without json result")
+	}
+}
+
+func TestCompareFioSummaries(t *testing.T) {
+	baseline := FioSummary{ReadIops: 1000, WriteIops: 1000, ReadBwKiBs: 4000, WriteBwKiBs: 4000, ReadLatP99Us: 1000, WriteLatP99Us: 1000}
+	within := FioSummary{ReadIops: 850, WriteIops: 1200, ReadBwKiBs: 3400, WriteBwKiBs: 4000, ReadLatP99Us: 1150, WriteLatP99Us: 500}
+	if regressions := CompareFioSummaries(within, baseline, 20); len(regressions) != 0 {
+		t.Errorf("unexpected regressions %v", regressions)
+	}
+	worse := FioSummary{ReadIops: 700, WriteIops: 1000, ReadBwKiBs: 2800, WriteBwKiBs: 4000, ReadLatP99Us: 1000, WriteLatP99Us: 1300}
+	regressions := CompareFioSummaries(worse, baseline, 20)
+	if len(regressions) != 3 {
+		t.Errorf("expected 3 regressions, got %v", regressions)
+	}
+	// no baseline values, no regressions
+	if regressions = CompareFioSummaries(worse, FioSummary{}, 20); len(regressions) != 0 {
+		t.Errorf("unexpected regressions %v", regressions)
+	}
+}
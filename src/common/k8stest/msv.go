@@ -33,6 +33,14 @@ func EnsureNodeAddressesAreSet() {
 	})
 }
 
+// SetControlPlaneInterface replaces the control plane interface used by k8stest,
+// node address initialisation is skipped so no cluster is required.
+// For use by unit tests with a fake control plane.
+func SetControlPlaneInterface(cp controlplane.ControlPlaneInterface) {
+	once.Do(func() {})
+	controlplane.SetControlPlaneInterface(cp)
+}
+
 // GetMSV Get pointer to a mayastor volume custom resource
 // returns nil and no error if the msv is in pending state.
 func GetMSV(uuid string) (*common.MayastorVolume, error) {
@@ -102,3 +110,32 @@ func CheckAllMsvsAreHealthy() error {
 	EnsureNodeAddressesAreSet()
 	return controlplane.CheckAllMsvsAreHealthy()
 }
+
+// CreateMsv create a mayastor volume directly using the control plane,
+// bypassing PVC and CSI.
+func CreateMsv(uuid string, sizeBytes int64, replicaCount int) (*common.MayastorVolume, error) {
+	EnsureNodeAddressesAreSet()
+	return controlplane.CreateMsv(uuid, sizeBytes, replicaCount)
+}
+
+// PublishMsv publish a mayastor volume on a node using protocol,
+// if node is empty the control plane selects the node.
+func PublishMsv(uuid string, node string, protocol common.ShareProto) (string, error) {
+	EnsureNodeAddressesAreSet()
+	return controlplane.PublishMsv(uuid, node, protocol)
+}
+
+func UnpublishMsv(uuid string) error {
+	EnsureNodeAddressesAreSet()
+	return controlplane.UnpublishMsv(uuid)
+}
+
+func ShareMsv(uuid string, protocol common.ShareProto) (string, error) {
+	EnsureNodeAddressesAreSet()
+	return controlplane.ShareMsv(uuid, protocol)
+}
+
+func UnshareMsv(uuid string) error {
+	EnsureNodeAddressesAreSet()
+	return controlplane.UnshareMsv(uuid)
+}
@@ -0,0 +1,181 @@
+package k8stest
+
+import (
+	"fmt"
+	"testing"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/controlplane"
+	"mayastor-e2e/common/controlplane/fake"
+)
+
+var _ controlplane.ControlPlaneInterface = fake.NewControlPlane()
+
+func makeFakeMsv(uuid string, replicaCount int) common.MayastorVolume {
+	msv := common.MayastorVolume{
+		Name: uuid,
+		Spec: common.MayastorVolumeSpec{
+			Protocol:      string(common.ShareProtoNvmf),
+			ReplicaCount:  replicaCount,
+			RequiredBytes: 64 * 1024 * 1024,
+		},
+		Status: common.MayastorVolumeStatus{
+			Nexus: common.Nexus{
+				Node:  "node-0",
+				State: "Online",
+				Uuid:  uuid,
+			},
+			Size:  64 * 1024 * 1024,
+			State: "Online",
+		},
+	}
+	for ix := 0; ix < replicaCount; ix++ {
+		node := fmt.Sprintf("node-%d", ix)
+		uri := fmt.Sprintf("nvmf://%s/%s", node, uuid)
+		msv.Status.Replicas = append(msv.Status.Replicas, common.Replica{Node: node, Pool: "pool-" + node, Uri: uri})
+		msv.Status.Nexus.Children = append(msv.Status.Nexus.Children, common.NexusChild{State: "Online", Uri: uri})
+	}
+	return msv
+}
+
+func setupFakeControlPlane() *fake.ControlPlane {
+	cp := fake.NewControlPlane()
+	SetControlPlaneInterface(cp)
+	return cp
+}
+
+func TestCheckAllMsvsAreHealthy(t *testing.T) {
+	cp := setupFakeControlPlane()
+	cp.AddMsv(makeFakeMsv("vol-1", 2))
+	cp.AddMsv(makeFakeMsv("vol-2", 3))
+
+	if err := CheckAllMsvsAreHealthy(); err != nil {
+		t.Fatalf("expected all volumes to be healthy, got %v", err)
+	}
+	if err := cp.SetMsvState("vol-2", controlplane.VolStateDegraded()); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckAllMsvsAreHealthy(); err == nil {
+		t.Fatalf("expected an error for a degraded volume")
+	}
+}
+
+func TestGetMsvNodes(t *testing.T) {
+	cp := setupFakeControlPlane()
+	cp.AddMsv(makeFakeMsv("vol-1", 3))
+
+	nexusNode, replicaNodes := GetMsvNodes("vol-1")
+	if nexusNode != "node-0" {
+		t.Errorf("nexus node: expected node-0 got %s", nexusNode)
+	}
+	if len(replicaNodes) != 3 {
+		t.Errorf("replica nodes: expected 3 got %v", replicaNodes)
+	}
+}
+
+func TestMsvConsistencyCheck(t *testing.T) {
+	cp := setupFakeControlPlane()
+	// consistency checks are only implemented for control plane version 0
+	cp.Major = 0
+	cp.AddMsv(makeFakeMsv("vol-1", 2))
+
+	if err := MsvConsistencyCheck("vol-1"); err != nil {
+		t.Fatalf("expected consistent volume, got %v", err)
+	}
+	if err := cp.SetMsvReplicas("vol-1", makeFakeMsv("vol-1", 1).Status.Replicas); err != nil {
+		t.Fatal(err)
+	}
+	if err := MsvConsistencyCheck("vol-1"); err == nil {
+		t.Fatalf("expected replica count mismatch to be reported")
+	}
+}
+
+func TestMsvStateTransitions(t *testing.T) {
+	cp := setupFakeControlPlane()
+	cp.AddMsv(makeFakeMsv("vol-1", 2))
+	cp.QueueMsvTransitions("vol-1",
+		fake.MsvStateTransition("Degraded", "Degraded", []string{"Online", "Faulted"}),
+		fake.MsvStateTransition("Degraded", "Degraded", []string{"Online", "Degraded"}),
+		fake.MsvStateTransition("Online", "Online", []string{"Online", "Online"}),
+	)
+
+	var states []string
+	for ix := 0; ix < 4; ix++ {
+		state, err := GetMsvState("vol-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, state)
+	}
+	expected := []string{"Degraded", "Degraded", "Online", "Online"}
+	if fmt.Sprint(states) != fmt.Sprint(expected) {
+		t.Errorf("expected states %v got %v", expected, states)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	cp := setupFakeControlPlane()
+	cp.AddMsv(makeFakeMsv("vol-1", 1))
+	cp.InjectError("GetMsvNexusChildren", fake.ErrTimeout)
+
+	if _, err := GetMsvNexusChildren("vol-1"); err == nil {
+		t.Fatalf("expected injected error")
+	} else if !controlplane.IsTimeoutError(err) {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	children, err := GetMsvNexusChildren("vol-1")
+	if err != nil || len(children) != 1 {
+		t.Fatalf("expected 1 child and no error, got %v %v", children, err)
+	}
+	if cp.Calls("GetMsvNexusChildren") != 2 {
+		t.Errorf("expected 2 calls got %d", cp.Calls("GetMsvNexusChildren"))
+	}
+	if _, err := GetMsvState("vol-2"); err == nil {
+		t.Errorf("expected error for unknown volume")
+	}
+}
+
+func TestMsvLifecycle(t *testing.T) {
+	cp := setupFakeControlPlane()
+	for ix := 0; ix < 3; ix++ {
+		node := fmt.Sprintf("node-%d", ix)
+		cp.AddMsPool(common.MayastorPool{Name: "pool-" + node, Spec: common.MayastorPoolSpec{Node: node}})
+	}
+
+	if _, err := CreateMsv("vol-1", 64*1024*1024, 4); err == nil {
+		t.Fatalf("expected create with more replicas than pools to fail")
+	}
+	msv, err := CreateMsv("vol-1", 64*1024*1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msv.Status.Replicas) != 2 || IsMsvPublished("vol-1") {
+		t.Fatalf("expected unpublished volume with 2 replicas, got %v", msv)
+	}
+	deviceUri, err := PublishMsv("vol-1", "node-1", common.ShareProtoNvmf)
+	if err != nil || deviceUri == "" {
+		t.Fatalf("publish failed, uri=%s err=%v", deviceUri, err)
+	}
+	nexusNode, _ := GetMsvNodes("vol-1")
+	if nexusNode != "node-1" {
+		t.Errorf("expected nexus on node-1 got %s", nexusNode)
+	}
+	if err = UnshareMsv("vol-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ShareMsv("vol-1", common.ShareProtoNvmf); err != nil {
+		t.Fatal(err)
+	}
+	if err = UnpublishMsv("vol-1"); err != nil {
+		t.Fatal(err)
+	}
+	if IsMsvPublished("vol-1") {
+		t.Errorf("expected volume to be unpublished")
+	}
+	if _, err = ShareMsv("vol-1", common.ShareProtoNvmf); err == nil {
+		t.Errorf("expected share of unpublished volume to fail")
+	}
+	if err = DeleteMsv("vol-1"); err != nil || !IsMsvDeleted("vol-1") {
+		t.Errorf("expected volume to be deleted, err=%v", err)
+	}
+}
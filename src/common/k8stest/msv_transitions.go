@@ -0,0 +1,286 @@
+package k8stest
+
+// Declarative assertions on the sequence of states a volume goes through.
+// A test declares the expected sequence, for example
+//
+//   err := k8stest.ExpectMsv(uuid).
+//   	Initially(controlplane.VolStateHealthy()).
+//   	ThenState(controlplane.VolStateDegraded(), 30*time.Second).
+//   	ThenState(controlplane.VolStateHealthy(), 5*time.Minute).
+//   	Never("all children faulted", k8stest.AllMsvChildrenInState(controlplane.ChildStateFaulted())).
+//   	Verify()
+//
+// The sequence is evaluated against the events generated by WatchMsvs,
+// states between the expected steps are permitted. On failure the error
+// includes the timeline of observed states.
+// Note states which last less than the watch poll period may not be observed.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mayastor-e2e/common/controlplane"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// time allowed to reach a step if none is specified
+const defMsvStepTimeout = defTimeoutSecs * time.Second
+
+// time allowed to observe the initial state if no steps follow it
+var msvInitialTimeout = defMsvStepTimeout
+
+// MsvEventMatcher returns true if the event matches a condition
+type MsvEventMatcher func(ev controlplane.MsvEvent) bool
+
+type msvCondition struct {
+	description string
+	match       MsvEventMatcher
+}
+
+type msvStep struct {
+	msvCondition
+	within time.Duration
+}
+
+// MsvTransitions is the expected sequence of states of a volume
+type MsvTransitions struct {
+	uuid       string
+	initial    *msvCondition
+	steps      []msvStep
+	invariants []msvCondition
+}
+
+// ExpectMsv starts the declaration of the expected state transitions of a volume
+func ExpectMsv(uuid string) *MsvTransitions {
+	return &MsvTransitions{uuid: uuid}
+}
+
+// Initially the first observed state of the volume must match volState
+func (mt *MsvTransitions) Initially(volState string) *MsvTransitions {
+	mt.initial = &msvCondition{
+		description: fmt.Sprintf("volume state %s", volState),
+		match:       MsvStateIs(volState),
+	}
+	return mt
+}
+
+// Then the volume must reach a state matching match within the specified duration
+// of the previous step, a duration of 0 selects the default.
+func (mt *MsvTransitions) Then(description string, match MsvEventMatcher, within time.Duration) *MsvTransitions {
+	if within == 0 {
+		within = defMsvStepTimeout
+	}
+	mt.steps = append(mt.steps, msvStep{
+		msvCondition: msvCondition{description: description, match: match},
+		within:       within,
+	})
+	return mt
+}
+
+// ThenState the volume must reach volState within the specified duration of the previous step
+func (mt *MsvTransitions) ThenState(volState string, within time.Duration) *MsvTransitions {
+	return mt.Then(fmt.Sprintf("volume state %s", volState), MsvStateIs(volState), within)
+}
+
+// ThenNexusState the nexus must reach nexusState within the specified duration of the previous step
+func (mt *MsvTransitions) ThenNexusState(nexusState string, within time.Duration) *MsvTransitions {
+	return mt.Then(fmt.Sprintf("nexus state %s", nexusState), MsvNexusStateIs(nexusState), within)
+}
+
+// Never no observed state may match match
+func (mt *MsvTransitions) Never(description string, match MsvEventMatcher) *MsvTransitions {
+	mt.invariants = append(mt.invariants, msvCondition{description: description, match: match})
+	return mt
+}
+
+// String describes the expected sequence
+func (mt *MsvTransitions) String() string {
+	var parts []string
+	if mt.initial != nil {
+		parts = append(parts, mt.initial.description)
+	}
+	for _, step := range mt.steps {
+		parts = append(parts, fmt.Sprintf("%s within %v", step.description, step.within))
+	}
+	desc := strings.Join(parts, " -> ")
+	for _, inv := range mt.invariants {
+		desc += ", never " + inv.description
+	}
+	return desc
+}
+
+// MsvTransitionsWatch evaluates an expected sequence of volume states
+// against the observed states
+type MsvTransitionsWatch struct {
+	cancel context.CancelFunc
+	result chan error
+}
+
+// Start watching the volume, use this to start observing before
+// triggering the sequence, for example before injecting a fault.
+func (mt *MsvTransitions) Start() *MsvTransitionsWatch {
+	ctx, cancel := context.WithCancel(context.Background())
+	return mt.start(WatchMsvs(ctx), cancel)
+}
+
+func (mt *MsvTransitions) start(events <-chan controlplane.MsvEvent, cancel context.CancelFunc) *MsvTransitionsWatch {
+	w := &MsvTransitionsWatch{
+		cancel: cancel,
+		result: make(chan error, 1),
+	}
+	go func() {
+		err := mt.evaluate(events)
+		cancel()
+		w.result <- err
+	}()
+	return w
+}
+
+// Wait for the evaluation to complete, returns nil if the expected sequence was observed.
+func (w *MsvTransitionsWatch) Wait() error {
+	err := <-w.result
+	w.result <- err
+	return err
+}
+
+// Cancel stops the evaluation, Wait returns an error if the sequence was incomplete
+func (w *MsvTransitionsWatch) Cancel() {
+	w.cancel()
+}
+
+// Verify starts watching the volume and waits for the evaluation to complete
+func (mt *MsvTransitions) Verify() error {
+	return mt.Start().Wait()
+}
+
+func (mt *MsvTransitions) evaluate(events <-chan controlplane.MsvEvent) error {
+	var timeline []controlplane.MsvEvent
+	stepIx := 0
+	initialChecked := mt.initial == nil
+
+	failure := func(reason string) error {
+		var sb strings.Builder
+		_, _ = fmt.Fprintf(&sb, "volume %s: %s\nexpected: %s\ntimeline:", mt.uuid, reason, mt)
+		for _, ev := range timeline {
+			_, _ = fmt.Fprintf(&sb, "\n  %v", ev)
+		}
+		logf.Log.Info("Volume state transitions", "uuid", mt.uuid, "failure", reason, "timeline", timeline)
+		return fmt.Errorf("%s", sb.String())
+	}
+
+	if len(mt.steps) == 0 && mt.initial == nil {
+		return failure("no expected states")
+	}
+
+	// a deadline is always armed, so that the evaluation completes
+	// even if the volume is never observed
+	within := func() time.Duration {
+		if stepIx < len(mt.steps) {
+			return mt.steps[stepIx].within
+		}
+		return msvInitialTimeout
+	}
+	deadline := time.NewTimer(within())
+	defer deadline.Stop()
+	resetDeadline := func() {
+		if !deadline.Stop() {
+			<-deadline.C
+		}
+		deadline.Reset(within())
+	}
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return failure("watch stopped")
+			}
+			if ev.Uuid != mt.uuid {
+				continue
+			}
+			timeline = append(timeline, ev)
+			for _, inv := range mt.invariants {
+				if inv.match(ev) {
+					return failure("observed " + inv.description)
+				}
+			}
+			if !initialChecked {
+				initialChecked = true
+				if !mt.initial.match(ev) {
+					return failure("initial state is not " + mt.initial.description)
+				}
+				if len(mt.steps) == 0 {
+					return nil
+				}
+				continue
+			}
+			if mt.steps[stepIx].match(ev) {
+				logf.Log.Info("Volume reached expected state", "uuid", mt.uuid, "step", mt.steps[stepIx].description)
+				stepIx++
+				if stepIx == len(mt.steps) {
+					return nil
+				}
+				resetDeadline()
+			}
+		case <-deadline.C:
+			if stepIx == len(mt.steps) {
+				return failure(fmt.Sprintf("timed out after %v waiting for %s", msvInitialTimeout, mt.initial.description))
+			}
+			step := mt.steps[stepIx]
+			return failure(fmt.Sprintf("timed out after %v waiting for %s", step.within, step.description))
+		}
+	}
+}
+
+// == Matchers ======================
+
+// MsvStateIs matches events with volume state volState
+func MsvStateIs(volState string) MsvEventMatcher {
+	return func(ev controlplane.MsvEvent) bool {
+		return ev.Type != controlplane.EventDeleted && ev.State == volState
+	}
+}
+
+// MsvNexusStateIs matches events with nexus state nexusState
+func MsvNexusStateIs(nexusState string) MsvEventMatcher {
+	return func(ev controlplane.MsvEvent) bool {
+		return ev.Type != controlplane.EventDeleted && ev.NexusState == nexusState
+	}
+}
+
+// MsvDeleted matches the deletion of the volume
+func MsvDeleted() MsvEventMatcher {
+	return func(ev controlplane.MsvEvent) bool {
+		return ev.Type == controlplane.EventDeleted
+	}
+}
+
+// AllMsvChildrenInState matches events where the nexus has children and all are in childState
+func AllMsvChildrenInState(childState string) MsvEventMatcher {
+	return func(ev controlplane.MsvEvent) bool {
+		if len(ev.Children) == 0 {
+			return false
+		}
+		for _, child := range ev.Children {
+			if child.State != childState {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyMsvChildInState matches events where at least one nexus child is in childState
+func AnyMsvChildInState(childState string) MsvEventMatcher {
+	return func(ev controlplane.MsvEvent) bool {
+		for _, child := range ev.Children {
+			if child.State == childState {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,82 @@
+package k8stest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mayastor-e2e/common/controlplane"
+	"mayastor-e2e/common/controlplane/fake"
+)
+
+func startFakeMsvTransitions(mt *MsvTransitions) *MsvTransitionsWatch {
+	ctx, cancel := context.WithCancel(context.Background())
+	return mt.start(controlplane.WatchMsvs(ctx, 10*time.Millisecond), cancel)
+}
+
+func TestMsvTransitionsObserved(t *testing.T) {
+	cp := setupFakeControlPlane()
+	cp.AddMsv(makeFakeMsv("vol-1", 2))
+	cp.QueueMsvTransitions("vol-1",
+		fake.MsvStateTransition("Online", "Online", nil),
+		fake.MsvStateTransition("Degraded", "Degraded", []string{"Online", "Faulted"}),
+		fake.MsvStateTransition("Degraded", "Degraded", []string{"Online", "Degraded"}),
+		fake.MsvStateTransition("Online", "Online", []string{"Online", "Online"}),
+	)
+	err := startFakeMsvTransitions(ExpectMsv("vol-1").
+		Initially(controlplane.VolStateHealthy()).
+		ThenState(controlplane.VolStateDegraded(), time.Second).
+		ThenState(controlplane.VolStateHealthy(), time.Second).
+		Never("all children faulted", AllMsvChildrenInState(controlplane.ChildStateFaulted()))).
+		Wait()
+	if err != nil {
+		t.Fatalf("expected transitions to be observed, got %v", err)
+	}
+}
+
+func TestMsvTransitionsInvariant(t *testing.T) {
+	cp := setupFakeControlPlane()
+	cp.AddMsv(makeFakeMsv("vol-1", 2))
+	cp.QueueMsvTransitions("vol-1",
+		fake.MsvStateTransition("Online", "Online", nil),
+		fake.MsvStateTransition("Degraded", "Faulted", []string{"Faulted", "Faulted"}),
+		fake.MsvStateTransition("Online", "Online", []string{"Online", "Online"}),
+	)
+	err := startFakeMsvTransitions(ExpectMsv("vol-1").
+		ThenState(controlplane.VolStateDegraded(), time.Second).
+		ThenState(controlplane.VolStateHealthy(), time.Second).
+		Never("all children faulted", AllMsvChildrenInState(controlplane.ChildStateFaulted()))).
+		Wait()
+	if err == nil || !strings.Contains(err.Error(), "observed all children faulted") {
+		t.Fatalf("expected invariant violation, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "timeline:") {
+		t.Errorf("expected timeline in error, got %v", err)
+	}
+}
+
+func TestMsvTransitionsTimeout(t *testing.T) {
+	cp := setupFakeControlPlane()
+	cp.AddMsv(makeFakeMsv("vol-1", 2))
+	err := startFakeMsvTransitions(ExpectMsv("vol-1").
+		Initially(controlplane.VolStateHealthy()).
+		ThenState(controlplane.VolStateDegraded(), 100*time.Millisecond)).
+		Wait()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
+
+func TestMsvTransitionsInitialTimeout(t *testing.T) {
+	setupFakeControlPlane()
+	saved := msvInitialTimeout
+	msvInitialTimeout = 100 * time.Millisecond
+	defer func() { msvInitialTimeout = saved }()
+	err := startFakeMsvTransitions(ExpectMsv("vol-1").
+		Initially(controlplane.VolStateHealthy())).
+		Wait()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
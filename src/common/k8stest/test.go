@@ -41,8 +41,12 @@ var gTestEnv TestEnvironment
 
 var resourceCheckError error
 
+// testName name of the test, used to name result files
+var testName string
+
 // InitTesting initialise testing and setup class name + report filename.
 func InitTesting(t *testing.T, classname string, reportname string) {
+	testName = reportname
 	RegisterFailHandler(Fail)
 	fmt.Printf("Mayastor namespace is \"%s\"\n", common.NSMayastor())
 	reporters := reporter.GetReporters(reportname)
@@ -151,6 +155,7 @@ func SetupTestEnv() error {
 }
 
 func TeardownTestEnvNoCleanup() error {
+	mayastorclient.CloseConnections()
 	err := gTestEnv.TestEnv.Stop()
 	if err != nil {
 		return fmt.Errorf("failed to tear down test environment: Stop %v", err)
@@ -284,6 +289,7 @@ func resourceCheck(waitForPools bool) error {
 
 	// gRPC calls can only be executed successfully is the e2e-agent daemonSet has been deployed successfully.
 	if mayastorclient.CanConnect() {
+		orphansFound := false
 		// check pools
 		{
 			poolUsage, err := GetPoolUsageInCluster()
@@ -320,6 +326,7 @@ func resourceCheck(waitForPools bool) error {
 			if len(nexuses) != 0 {
 				errs.Accumulate(fmt.Errorf("gRPC: count of nexuses reported via mayastor client is %d", len(nexuses)))
 			}
+			orphansFound = len(nexuses) != 0
 		}
 		// check replicas
 		{
@@ -332,6 +339,20 @@ func resourceCheck(waitForPools bool) error {
 			if len(replicas) != 0 {
 				errs.Accumulate(fmt.Errorf("gRPC: count of replicas reported via mayastor client is %d", len(replicas)))
 			}
+			orphansFound = orphansFound || len(replicas) != 0
+		}
+		// classify remaining replicas and nexuses, and optionally remove the leaked ones
+		if orphansFound {
+			orphans, err := DetectOrphans(time.Duration(e2e_config.GetConfig().OrphanSettleSecs) * time.Second)
+			errs.Accumulate(err)
+			if len(orphans) != 0 {
+				errs.Accumulate(fmt.Errorf("gRPC: orphans %s", OrphansSummary(orphans)))
+			}
+			if e2e_config.GetConfig().ReapLeakedResources {
+				reaped, err := ReapLeakedOrphans(orphans)
+				logf.Log.Info("ResourceCheck:", "reaped leaked resources", reaped)
+				errs.Accumulate(err)
+			}
 		}
 		// check nvmeControllers
 		{
@@ -416,11 +437,18 @@ func BeforeEachCheck() error {
 func AfterEachCheck() error {
 	logf.Log.Info("AfterEachCheck")
 
+	if CurrentGinkgoTestDescription().Failed {
+		dumpSpdkStateOnFailure()
+	}
+
 	if e2e_config.GetConfig().FailQuick && resourceCheckError != nil {
 		return fmt.Errorf("prior ResourceCheck failed")
 	}
 
 	resourceCheckError = ResourceCheck()
+	if resourceCheckError == nil && e2e_config.GetConfig().FailOnInconsistency {
+		resourceCheckError = ClusterConsistencyReport().Err()
+	}
 	logf.Log.Info("AfterEachCheck", "error", resourceCheckError)
 
 	return resourceCheckError
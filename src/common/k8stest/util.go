@@ -617,22 +617,21 @@ func GetPoolUsageInCluster() (uint64, error) {
 // CreateConfiguredPools (re)create pools as defined by the configuration.
 // No check is made on the status of pools
 func CreateConfiguredPools() error {
-	if len(e2e_config.GetConfig().PoolDevice) == 0 {
-		return fmt.Errorf("pool device not configured, PoolDevice: %s", e2e_config.GetConfig().PoolDevice)
+	if e2e_config.GetConfig().PoolPlacement == mayastorclient.PoolPlacementDiscover {
+		waitForPoolsDestroyed(120)
 	}
-	disks := []string{e2e_config.GetConfig().PoolDevice}
-	// NO check is made on the status of pools
-	nodes, err := GetNodeLocs()
+	poolDisks, err := ConfiguredPoolDisks()
 	if err != nil {
-		return fmt.Errorf("failed to get list of nodes, error: %v", err)
+		return err
 	}
+	// NO check is made on the status of pools
 	var errs common.ErrorAccumulator
-	for _, node := range nodes {
-		if node.MayastorNode {
-			poolName := fmt.Sprintf("pool-on-%s", node.NodeName)
-			pool, err := custom_resources.CreateMsPool(poolName, node.NodeName, disks)
+	for nodeName, diskSets := range poolDisks {
+		for _, disks := range diskSets {
+			poolName := configuredPoolName(nodeName, disks)
+			pool, err := custom_resources.CreateMsPool(poolName, nodeName, disks)
 			if err != nil {
-				errs.Accumulate(fmt.Errorf("failed to create pool on %v , disks: %s, error: %v", node, disks, err))
+				errs.Accumulate(fmt.Errorf("failed to create pool on %s , disks: %s, error: %v", nodeName, disks, err))
 			}
 			logf.Log.Info("Created", "pool", pool)
 		}
@@ -0,0 +1,40 @@
+package k8stest
+
+import (
+	"fmt"
+
+	"mayastor-e2e/common/mayastorclient"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+)
+
+// getMsvNexus returns the ip address of the nexus node and the nexus of a published volume
+func getMsvNexus(uuid string) (string, *mayastorclient.MayastorNexus, error) {
+	nexusNode, _ := GetMsvNodes(uuid)
+	if nexusNode == "" {
+		return "", nil, fmt.Errorf("volume %s is not published", uuid)
+	}
+	address, err := GetNodeIPAddress(nexusNode)
+	if err != nil {
+		return "", nil, err
+	}
+	nexus, err := findNexusForVolume(uuid, *address)
+	return *address, nexus, err
+}
+
+// GetMsvNvmeAnaState returns the NVMe ANA state of the nexus of a published volume
+func GetMsvNvmeAnaState(uuid string) (mayastorGrpc.NvmeAnaState, error) {
+	address, nexus, err := getMsvNexus(uuid)
+	if err != nil {
+		return mayastorGrpc.NvmeAnaState_NVME_ANA_INVALID_STATE, err
+	}
+	return mayastorclient.GetNvmeAnaState(address, nexus.Uuid)
+}
+
+// SetMsvNvmeAnaState sets the NVMe ANA state of the nexus of a published volume
+func SetMsvNvmeAnaState(uuid string, anaState mayastorGrpc.NvmeAnaState) error {
+	address, nexus, err := getMsvNexus(uuid)
+	if err != nil {
+		return err
+	}
+	return mayastorclient.SetNvmeAnaState(address, nexus.Uuid, anaState)
+}
@@ -413,6 +413,11 @@ func CleanUp() bool {
 	podCount := 0
 	pvcCount := 0
 
+	// faults left by a failed test may prevent the removal of resources
+	if err := RevertAgentFaults(); err != nil {
+		errs = append(errs, err)
+	}
+
 	nameSpaces, err := gTestEnv.KubeInt.CoreV1().Namespaces().List(context.TODO(), metaV1.ListOptions{})
 	if err == nil {
 		for _, ns := range nameSpaces.Items {
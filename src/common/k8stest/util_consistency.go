@@ -0,0 +1,73 @@
+package k8stest
+
+import (
+	"mayastor-e2e/common/consistency"
+	"mayastor-e2e/common/controlplane"
+	"mayastor-e2e/common/custom_resources"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func listCrdPools() ([]consistency.Pool, error) {
+	crdPools, err := custom_resources.ListMsPools()
+	if err != nil {
+		return nil, err
+	}
+	return consistency.CrdPools(crdPools)
+}
+
+func listCpPools() ([]consistency.Pool, error) {
+	cpPools, err := controlplane.ListMsPools()
+	if err != nil {
+		return nil, err
+	}
+	var pools []consistency.Pool
+	for _, cpPool := range cpPools {
+		pools = append(pools, consistency.Pool{
+			Name:     cpPool.Name,
+			Node:     cpPool.Spec.Node,
+			State:    cpPool.Status.State,
+			Capacity: cpPool.Status.Capacity,
+		})
+	}
+	return pools, nil
+}
+
+func listNodeAddresses() (map[string]string, error) {
+	nodes, err := GetNodeLocs()
+	if err != nil {
+		return nil, err
+	}
+	addrs := make(map[string]string)
+	for _, node := range nodes {
+		if node.MayastorNode {
+			addrs[node.NodeName] = node.IPAddress
+		}
+	}
+	return addrs, nil
+}
+
+// ConsistencySources returns the sources of the custom resources and control plane state
+// for cross-layer consistency checks, volume custom resources are included
+// if they are served by the cluster.
+func ConsistencySources() consistency.Sources {
+	src := consistency.Sources{
+		CrdPools:      listCrdPools,
+		CpPools:       listCpPools,
+		CpVolumes:     controlplane.ListMsvs,
+		NodeAddresses: listNodeAddresses,
+	}
+	if custom_resources.MsVolsServed() {
+		src.CrdVolumes = custom_resources.ListMsVols
+	}
+	return src
+}
+
+// ClusterConsistencyReport cross-references the pools, volumes, replicas, nexuses and nexus children
+// of the custom resources, the control plane and mayastor, and reports all inconsistencies found.
+func ClusterConsistencyReport() *consistency.Report {
+	EnsureNodeAddressesAreSet()
+	report := consistency.ClusterReport(GetMayastorNodeIPAddresses(), ConsistencySources())
+	logf.Log.Info("ClusterConsistencyReport", "report", report.String())
+	return report
+}
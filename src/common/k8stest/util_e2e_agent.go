@@ -2,12 +2,18 @@ package k8stest
 
 import (
 	"context"
+	"fmt"
 	"mayastor-e2e/common"
+	agent "mayastor-e2e/common/e2e-agent"
 	"mayastor-e2e/common/locations"
 	"time"
 
 	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 func e2eReadyPodCount() int {
@@ -18,6 +24,42 @@ func e2eReadyPodCount() int {
 	return int(daemonSet.Status.NumberAvailable)
 }
 
+// ensureE2EAgentSecret creates or updates the secret used to authenticate requests to
+// the e2e agent from the secret of the e2e agent client. Returns true if the secret was changed.
+func ensureE2EAgentSecret() (bool, error) {
+	const secretName = "e2e-agent-auth"
+	secretValue := agent.AuthSecret()
+	secrets := gTestEnv.KubeInt.CoreV1().Secrets(common.NSE2EAgent)
+	secret, err := secrets.Get(context.TODO(), secretName, metaV1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get e2e agent secret, error: %v", err)
+	}
+	if secretValue == "" {
+		if err == nil {
+			logf.Log.Info("e2e agent secret exists but is not set, requests to the e2e agent will fail", "env", agent.AuthSecretEnv)
+		}
+		return false, nil
+	}
+	if err != nil {
+		secret = &coreV1.Secret{
+			ObjectMeta: metaV1.ObjectMeta{Name: secretName, Namespace: common.NSE2EAgent},
+			StringData: map[string]string{"secret": secretValue},
+		}
+		if _, err = secrets.Create(context.TODO(), secret, metaV1.CreateOptions{}); err != nil {
+			return false, fmt.Errorf("failed to create e2e agent secret, error: %v", err)
+		}
+		return true, nil
+	}
+	if string(secret.Data["secret"]) == secretValue {
+		return false, nil
+	}
+	secret.Data = map[string][]byte{"secret": []byte(secretValue)}
+	if _, err = secrets.Update(context.TODO(), secret, metaV1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("failed to update e2e agent secret, error: %v", err)
+	}
+	return true, nil
+}
+
 // EnsureE2EAgent ensure that e2e agent daemonSet is running, if already deployed
 // does nothing, otherwise creates the e2e agent namespace and deploys the daemonSet.
 // asserts if creating the namespace fails. This function can be called repeatedly.
@@ -30,6 +72,18 @@ func EnsureE2EAgent() (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	secretChanged, err := ensureE2EAgentSecret()
+	if err != nil {
+		return false, err
+	}
+	if secretChanged {
+		// restart the agent pods to pick up the secret
+		err = gTestEnv.KubeInt.CoreV1().Pods(common.NSE2EAgent).DeleteCollection(context.TODO(),
+			metaV1.DeleteOptions{}, metaV1.ListOptions{LabelSelector: "app=e2e-rest-agent"})
+		if err != nil {
+			return false, fmt.Errorf("failed to restart e2e agent pods, error: %v", err)
+		}
+	}
 
 	nodes, _ := GetNodeLocs()
 	instances := 0
@@ -39,7 +93,7 @@ func EnsureE2EAgent() (bool, error) {
 		}
 	}
 
-	if e2eReadyPodCount() == instances {
+	if !secretChanged && e2eReadyPodCount() == instances {
 		return true, nil
 	}
 
@@ -54,3 +108,33 @@ func EnsureE2EAgent() (bool, error) {
 	}
 	return ready, nil
 }
+
+// RevertAgentFaults reverts the faults applied by the e2e agent on every node,
+// does nothing if the e2e agent is not deployed.
+func RevertAgentFaults() error {
+	pods, err := gTestEnv.KubeInt.CoreV1().Pods(common.NSE2EAgent).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list e2e agent pods, error: %v", err)
+	}
+	var errs common.ErrorAccumulator
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != coreV1.PodRunning {
+			continue
+		}
+		// the e2e agent uses the host network
+		addr := pod.Status.PodIP
+		faults, err := agent.ListFaults(addr)
+		if err != nil {
+			errs.Accumulate(fmt.Errorf("failed to list faults on node %s, error: %v", pod.Spec.NodeName, err))
+			continue
+		}
+		if len(faults) == 0 {
+			continue
+		}
+		logf.Log.Info("Reverting e2e agent faults", "node", pod.Spec.NodeName, "faults", faults)
+		if err = agent.RevertFaults(addr); err != nil {
+			errs.Accumulate(fmt.Errorf("failed to revert faults on node %s, error: %v", pod.Spec.NodeName, err))
+		}
+	}
+	return errs.GetError()
+}
@@ -0,0 +1,283 @@
+package k8stest
+
+// Detection of replicas and nexuses which are not owned by a mayastor volume.
+// Each orphan is classified so that a failing resource check identifies the likely cause:
+// resources which disappear or acquire an owner within the settle period, or which
+// belong to a mayastor persistent volume, are in flight; resources on a node on which
+// mayastor has restarted are left over from a crash; all others were leaked when
+// their volume was deleted. Only leaked resources are reaped.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/consistency"
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/mayastorclient"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type OrphanClass string
+
+const (
+	// OrphanInFlight the resource belongs to a volume which is being created or deleted
+	OrphanInFlight OrphanClass = "InFlight"
+	// OrphanCrashLeftover the resource is on a node on which mayastor has restarted
+	OrphanCrashLeftover OrphanClass = "CrashLeftover"
+	// OrphanLeaked the resource remained after its volume was deleted
+	OrphanLeaked OrphanClass = "Leaked"
+)
+
+const (
+	OrphanReplica = "replica"
+	OrphanNexus   = "nexus"
+)
+
+// Orphan a replica or nexus which is not owned by a mayastor volume
+type Orphan struct {
+	Resource string
+	Address  string
+	Node     string
+	Name     string
+	Uuid     string
+	// Pool of a replica
+	Pool string
+	// Nexus which has the replica as a child
+	Nexus  string
+	Class  OrphanClass
+	Reason string
+}
+
+func (o Orphan) String() string {
+	return fmt.Sprintf("%s %s %s on %s (%s)", o.Class, o.Resource, o.Name, o.Node, o.Reason)
+}
+
+func (o Orphan) key() string {
+	return o.Resource + "/" + o.Address + "/" + o.Uuid + "/" + o.Name
+}
+
+// listUnownedResources lists the replicas and nexuses in the cluster which are not
+// owned by a mayastor volume.
+func listUnownedResources() ([]Orphan, error) {
+	msvs, err := ListMsvs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MSVs, error: %v", err)
+	}
+	owned := make(map[string]bool)
+	for _, msv := range msvs {
+		owned[msv.Name] = true
+		if msv.Status.Nexus.Uuid != "" {
+			owned[msv.Status.Nexus.Uuid] = true
+		}
+		for _, replica := range msv.Status.Replicas {
+			owned[consistency.ResourceKey(replica.Uri)] = true
+		}
+		for _, child := range msv.Status.Nexus.Children {
+			owned[consistency.ResourceKey(child.Uri)] = true
+		}
+	}
+	nodes, err := GetNodeLocs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list of nodes, error: %v", err)
+	}
+
+	var orphans []Orphan
+	var errs common.ErrorAccumulator
+	childOf := make(map[string]string)
+	for _, node := range nodes {
+		if !node.MayastorNode {
+			continue
+		}
+		nexuses, err := mayastorclient.ListNexuses([]string{node.IPAddress})
+		if err != nil {
+			errs.Accumulate(fmt.Errorf("failed to list nexuses on %s, error: %v", node.NodeName, err))
+		}
+		for _, nexus := range nexuses {
+			for _, child := range nexus.Children {
+				childOf[consistency.ResourceKey(child.Uri)] = nexus.Uuid
+			}
+			if owned[nexus.Uuid] || owned[nexus.Name] {
+				continue
+			}
+			orphans = append(orphans, Orphan{
+				Resource: OrphanNexus,
+				Address:  node.IPAddress,
+				Node:     node.NodeName,
+				Name:     nexus.Name,
+				Uuid:     nexus.Uuid,
+			})
+		}
+		replicas, err := mayastorclient.ListReplicas([]string{node.IPAddress})
+		if err != nil {
+			errs.Accumulate(fmt.Errorf("failed to list replicas on %s, error: %v", node.NodeName, err))
+		}
+		for _, replica := range replicas {
+			name := replica.Name
+			// a snapshot is owned by the owner of its source replica
+			if source, _, ok := mayastorclient.ParseSnapshotName(name); ok {
+				name = source
+			}
+			if owned[name] || owned[replica.Uuid] {
+				continue
+			}
+			orphans = append(orphans, Orphan{
+				Resource: OrphanReplica,
+				Address:  node.IPAddress,
+				Node:     node.NodeName,
+				Name:     replica.Name,
+				Uuid:     replica.Uuid,
+				Pool:     replica.Pool,
+			})
+		}
+	}
+	for ix, orphan := range orphans {
+		if orphan.Resource == OrphanReplica {
+			if nexus, ok := childOf[orphan.Name]; ok {
+				orphans[ix].Nexus = nexus
+			} else if nexus, ok = childOf[orphan.Uuid]; ok {
+				orphans[ix].Nexus = nexus
+			}
+		}
+	}
+	return orphans, errs.GetError()
+}
+
+// mayastorPvVolumes returns the volume handles of the persistent volumes provisioned by mayastor
+func mayastorPvVolumes() (map[string]bool, error) {
+	volumes := make(map[string]bool)
+	pvs, err := gTestEnv.KubeInt.CoreV1().PersistentVolumes().List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return volumes, err
+	}
+	provisioner := e2e_config.GetConfig().Product.CsiProvisioner
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == provisioner {
+			volumes[pv.Spec.CSI.VolumeHandle] = true
+		}
+	}
+	return volumes, nil
+}
+
+// mayastorRestartedNodes returns the names of the nodes on which the mayastor container has restarted
+func mayastorRestartedNodes() (map[string]bool, error) {
+	nodes := make(map[string]bool)
+	pods, err := gTestEnv.KubeInt.CoreV1().Pods(common.NSMayastor()).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return nodes, err
+	}
+	containerName := e2e_config.GetConfig().Product.ContainerName
+	for _, pod := range pods.Items {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.Name == containerName && containerStatus.RestartCount != 0 {
+				nodes[pod.Spec.NodeName] = true
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// classifyOrphans classifies orphans which remain after the settle period,
+// resolved holds the keys of orphans which were not present after the settle period.
+func classifyOrphans(orphans []Orphan, resolved map[string]bool, pvVolumes map[string]bool, restartedNodes map[string]bool) {
+	for ix := range orphans {
+		orphan := &orphans[ix]
+		switch {
+		case resolved[orphan.key()]:
+			orphan.Class = OrphanInFlight
+			orphan.Reason = "removed or owned within the settle period"
+		case orphan.Resource == OrphanNexus && pvVolumes[orphan.Uuid]:
+			orphan.Class = OrphanInFlight
+			orphan.Reason = "persistent volume exists"
+		case orphan.Resource == OrphanReplica && orphan.Nexus != "" && pvVolumes[orphan.Nexus]:
+			orphan.Class = OrphanInFlight
+			orphan.Reason = fmt.Sprintf("child of nexus %s, persistent volume exists", orphan.Nexus)
+		case restartedNodes[orphan.Node]:
+			orphan.Class = OrphanCrashLeftover
+			orphan.Reason = fmt.Sprintf("%s restarted on node", e2e_config.GetConfig().Product.ContainerName)
+		default:
+			orphan.Class = OrphanLeaked
+			orphan.Reason = "no owning volume"
+			if orphan.Nexus != "" {
+				orphan.Reason = fmt.Sprintf("no owning volume, child of nexus %s", orphan.Nexus)
+			}
+		}
+	}
+}
+
+// DetectOrphans returns the replicas and nexuses in the cluster which are not owned by a
+// mayastor volume, classified. Orphans are listed twice, settle apart, to identify resources
+// which are in flight.
+func DetectOrphans(settle time.Duration) ([]Orphan, error) {
+	// orphans are classified even if listing failed on some nodes
+	orphans, listErr := listUnownedResources()
+	if len(orphans) == 0 {
+		return orphans, listErr
+	}
+	resolved := make(map[string]bool)
+	if settle > 0 {
+		time.Sleep(settle)
+		// an incomplete listing cannot show that an orphan was resolved
+		if remaining, err := listUnownedResources(); err != nil {
+			listErr = err
+		} else {
+			for _, orphan := range orphans {
+				resolved[orphan.key()] = true
+			}
+			for _, orphan := range remaining {
+				delete(resolved, orphan.key())
+			}
+		}
+	}
+	pvVolumes, err := mayastorPvVolumes()
+	if err != nil {
+		return orphans, fmt.Errorf("failed to list persistent volumes, error: %v", err)
+	}
+	restartedNodes, err := mayastorRestartedNodes()
+	if err != nil {
+		return orphans, fmt.Errorf("failed to list %s pods, error: %v", common.NSMayastor(), err)
+	}
+	classifyOrphans(orphans, resolved, pvVolumes, restartedNodes)
+	logf.Log.Info("DetectOrphans", "orphans", orphans, "error", listErr)
+	return orphans, listErr
+}
+
+// OrphansSummary returns a one line description of orphans
+func OrphansSummary(orphans []Orphan) string {
+	var descs []string
+	for _, orphan := range orphans {
+		descs = append(descs, orphan.String())
+	}
+	return strings.Join(descs, "; ")
+}
+
+// ReapLeakedOrphans removes the orphans which are classified as leaked,
+// nexuses are destroyed before replicas. Returns the number of resources removed.
+func ReapLeakedOrphans(orphans []Orphan) (int, error) {
+	var errs common.ErrorAccumulator
+	reaped := 0
+	for _, resource := range []string{OrphanNexus, OrphanReplica} {
+		for _, orphan := range orphans {
+			if orphan.Class != OrphanLeaked || orphan.Resource != resource {
+				continue
+			}
+			var err error
+			if resource == OrphanNexus {
+				err = mayastorclient.DestroyNexus(orphan.Address, orphan.Uuid)
+			} else {
+				err = mayastorclient.RmListedReplica(orphan.Address, mayastorclient.MayastorReplica{Name: orphan.Name, Uuid: orphan.Uuid})
+			}
+			if err != nil {
+				errs.Accumulate(fmt.Errorf("failed to remove %s %s on %s, error: %v", resource, orphan.Name, orphan.Node, err))
+				continue
+			}
+			logf.Log.Info("ReapLeakedOrphans", "removed", orphan)
+			reaped++
+		}
+	}
+	return reaped, errs.GetError()
+}
@@ -0,0 +1,38 @@
+package k8stest
+
+import (
+	"testing"
+)
+
+func TestClassifyOrphans(t *testing.T) {
+	orphans := []Orphan{
+		{Resource: OrphanReplica, Address: "10.1.0.2", Node: "node-1", Name: "r-resolved", Uuid: "r-resolved"},
+		{Resource: OrphanNexus, Address: "10.1.0.2", Node: "node-1", Name: "vol-1", Uuid: "vol-1"},
+		{Resource: OrphanReplica, Address: "10.1.0.3", Node: "node-2", Name: "r-child", Uuid: "r-child", Nexus: "vol-1"},
+		{Resource: OrphanReplica, Address: "10.1.0.3", Node: "node-2", Name: "r-restarted", Uuid: "r-restarted"},
+		{Resource: OrphanNexus, Address: "10.1.0.4", Node: "node-3", Name: "vol-2", Uuid: "vol-2"},
+		{Resource: OrphanReplica, Address: "10.1.0.4", Node: "node-3", Name: "r-leaked", Uuid: "r-leaked", Nexus: "vol-2"},
+	}
+	resolved := map[string]bool{orphans[0].key(): true}
+	pvVolumes := map[string]bool{"vol-1": true}
+	restartedNodes := map[string]bool{"node-2": true}
+
+	classifyOrphans(orphans, resolved, pvVolumes, restartedNodes)
+
+	expected := []OrphanClass{
+		OrphanInFlight,
+		OrphanInFlight,
+		OrphanInFlight,
+		OrphanCrashLeftover,
+		OrphanLeaked,
+		OrphanLeaked,
+	}
+	for ix, orphan := range orphans {
+		if orphan.Class != expected[ix] {
+			t.Errorf("%s %s classified %s, expected %s", orphan.Resource, orphan.Name, orphan.Class, expected[ix])
+		}
+		if orphan.Reason == "" {
+			t.Errorf("%s %s has no reason", orphan.Resource, orphan.Name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package k8stest
+
+// Pools as defined by the configuration, see mayastorclient.DiscoverPoolDevices
+// for the selection of devices with PoolPlacement "discover".
+// Pools are named after the node and, for discovered devices, the device, so that the
+// names do not change when devices are discovered again after pools are deleted.
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/mayastorclient"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PoolsConfigured returns true if the configuration defines the devices for pools
+func PoolsConfigured() bool {
+	cfg := e2e_config.GetConfig()
+	return len(cfg.PoolDevice) != 0 || cfg.PoolPlacement == mayastorclient.PoolPlacementDiscover
+}
+
+// ConfiguredPoolDisks returns the disks for the pools on each mayastor node as defined
+// by the configuration, keyed by node name, each entry is the disk list for one pool.
+func ConfiguredPoolDisks() (map[string][][]string, error) {
+	cfg := e2e_config.GetConfig()
+	nodes, err := GetNodeLocs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list of nodes, error: %v", err)
+	}
+	poolDisks := make(map[string][][]string)
+	switch cfg.PoolPlacement {
+	case mayastorclient.PoolPlacementDevice, "":
+		if len(cfg.PoolDevice) == 0 {
+			return nil, fmt.Errorf("pool device not configured, PoolDevice: %s", cfg.PoolDevice)
+		}
+		for _, node := range nodes {
+			if node.MayastorNode {
+				poolDisks[node.NodeName] = [][]string{{cfg.PoolDevice}}
+			}
+		}
+	case mayastorclient.PoolPlacementDiscover:
+		for _, node := range nodes {
+			if !node.MayastorNode {
+				continue
+			}
+			devices, err := mayastorclient.DiscoverPoolDevices(node.IPAddress)
+			if err != nil {
+				return nil, err
+			}
+			if len(devices) == 0 {
+				return nil, fmt.Errorf("no devices for pools found on node %s", node.NodeName)
+			}
+			for _, device := range devices {
+				poolDisks[node.NodeName] = append(poolDisks[node.NodeName], []string{device})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported pool placement %s", cfg.PoolPlacement)
+	}
+	return poolDisks, nil
+}
+
+// configuredPoolName returns the name of the pool on disks on a node,
+// discovered devices may be in use by other pools on the node, so the device name
+// rather than its position in the list of discovered devices is part of the name.
+func configuredPoolName(nodeName string, disks []string) string {
+	if e2e_config.GetConfig().PoolPlacement != mayastorclient.PoolPlacementDiscover || len(disks) == 0 {
+		return fmt.Sprintf("pool-on-%s", nodeName)
+	}
+	device := strings.ToLower(filepath.Base(disks[0]))
+	device = invalidPoolNameChars.ReplaceAllString(device, "-")
+	return fmt.Sprintf("pool-on-%s-%s", nodeName, device)
+}
+
+// invalidPoolNameChars matches the characters which are not valid in a custom resource name
+var invalidPoolNameChars = regexp.MustCompile("[^a-z0-9.-]")
+
+// waitForPoolsDestroyed waits for mayastor to destroy the pools of deleted pool custom resources,
+// pool devices are discovered after pools are deleted and the devices of pools which
+// have not been destroyed yet are in use, so would not be discovered.
+func waitForPoolsDestroyed(timeoutSecs int) {
+	const sleepTime = 5
+	for ix := 0; ; ix += sleepTime {
+		pools, err := mayastorclient.ListPools(GetMayastorNodeIPAddresses())
+		if err == nil && len(pools) == 0 {
+			return
+		}
+		if ix >= timeoutSecs {
+			logf.Log.Info("Pools were not destroyed, their devices are not discovered", "pools", pools, "error", err)
+			return
+		}
+		time.Sleep(sleepTime * time.Second)
+	}
+}
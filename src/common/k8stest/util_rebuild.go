@@ -0,0 +1,235 @@
+package k8stest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"mayastor-e2e/common/controlplane"
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/mayastorclient"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const rebuildPollPeriod = 1 * time.Second
+
+// RebuildSample rebuild progress at a point in time
+type RebuildSample struct {
+	Elapsed         time.Duration `json:"elapsed"`
+	Progress        uint64        `json:"progress"`
+	BlocksRecovered uint64        `json:"blocks_recovered"`
+}
+
+// RebuildReport summary of a nexus child rebuild
+type RebuildReport struct {
+	Uuid      string    `json:"uuid"`
+	NexusNode string    `json:"nexus_node"`
+	ChildUri  string    `json:"child_uri"`
+	Start     time.Time `json:"start"`
+	// Duration is measured from when the rebuild was first observed,
+	// so is accurate to within rebuildPollPeriod
+	Duration   time.Duration `json:"duration"`
+	BytesTotal uint64        `json:"bytes_total"`
+	// Throughput in bytes per second
+	Throughput float64         `json:"throughput"`
+	Samples    []RebuildSample `json:"samples"`
+}
+
+func (rr RebuildReport) String() string {
+	return fmt.Sprintf("volume %s child %s rebuilt %d bytes in %v, %.2f MiB/s",
+		rr.Uuid, rr.ChildUri, rr.BytesTotal, rr.Duration, rr.Throughput/(1024*1024))
+}
+
+// findNexusForVolume returns the nexus for a volume on the node with ip address address,
+// depending on the control plane version the nexus uuid or name is the volume uuid.
+func findNexusForVolume(uuid string, address string) (*mayastorclient.MayastorNexus, error) {
+	nexuses, err := mayastorclient.ListNexuses([]string{address})
+	if err != nil {
+		return nil, err
+	}
+	for _, nexus := range nexuses {
+		if nexus.Uuid == uuid || nexus.Name == uuid {
+			return &nexus, nil
+		}
+	}
+	return nil, fmt.Errorf("nexus for volume %s not found on %s", uuid, address)
+}
+
+func rebuildingChild(nexus *mayastorclient.MayastorNexus) string {
+	for _, child := range nexus.Children {
+		if child.State == mayastorGrpc.ChildState_CHILD_DEGRADED {
+			return child.Uri
+		}
+	}
+	return ""
+}
+
+func childState(nexus *mayastorclient.MayastorNexus, uri string) mayastorGrpc.ChildState {
+	for _, child := range nexus.Children {
+		if child.Uri == uri {
+			return child.State
+		}
+	}
+	return mayastorGrpc.ChildState_CHILD_UNKNOWN
+}
+
+// RebuildTarget identifies a nexus child which is being rebuilt
+type RebuildTarget struct {
+	NexusNode    string
+	NexusAddress string
+	NexusUuid    string
+	ChildUri     string
+}
+
+// Rebuilding returns true if the target child is still being rebuilt,
+// the rebuild job of a child is removed when the rebuild completes.
+func (rt *RebuildTarget) Rebuilding() (bool, error) {
+	nexus, err := findNexusForVolume(rt.NexusUuid, rt.NexusAddress)
+	if err != nil {
+		return false, err
+	}
+	return childState(nexus, rt.ChildUri) == mayastorGrpc.ChildState_CHILD_DEGRADED, nil
+}
+
+// WaitForRebuildStart waits for a rebuild of a nexus child of the volume to start,
+// and returns the nexus and child being rebuilt.
+func WaitForRebuildStart(uuid string, timeoutSecs int) (*RebuildTarget, error) {
+	nexusNode, _ := GetMsvNodes(uuid)
+	if nexusNode == "" {
+		return nil, fmt.Errorf("volume %s is not published", uuid)
+	}
+	address, err := GetNodeIPAddress(nexusNode)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(time.Duration(timeoutSecs) * time.Second)
+	for {
+		nexus, err := findNexusForVolume(uuid, *address)
+		if err == nil {
+			if childUri := rebuildingChild(nexus); childUri != "" {
+				return &RebuildTarget{
+					NexusNode:    nexusNode,
+					NexusAddress: *address,
+					NexusUuid:    nexus.Uuid,
+					ChildUri:     childUri,
+				}, nil
+			}
+		} else {
+			logf.Log.Info("WaitForRebuildStart", "error", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for a rebuild of volume %s to start", uuid)
+		}
+		time.Sleep(rebuildPollPeriod)
+	}
+}
+
+func allChildrenOnline(nexus *mayastorclient.MayastorNexus) bool {
+	for _, child := range nexus.Children {
+		if child.State != mayastorGrpc.ChildState_CHILD_ONLINE {
+			return false
+		}
+	}
+	return true
+}
+
+func msvHealthy(uuid string) bool {
+	msv, err := GetMSV(uuid)
+	return err == nil && msv != nil && msv.Status.State == controlplane.VolStateHealthy()
+}
+
+// WaitForRebuild waits for all nexus children of the volume to be online and the volume
+// to be healthy, and returns a report of the progress, duration and throughput of the rebuild.
+// A rebuild which completes before it is observed is not an error, the report then
+// has no child, duration or samples.
+// The report is written to the reports directory if one is configured.
+func WaitForRebuild(uuid string) (*RebuildReport, error) {
+	nexusNode, _ := GetMsvNodes(uuid)
+	if nexusNode == "" {
+		return nil, fmt.Errorf("volume %s is not published", uuid)
+	}
+	address, err := GetNodeIPAddress(nexusNode)
+	if err != nil {
+		return nil, err
+	}
+	report := RebuildReport{
+		Uuid:      uuid,
+		NexusNode: nexusNode,
+	}
+
+	var stats *mayastorclient.RebuildStats
+	// time allowed for the rebuild to start and to complete
+	deadline := time.Now().Add(2 * defTimeoutSecs * time.Second)
+	for {
+		nexus, err := findNexusForVolume(uuid, *address)
+		if err != nil {
+			logf.Log.Info("WaitForRebuild", "error", err)
+		} else {
+			if report.ChildUri == "" {
+				if childUri := rebuildingChild(nexus); childUri != "" {
+					report.ChildUri = childUri
+					report.Start = time.Now()
+					logf.Log.Info("Rebuild started", "uuid", uuid, "child", report.ChildUri)
+				}
+			}
+			if report.ChildUri != "" {
+				state := childState(nexus, report.ChildUri)
+				if state != mayastorGrpc.ChildState_CHILD_ONLINE && state != mayastorGrpc.ChildState_CHILD_DEGRADED {
+					return &report, fmt.Errorf("rebuild of volume %s child %s failed, child state is %v", uuid, report.ChildUri, state)
+				}
+				// the rebuild job is removed on completion, so errors are expected
+				// if the rebuild completes between listing the nexus and getting the stats
+				if state == mayastorGrpc.ChildState_CHILD_DEGRADED {
+					if latest, err := mayastorclient.GetRebuildStats(*address, nexus.Uuid, report.ChildUri); err == nil {
+						stats = latest
+						sample := RebuildSample{
+							Elapsed:         time.Since(report.Start),
+							Progress:        stats.Progress,
+							BlocksRecovered: stats.BlocksRecovered,
+						}
+						report.Samples = append(report.Samples, sample)
+						logf.Log.Info("Rebuild progress", "uuid", uuid, "elapsed", sample.Elapsed, "progress", sample.Progress)
+					}
+				}
+			}
+			if allChildrenOnline(nexus) && msvHealthy(uuid) {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			return &report, fmt.Errorf("timed out waiting for rebuild of volume %s to complete", uuid)
+		}
+		time.Sleep(rebuildPollPeriod)
+	}
+	if report.ChildUri == "" {
+		logf.Log.Info("Volume is healthy, no rebuild was observed", "uuid", uuid)
+		return &report, nil
+	}
+	report.Duration = time.Since(report.Start)
+	if stats != nil {
+		report.BytesTotal = stats.BlocksTotal * stats.BlockSize
+		report.Throughput = float64(report.BytesTotal) / report.Duration.Seconds()
+	}
+	logf.Log.Info("Rebuild complete", "report", report.String())
+	writeRebuildReport(report)
+	return &report, nil
+}
+
+func writeRebuildReport(report RebuildReport) {
+	reportsDir := e2e_config.GetConfig().ReportsDir
+	if reportsDir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		fileName := filepath.Join(reportsDir, fmt.Sprintf("rebuild-%s.json", report.Uuid))
+		err = ioutil.WriteFile(fileName, data, 0644)
+	}
+	if err != nil {
+		logf.Log.Info("Failed to write rebuild report", "error", err)
+	}
+}
@@ -34,13 +34,12 @@ func ChecksumReplica(initiatorIP, targetIP, uri string, maxRetries int) (string,
 		"-s", "8420",
 		"-n", nqn,
 	}
-	args := strings.Join(cmdArgs, " ")
-	resp, err := agent.Exec(initiatorIP, args)
-	resp = strings.TrimSpace(resp)
+	result, err := agent.ExecCommand(initiatorIP, agent.ExecRequest{Argv: cmdArgs})
 	if err != nil {
 		logf.Log.Info("Running agent failed", "error", err)
 		return "", err
 	}
+	resp := strings.TrimSpace(result.Stdout)
 	if resp != "" { // connect should be silent
 		return "", fmt.Errorf("nvme connect returned with %s", resp)
 	}
@@ -83,7 +82,7 @@ func ChecksumReplica(initiatorIP, targetIP, uri string, maxRetries int) (string,
 	// checksum the device
 	// the returned format is <checksum> <size> <device>
 	// e.g. "924018992 61849088 /dev/nvme0n1p2"
-	args = "cksum " + devicePath
+	args := "cksum " + devicePath
 	cksumText, err := agent.Exec(initiatorIP, args)
 	if err != nil {
 		logf.Log.Info("Running agent failed", "error", err)
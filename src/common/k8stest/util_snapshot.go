@@ -0,0 +1,108 @@
+package k8stest
+
+import (
+	"fmt"
+	"strings"
+
+	"mayastor-e2e/common/mayastorclient"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// SnapshotReplica snapshot of a single replica of a volume
+type SnapshotReplica struct {
+	Address string
+	Name    string
+	Pool    string
+}
+
+// MsvSnapshot snapshot of a volume, comprising a snapshot of each replica
+type MsvSnapshot struct {
+	Uuid     string
+	Name     string
+	Replicas []SnapshotReplica
+}
+
+// SnapshotMsv creates a snapshot of a published volume, and returns the snapshots of its replicas
+func SnapshotMsv(uuid string) (*MsvSnapshot, error) {
+	address, nexus, err := getMsvNexus(uuid)
+	if err != nil {
+		return nil, err
+	}
+	name, err := mayastorclient.CreateSnapshot(address, nexus.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	_, snapTime, ok := mayastorclient.ParseSnapshotName(name)
+	if !ok {
+		return nil, fmt.Errorf("unexpected snapshot name %s for volume %s", name, uuid)
+	}
+	snapshot := MsvSnapshot{Uuid: uuid, Name: name}
+	for _, nodeAddr := range GetMayastorNodeIPAddresses() {
+		replicas, err := mayastorclient.ListSnapshots([]string{nodeAddr})
+		if err != nil {
+			return &snapshot, err
+		}
+		for _, replica := range replicas {
+			source, replicaSnapTime, _ := mayastorclient.ParseSnapshotName(replica.Name)
+			if replicaSnapTime != snapTime {
+				continue
+			}
+			for _, child := range nexus.Children {
+				if strings.Contains(child.Uri, source) {
+					snapshot.Replicas = append(snapshot.Replicas, SnapshotReplica{
+						Address: nodeAddr,
+						Name:    replica.Name,
+						Pool:    replica.Pool,
+					})
+					break
+				}
+			}
+		}
+	}
+	logf.Log.Info("SnapshotMsv", "snapshot", snapshot)
+	if len(snapshot.Replicas) != len(nexus.Children) {
+		return &snapshot, fmt.Errorf("found %d replica snapshots for volume %s snapshot %s, expected %d",
+			len(snapshot.Replicas), uuid, name, len(nexus.Children))
+	}
+	return &snapshot, nil
+}
+
+// ChecksumSnapshot checksums a replica snapshot, the snapshot is shared over nvmf
+// for the duration of the checksum.
+// the returned format is <checksum> <size>
+func ChecksumSnapshot(replica SnapshotReplica) (string, error) {
+	uri, err := mayastorclient.ShareReplica(replica.Address, replica.Name, mayastorGrpc.ShareProtocolReplica_REPLICA_NVMF)
+	if err != nil {
+		return "", err
+	}
+	cksum, err := ChecksumReplica(replica.Address, replica.Address, uri, 60)
+	_, unshareErr := mayastorclient.ShareReplica(replica.Address, replica.Name, mayastorGrpc.ShareProtocolReplica_REPLICA_NONE)
+	if err != nil {
+		return "", err
+	}
+	if unshareErr != nil {
+		return "", unshareErr
+	}
+	// discard the device name which varies from call to call
+	fields := strings.Fields(cksum)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("unexpected checksum %s for snapshot %s", cksum, replica.Name)
+	}
+	return fields[0] + " " + fields[1], nil
+}
+
+// DeleteMsvSnapshot deletes the snapshots of all replicas of a volume snapshot
+func DeleteMsvSnapshot(snapshot *MsvSnapshot) error {
+	var errs []string
+	for _, replica := range snapshot.Replicas {
+		if err := mayastorclient.RmReplica(replica.Address, replica.Name); err != nil {
+			errs = append(errs, fmt.Sprintf("%s on %s: %v", replica.Name, replica.Address, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to delete snapshots of volume %s, %s", snapshot.Uuid, strings.Join(errs, "; "))
+	}
+	return nil
+}
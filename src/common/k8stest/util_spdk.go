@@ -0,0 +1,73 @@
+package k8stest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/mayastorclient"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DumpSpdkState writes the SPDK bdev, nvmf subsystem and lvol store state of
+// every mayastor node to dir, one file per node named spdk-<node name>.json.
+// Errors for individual methods are recorded in the file rather than returned,
+// and a result which is not JSON is recorded as a string, so that as much state
+// as possible is captured. Failure to write the file of a node does not stop
+// the state of the remaining nodes being written.
+func DumpSpdkState(dir string) error {
+	nodes, err := GetNodeLocs()
+	if err != nil {
+		return fmt.Errorf("failed to get list of nodes, error: %v", err)
+	}
+	if err = os.MkdirAll(dir, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	var errs common.ErrorAccumulator
+	for _, node := range nodes {
+		if !node.MayastorNode {
+			continue
+		}
+		state := make(map[string]interface{})
+		for _, method := range mayastorclient.SpdkDiagnosticMethods {
+			result, err := mayastorclient.JsonRpc(node.IPAddress, method, nil)
+			if err != nil {
+				state[method] = map[string]string{"error": err.Error()}
+			} else if json.Valid(result) {
+				state[method] = result
+			} else {
+				state[method] = string(result)
+			}
+		}
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err == nil {
+			fileName := filepath.Join(dir, fmt.Sprintf("spdk-%s.json", node.NodeName))
+			if err = ioutil.WriteFile(fileName, data, 0644); err == nil {
+				logf.Log.Info("Wrote SPDK state", "node", node.NodeName, "file", fileName)
+			}
+		}
+		if err != nil {
+			errs.Accumulate(fmt.Errorf("failed to write SPDK state of node %s, error: %v", node.NodeName, err))
+		}
+	}
+	return errs.GetError()
+}
+
+// dumpSpdkStateOnFailure writes the SPDK state to the reports directory,
+// failure to write the state is logged but is not a test failure.
+func dumpSpdkStateOnFailure() {
+	reportsDir := e2e_config.GetConfig().ReportsDir
+	if reportsDir == "" || !mayastorclient.CanConnect() {
+		return
+	}
+	dir := filepath.Join(reportsDir, "spdk", time.Now().Format("20060102-150405"))
+	if err := DumpSpdkState(dir); err != nil {
+		logf.Log.Info("Failed to dump SPDK state", "error", err)
+	}
+}
@@ -0,0 +1,47 @@
+package k8stest
+
+import (
+	"time"
+
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/mayastorclient"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// StartStatsCollector starts collecting resource usage, replica and nvme controller statistics
+// from all mayastor nodes, at the configured period.
+// Returns nil if gRPC is not available or no reports directory is configured.
+func StartStatsCollector() *mayastorclient.StatsCollector {
+	cfg := e2e_config.GetConfig()
+	if !mayastorclient.CanConnect() || cfg.ReportsDir == "" {
+		logf.Log.Info("Statistics collection is disabled", "gRPC", mayastorclient.CanConnect(), "reportsDir", cfg.ReportsDir)
+		return nil
+	}
+	period, err := time.ParseDuration(cfg.StatsCollectionPeriod)
+	if err != nil {
+		logf.Log.Info("Invalid statistics collection period", "period", cfg.StatsCollectionPeriod, "error", err)
+		return nil
+	}
+	nodeAddrs, err := getClusterMayastorNodeIPAddrs()
+	if err != nil {
+		logf.Log.Info("Failed to get mayastor node addresses", "error", err)
+		return nil
+	}
+	sc := mayastorclient.NewStatsCollector(nodeAddrs, period)
+	sc.Start()
+	return sc
+}
+
+// StopStatsCollector stops a collector started by StartStatsCollector and writes
+// the statistics to the reports directory, file names are prefixed with name.
+// Failure to write the statistics is logged but is not a test failure.
+func StopStatsCollector(sc *mayastorclient.StatsCollector, name string) {
+	if sc == nil {
+		return
+	}
+	sc.Stop()
+	if err := sc.WriteReports(e2e_config.GetConfig().ReportsDir, name); err != nil {
+		logf.Log.Info("Failed to write statistics", "name", name, "error", err)
+	}
+}
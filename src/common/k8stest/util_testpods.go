@@ -22,7 +22,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-// FIXME: this function runs fio with a bunch of parameters which are not configurable.
+// RunFio runs fio in the pod podName with the configured fio profile (E2EConfig.Workloads.Fio)
 // sizeMb should be 0 for fio to use the entire block device
 func RunFio(podName string, duration int, filename string, sizeMb int, args ...string) ([]byte, error) {
 	argRuntime := fmt.Sprintf("--runtime=%d", duration)
@@ -40,20 +40,14 @@ func RunFio(podName string, duration int, filename string, sizeMb int, args ...s
 		podName,
 		"--",
 		"fio",
-		"--name=benchtest",
-		"--verify=crc32",
-		"--verify_fatal=1",
-		"--verify_async=2",
+	}
+	cmdArgs = append(cmdArgs, e2e_config.GetConfig().Workloads.Fio.Args()...)
+	cmdArgs = append(cmdArgs,
 		argFilename,
-		"--direct=1",
-		"--rw=randrw",
-		"--ioengine=libaio",
-		"--bs=4k",
-		"--iodepth=16",
-		"--numjobs=1",
 		"--time_based",
 		argRuntime,
-	}
+		"--output-format=json",
+	)
 
 	if sizeMb != 0 {
 		sizeArgs := []string{fmt.Sprintf("--size=%dm", sizeMb)}
@@ -72,6 +66,10 @@ func RunFio(podName string, duration int, filename string, sizeMb int, args ...s
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logf.Log.Info("Running fio failed", "error", err)
+	} else if result, parseErr := ParseFioJson(string(output)); parseErr != nil {
+		logf.Log.Info("Parsing fio output failed", "error", parseErr)
+	} else {
+		err = RecordFioResult(podName, result)
 	}
 	return output, err
 }
@@ -536,8 +534,8 @@ func restartMayastor(restartTOSecs int, readyTOSecs int, poolsTOSecs int) error
 	time.Sleep(30 * time.Second)
 
 	_, _ = DeleteAllPoolFinalizers()
-	// Pool device is not specified so do not re-create the pools
-	if len(e2e_config.GetConfig().PoolDevice) != 0 {
+	// Pools are not configured so do not re-create the pools
+	if PoolsConfigured() {
 		_ = DeleteAllPools()
 		err = CreateConfiguredPools()
 		if err != nil {
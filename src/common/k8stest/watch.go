@@ -0,0 +1,42 @@
+package k8stest
+
+import (
+	"context"
+	"time"
+
+	"mayastor-e2e/common/controlplane"
+	"mayastor-e2e/common/custom_resources"
+)
+
+// period at which the control plane is polled by watches,
+// short enough to observe transient states.
+const watchPollPeriod = 500 * time.Millisecond
+
+// WatchMsvs returns a channel of volume state transition events,
+// an informer is used if volumes are custom resources, otherwise the control plane is polled.
+// the channel is closed when ctx is done.
+func WatchMsvs(ctx context.Context) <-chan controlplane.MsvEvent {
+	if custom_resources.MsVolsServed() {
+		return custom_resources.WatchMsVols(ctx)
+	}
+	EnsureNodeAddressesAreSet()
+	return controlplane.WatchMsvs(ctx, watchPollPeriod)
+}
+
+// WatchMsns returns a channel of mayastor node status transition events,
+// an informer is used if nodes are custom resources, otherwise the control plane is polled.
+// the channel is closed when ctx is done.
+func WatchMsns(ctx context.Context) <-chan controlplane.MsnEvent {
+	if custom_resources.MsNodesServed() {
+		return custom_resources.WatchMsNodes(ctx)
+	}
+	EnsureNodeAddressesAreSet()
+	return controlplane.WatchMsns(ctx, watchPollPeriod)
+}
+
+// WatchMsPools returns a channel of pool state transition events,
+// pools are custom resources so an informer is used.
+// the channel is closed when ctx is done.
+func WatchMsPools(ctx context.Context) <-chan controlplane.MspEvent {
+	return custom_resources.WatchMsPools(ctx)
+}
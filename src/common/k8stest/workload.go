@@ -0,0 +1,385 @@
+package k8stest
+
+// IO workloads run in pods against a volume.
+// A Workload builds the container which runs it and parses the log of the
+// completed pod, so that tests can run fio, fsx or a dd writer-verifier the same
+// way and select the workload by name from the configuration.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/e2e_config"
+
+	coreV1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	WorkloadFio      = "fio"
+	WorkloadFsx      = "fsx"
+	WorkloadDdVerify = "dd-verify"
+)
+
+// WorkloadParams parameters common to all workloads
+type WorkloadParams struct {
+	// DurationSecs for time based workloads, 0 runs the workload once
+	DurationSecs int
+	// SizeMb of the data, 0 uses the whole device for raw block volumes
+	SizeMb int
+}
+
+// WorkloadResult result of a completed workload
+type WorkloadResult struct {
+	Workload string
+	Passed   bool
+	Summary  string
+	// Fio result of fio workloads
+	Fio *FioResult
+}
+
+// Workload an IO workload run in a pod against a volume
+type Workload interface {
+	// Name of the workload
+	Name() string
+	// Container returns the container which runs the workload on a volume of type volType
+	Container(podName string, volType common.VolumeType) (coreV1.Container, error)
+	// ParseResult parses the log of the completed workload pod
+	ParseResult(log string) (*WorkloadResult, error)
+}
+
+// NewWorkload returns the workload with name configured by E2EConfig.Workloads,
+// fio:<profile name> selects a named fio profile.
+func NewWorkload(name string, params WorkloadParams) (Workload, error) {
+	cfg := e2e_config.GetConfig().Workloads
+	switch {
+	case name == WorkloadFio:
+		return &FioWorkload{Profile: cfg.Fio, Params: params}, nil
+	case strings.HasPrefix(name, WorkloadFio+":"):
+		profileName := strings.TrimPrefix(name, WorkloadFio+":")
+		profile, ok := cfg.FioProfiles[profileName]
+		if !ok {
+			return nil, fmt.Errorf("fio profile %s is not configured", profileName)
+		}
+		return &FioWorkload{Profile: profile, Params: params}, nil
+	case name == WorkloadFsx:
+		return &FsxWorkload{FsType: cfg.Fsx.FsType, Operations: cfg.Fsx.Operations}, nil
+	case name == WorkloadDdVerify:
+		sizeMb := params.SizeMb
+		if sizeMb == 0 {
+			sizeMb = cfg.DdVerify.SizeMb
+		}
+		return &DdVerifyWorkload{SizeMb: sizeMb, BlockSizeKb: cfg.DdVerify.BlockSizeKb}, nil
+	}
+	return nil, fmt.Errorf("unknown workload %s", name)
+}
+
+func workloadFilename(volType common.VolumeType) string {
+	if volType == common.VolRawBlock {
+		return common.FioBlockFilename
+	}
+	return common.FioFsFilename
+}
+
+// FioWorkload runs fio with a profile
+type FioWorkload struct {
+	Profile e2e_config.FioProfile
+	Params  WorkloadParams
+}
+
+func (w *FioWorkload) Name() string {
+	return WorkloadFio
+}
+
+// Args returns the fio arguments for a volume of type volType
+func (w *FioWorkload) Args(volType common.VolumeType) []string {
+	args := []string{"--filename=" + workloadFilename(volType)}
+	if w.Params.SizeMb != 0 {
+		args = append(args, fmt.Sprintf("--size=%dm", w.Params.SizeMb))
+	}
+	if w.Params.DurationSecs != 0 {
+		args = append(args, "--time_based", fmt.Sprintf("--runtime=%d", w.Params.DurationSecs))
+	}
+	args = append(args, w.Profile.Args()...)
+	return append(args, "--output-format=json")
+}
+
+func (w *FioWorkload) Container(podName string, volType common.VolumeType) (coreV1.Container, error) {
+	if volType == common.VolFileSystem && w.Params.SizeMb == 0 {
+		return coreV1.Container{}, fmt.Errorf("fio on a filesystem volume requires a size")
+	}
+	return MakeFioContainer(podName, append([]string{"--"}, w.Args(volType)...)), nil
+}
+
+func (w *FioWorkload) ParseResult(log string) (*WorkloadResult, error) {
+	fioResult, err := ParseFioJson(log)
+	if err != nil {
+		return nil, err
+	}
+	summary := fioResult.Summary()
+	return &WorkloadResult{
+		Workload: w.Name(),
+		Passed:   summary.Errors == 0,
+		Summary:  summary.String(),
+		Fio:      fioResult,
+	}, nil
+}
+
+// FsxWorkload creates a filesystem on a raw block volume and runs fsx on it
+type FsxWorkload struct {
+	FsType     string
+	Operations int
+}
+
+func (w *FsxWorkload) Name() string {
+	return WorkloadFsx
+}
+
+func (w *FsxWorkload) Container(podName string, volType common.VolumeType) (coreV1.Container, error) {
+	if volType != common.VolRawBlock {
+		return coreV1.Container{}, fmt.Errorf("fsx requires a raw block volume")
+	}
+	args := []string{common.FioBlockFilename, w.FsType, strconv.Itoa(w.Operations)}
+	return MakeFsxContainer(podName, args), nil
+}
+
+func (w *FsxWorkload) ParseResult(log string) (*WorkloadResult, error) {
+	for _, line := range strings.Split(log, "\n") {
+		if strings.HasPrefix(line, "PASS: fsxtest") {
+			return &WorkloadResult{Workload: w.Name(), Passed: true, Summary: line}, nil
+		}
+		if strings.HasPrefix(line, "FAIL: fsxtest") {
+			return &WorkloadResult{Workload: w.Name(), Passed: false, Summary: line}, nil
+		}
+	}
+	return nil, fmt.Errorf("no fsx result found")
+}
+
+// DdVerifyWorkload writes random data to the volume using dd,
+// reads it back and compares the sha256 checksums
+type DdVerifyWorkload struct {
+	SizeMb      int
+	BlockSizeKb int
+}
+
+func (w *DdVerifyWorkload) Name() string {
+	return WorkloadDdVerify
+}
+
+// Script returns the shell script which writes and verifies the data
+func (w *DdVerifyWorkload) Script(volType common.VolumeType) string {
+	filename := workloadFilename(volType)
+	count := w.SizeMb * 1024 / w.BlockSizeKb
+	return strings.Join([]string{
+		"set -e",
+		fmt.Sprintf("dd if=/dev/urandom of=/tmp/pattern bs=%dk count=%d", w.BlockSizeKb, count),
+		"written=$(sha256sum < /tmp/pattern | cut -d' ' -f1)",
+		fmt.Sprintf("dd if=/tmp/pattern of=%s bs=%dk conv=fsync", filename, w.BlockSizeKb),
+		"sync",
+		"echo 3 > /proc/sys/vm/drop_caches || true",
+		fmt.Sprintf("readback=$(dd if=%s bs=%dk count=%d iflag=direct | sha256sum | cut -d' ' -f1)", filename, w.BlockSizeKb, count),
+		"echo \"sha256 written $written read $readback\"",
+		fmt.Sprintf("if [ \"$written\" = \"$readback\" ]; then echo \"PASS: %s\"; else echo \"FAIL: %s\"; exit 1; fi", WorkloadDdVerify, WorkloadDdVerify),
+	}, "\n")
+}
+
+func (w *DdVerifyWorkload) Container(podName string, volType common.VolumeType) (coreV1.Container, error) {
+	if w.SizeMb <= 0 || w.BlockSizeKb <= 0 || (w.SizeMb*1024)%w.BlockSizeKb != 0 {
+		return coreV1.Container{}, fmt.Errorf("invalid dd-verify size %dMiB block size %dKiB", w.SizeMb, w.BlockSizeKb)
+	}
+	container := MakeFioContainer(podName, []string{w.Script(volType)})
+	container.Command = []string{"/bin/sh", "-c"}
+	return container, nil
+}
+
+func (w *DdVerifyWorkload) ParseResult(log string) (*WorkloadResult, error) {
+	var summary string
+	for _, line := range strings.Split(log, "\n") {
+		if strings.HasPrefix(line, "sha256 ") {
+			summary = line
+		}
+		if line == "PASS: "+WorkloadDdVerify {
+			return &WorkloadResult{Workload: w.Name(), Passed: true, Summary: summary}, nil
+		}
+		if line == "FAIL: "+WorkloadDdVerify {
+			return &WorkloadResult{Workload: w.Name(), Passed: false, Summary: summary}, nil
+		}
+	}
+	return nil, fmt.Errorf("no dd-verify result found")
+}
+
+// WorkloadPodDef returns the definition of a pod in the default namespace which runs the
+// workload against the volume with PVC pvcName
+func WorkloadPodDef(workload Workload, podName string, pvcName string, volType common.VolumeType) (*coreV1.Pod, error) {
+	container, err := workload.Container(podName, volType)
+	if err != nil {
+		return nil, err
+	}
+	volume := coreV1.Volume{
+		Name: "ms-volume",
+		VolumeSource: coreV1.VolumeSource{
+			PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{
+				ClaimName: pvcName,
+			},
+		},
+	}
+	return NewPodBuilder().
+		WithName(podName).
+		WithNamespace(common.NSDefault).
+		WithLabels(map[string]string{"app": workload.Name()}).
+		WithRestartPolicy(coreV1.RestartPolicyNever).
+		WithContainer(container).
+		WithVolume(volume).
+		WithVolumeDeviceOrMount(volType).
+		Build()
+}
+
+// StartWorkload creates a pod in the default namespace which runs the workload against
+// the volume with PVC pvcName and waits for it to run
+func StartWorkload(workload Workload, podName string, pvcName string, volType common.VolumeType) error {
+	logf.Log.Info("StartWorkload", "workload", workload.Name(), "pod", podName, "pvc", pvcName)
+	podDef, err := WorkloadPodDef(workload, podName, pvcName, volType)
+	if err != nil {
+		return fmt.Errorf("failed to define %s pod %s, error: %v", workload.Name(), podName, err)
+	}
+	if _, err = CreatePod(podDef, common.NSDefault); err != nil {
+		return fmt.Errorf("failed to create %s pod %s, error: %v", workload.Name(), podName, err)
+	}
+	// short workloads may complete before the pod is seen running
+	const sleepTime = 3
+	for ix := 0; ix < defTimeoutSecs/sleepTime; ix++ {
+		var pod coreV1.Pod
+		if err = gTestEnv.K8sClient.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: common.NSDefault}, &pod); err == nil {
+			if pod.Status.Phase != coreV1.PodPending {
+				return nil
+			}
+		}
+		time.Sleep(sleepTime * time.Second)
+	}
+	return fmt.Errorf("%s pod %s did not start, error: %v", workload.Name(), podName, err)
+}
+
+// GetPodLog returns the log of the first container of a pod
+func GetPodLog(podName string, nameSpace string) (string, error) {
+	logs, err := gTestEnv.KubeInt.CoreV1().Pods(nameSpace).GetLogs(podName, &coreV1.PodLogOptions{}).DoRaw(context.TODO())
+	return string(logs), err
+}
+
+// WaitWorkload waits for the workload pod to complete and returns the result parsed from its log.
+// An error is returned if the pod did not succeed or the workload failed.
+func WaitWorkload(workload Workload, podName string, timeoutSecs int) (*WorkloadResult, error) {
+	waitErr := WaitPodComplete(podName, 1, timeoutSecs)
+	log, err := GetPodLog(podName, common.NSDefault)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log of %s pod %s, error: %v", workload.Name(), podName, err)
+	}
+	result, err := workload.ParseResult(log)
+	if err != nil {
+		if waitErr != nil {
+			return nil, fmt.Errorf("%s pod %s: %v, %v", workload.Name(), podName, waitErr, err)
+		}
+		return nil, fmt.Errorf("%s pod %s: %v", workload.Name(), podName, err)
+	}
+	logf.Log.Info("WaitWorkload", "pod", podName, "result", result)
+	if result.Fio != nil {
+		if err = RecordFioResult(podName, result.Fio); err != nil && waitErr == nil && result.Passed {
+			return result, err
+		}
+	}
+	if waitErr != nil {
+		return result, fmt.Errorf("%s pod %s: %v, %s", workload.Name(), podName, waitErr, result.Summary)
+	}
+	if !result.Passed {
+		return result, fmt.Errorf("%s pod %s failed: %s", workload.Name(), podName, result.Summary)
+	}
+	return result, nil
+}
+
+// RunWorkload runs the workload against the volume with PVC pvcName in a pod,
+// the pod is deleted after the workload completes.
+func RunWorkload(workload Workload, podName string, pvcName string, volType common.VolumeType, timeoutSecs int) (*WorkloadResult, error) {
+	if err := StartWorkload(workload, podName, pvcName, volType); err != nil {
+		return nil, err
+	}
+	result, err := WaitWorkload(workload, podName, timeoutSecs)
+	if delErr := DeletePod(podName, common.NSDefault); delErr != nil && err == nil {
+		err = delErr
+	}
+	return result, err
+}
+
+// WorkloadVolume a filesystem volume with a workload pod running against it
+type WorkloadVolume struct {
+	ScName   string
+	PvcName  string
+	Uuid     string
+	PodName  string
+	Workload Workload
+}
+
+// DeployWorkloadVolume creates a storage class scName with replicas replicas, a filesystem
+// volume pvcName of size volMb and a pod which runs the workload against the volume, then
+// waits for the pod to run and the volume to be published.
+// The returned cleanup func deletes the resources which were created in reverse order,
+// it is returned also on error so that callers can defer it before checking the error.
+func DeployWorkloadVolume(scName string, pvcName string, replicas int, volMb int, workload Workload, timeoutSecs int) (*WorkloadVolume, func() error, error) {
+	var cleanups []func() error
+	cleanup := func() error {
+		var firstErr error
+		for ix := len(cleanups) - 1; ix >= 0; ix-- {
+			if err := cleanups[ix](); err != nil {
+				logf.Log.Info("DeployWorkloadVolume cleanup", "error", err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		cleanups = nil
+		return firstErr
+	}
+
+	wv := WorkloadVolume{
+		ScName:   scName,
+		PvcName:  pvcName,
+		PodName:  workload.Name() + "-" + pvcName,
+		Workload: workload,
+	}
+	if err := MkStorageClass(scName, replicas, common.ShareProtoNvmf, common.NSDefault); err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create storage class %s, error: %v", scName, err)
+	}
+	cleanups = append(cleanups, func() error { return RmStorageClass(scName) })
+
+	uuid, err := MkPVC(volMb, pvcName, scName, common.VolFileSystem, common.NSDefault)
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create pvc %s, error: %v", pvcName, err)
+	}
+	cleanups = append(cleanups, func() error { return RmPVC(pvcName, scName, common.NSDefault) })
+	wv.Uuid = uuid
+	logf.Log.Info("Volume", "uid", uuid)
+
+	// the pod is deleted if it was created, also when StartWorkload fails
+	cleanups = append(cleanups, func() error {
+		if err := DeletePod(wv.PodName, common.NSDefault); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	})
+	if err = StartWorkload(workload, wv.PodName, pvcName, common.VolFileSystem); err != nil {
+		return nil, cleanup, err
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutSecs) * time.Second)
+	for !IsPodRunning(wv.PodName, common.NSDefault) || !IsMsvPublished(uuid) {
+		if time.Now().After(deadline) {
+			return nil, cleanup, fmt.Errorf("timed out waiting for %s pod %s to run on volume %s", workload.Name(), wv.PodName, uuid)
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return &wv, cleanup, nil
+}
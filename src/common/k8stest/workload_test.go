@@ -0,0 +1,63 @@
+package k8stest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/e2e_config"
+)
+
+func TestFioWorkload(t *testing.T) {
+	w := FioWorkload{Profile: e2e_config.FioProfile{Rw: "write"}, Params: WorkloadParams{DurationSecs: 30, SizeMb: 100}}
+	expected := []string{"--filename=" + common.FioFsFilename, "--size=100m", "--time_based", "--runtime=30", "--name=benchtest", "--rw=write", "--direct=1", "--output-format=json"}
+	if args := w.Args(common.VolFileSystem); !reflect.DeepEqual(args, expected) {
+		t.Errorf("args %v, expected %v", args, expected)
+	}
+	result, err := w.ParseResult("fio-3.25\n" + fioJson)
+	if err != nil || !result.Passed || result.Fio == nil || result.Fio.Summary().ReadIops != 2310.5 {
+		t.Errorf("unexpected result %v, error %v", result, err)
+	}
+	result, err = w.ParseResult(strings.Replace(fioJson, `"error" : 0`, `"error" : 84`, 1))
+	if err != nil || result.Passed || !strings.Contains(result.Summary, "errors=1") {
+		t.Errorf("unexpected result %v, error %v", result, err)
+	}
+	if _, err = w.ParseResult("fio: pid=0, err=2/file:filesetup.c"); err == nil {
+		t.Errorf("expected error for log without result")
+	}
+}
+
+func TestFsxWorkload(t *testing.T) {
+	w := FsxWorkload{FsType: "ext4", Operations: 1000}
+	if _, err := w.Container("fsx", common.VolFileSystem); err == nil {
+		t.Errorf("expected error for filesystem volume")
+	}
+	result, err := w.ParseResult("mke2fs 1.46.2\nPASS: fsxtest /dev/sdm ext4 1000\n")
+	if err != nil || !result.Passed {
+		t.Errorf("unexpected result %v, error %v", result, err)
+	}
+	result, err = w.ParseResult("FAIL: fsxtest /dev/sdm ext4 1000\n")
+	if err != nil || result.Passed {
+		t.Errorf("unexpected result %v, error %v", result, err)
+	}
+}
+
+func TestDdVerifyWorkload(t *testing.T) {
+	w := DdVerifyWorkload{SizeMb: 64, BlockSizeKb: 1024}
+	script := w.Script(common.VolRawBlock)
+	if !strings.Contains(script, "of="+common.FioBlockFilename+" bs=1024k") || !strings.Contains(script, "count=64 iflag=direct") {
+		t.Errorf("unexpected script %s", script)
+	}
+	if _, err := (&DdVerifyWorkload{SizeMb: 1, BlockSizeKb: 1000}).Container("dd", common.VolRawBlock); err == nil {
+		t.Errorf("expected error for size which is not a multiple of the block size")
+	}
+	result, err := w.ParseResult("64+0 records in\nsha256 written abc read abc\nPASS: dd-verify\n")
+	if err != nil || !result.Passed || result.Summary != "sha256 written abc read abc" {
+		t.Errorf("unexpected result %v, error %v", result, err)
+	}
+	result, err = w.ParseResult("sha256 written abc read abd\nFAIL: dd-verify\n")
+	if err != nil || result.Passed {
+		t.Errorf("unexpected result %v, error %v", result, err)
+	}
+}
@@ -0,0 +1,47 @@
+package mayastorclient
+
+import (
+	"context"
+	"fmt"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// GetNvmeAnaState returns the NVMe ANA state of the nexus with uuid on the node with ip address address
+func GetNvmeAnaState(address string, uuid string) (mayastorGrpc.NvmeAnaState, error) {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("GetNvmeAnaState", "error", err)
+		return mayastorGrpc.NvmeAnaState_NVME_ANA_INVALID_STATE, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.GetNvmeAnaStateRequest{Uuid: uuid}
+	var response *mayastorGrpc.GetNvmeAnaStateReply
+	err = callWithRetry(true, func(ctx context.Context) (err error) {
+		response, err = c.GetNvmeAnaState(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to GetNvmeAnaState")
+		} else {
+			return response.AnaState, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("GetNvmeAnaState", "error", err)
+	}
+	return mayastorGrpc.NvmeAnaState_NVME_ANA_INVALID_STATE, err
+}
+
+// SetNvmeAnaState sets the NVMe ANA state of the nexus with uuid on the node with ip address address
+func SetNvmeAnaState(address string, uuid string, anaState mayastorGrpc.NvmeAnaState) error {
+	req := mayastorGrpc.SetNvmeAnaStateRequest{Uuid: uuid, AnaState: anaState}
+	return nullResponseCall(address, "SetNvmeAnaState", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.SetNvmeAnaState(ctx, &req)
+	})
+}
@@ -0,0 +1,76 @@
+package mayastorclient
+
+import (
+	"context"
+	"fmt"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// BlockDevice block device on a mayastor node
+type BlockDevice struct {
+	Devname  string   `json:"devname"`
+	Devtype  string   `json:"devtype"`
+	Model    string   `json:"model"`
+	Devpath  string   `json:"devpath"`
+	Devlinks []string `json:"devlinks,omitempty"`
+	// Size in bytes
+	Size       uint64 `json:"size"`
+	Fstype     string `json:"fstype,omitempty"`
+	Mountpoint string `json:"mountpoint,omitempty"`
+	Available  bool   `json:"available"`
+}
+
+func (bd BlockDevice) String() string {
+	return fmt.Sprintf("Devname=%s; Devtype=%s; Model=%s; Size=%d; Fstype=%s; Mountpoint=%s; Available=%v;",
+		bd.Devname, bd.Devtype, bd.Model, bd.Size, bd.Fstype, bd.Mountpoint, bd.Available)
+}
+
+// ListBlockDevices returns the block devices on the node with ip address address,
+// if all is false only devices which are available for use are listed.
+func ListBlockDevices(address string, all bool) ([]BlockDevice, error) {
+	var devices []BlockDevice
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("ListBlockDevices", "error", err)
+		return devices, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.ListBlockDevicesRequest{All: all}
+	var response *mayastorGrpc.ListBlockDevicesReply
+	err = callWithRetry(true, func(ctx context.Context) (err error) {
+		response, err = c.ListBlockDevices(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response != nil {
+			for _, dev := range response.Devices {
+				bd := BlockDevice{
+					Devname:   dev.Devname,
+					Devtype:   dev.Devtype,
+					Model:     dev.Model,
+					Devpath:   dev.Devpath,
+					Devlinks:  dev.Devlinks,
+					Size:      dev.Size * 512,
+					Available: dev.Available,
+				}
+				if dev.Filesystem != nil {
+					bd.Fstype = dev.Filesystem.Fstype
+					bd.Mountpoint = dev.Filesystem.Mountpoint
+				}
+				devices = append(devices, bd)
+			}
+		} else {
+			err = fmt.Errorf("nil response for ListBlockDevices on %s", address)
+			logf.Log.Info("ListBlockDevices", "error", err)
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("ListBlockDevices", "error", err)
+	}
+	return devices, err
+}
@@ -0,0 +1,240 @@
+package mayastorclient
+
+// Periodic collection of resource usage, replica and NVMe controller statistics
+// from mayastor nodes. The samples are written as a time series to
+// <prefix>-stats.json, and as CSV files:
+//   <prefix>-resource-usage.csv
+//   <prefix>-replica-stats.csv
+//   <prefix>-nvme-controller-stats.csv
+// IOPS and throughput in the CSV files are computed from the difference
+// between consecutive samples of the same node and replica or controller.
+// CPU and memory usage and IO latency are not reported by the mayastor gRPC API,
+// so are not collected. IO latency as seen by the application is recorded
+// with the fio results, see k8stest.RecordFioResult.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// StatsSample statistics collected from a node at a point in time
+type StatsSample struct {
+	Timestamp       time.Time             `json:"timestamp"`
+	Node            string                `json:"node"`
+	Usage           *ResourceUsage        `json:"usage,omitempty"`
+	Replicas        []ReplicaStats        `json:"replicas,omitempty"`
+	NvmeControllers []NvmeControllerStats `json:"nvme_controllers,omitempty"`
+	Errors          []string              `json:"errors,omitempty"`
+}
+
+// StatsCollector periodically samples statistics from a set of mayastor nodes
+type StatsCollector struct {
+	addrs   []string
+	period  time.Duration
+	mutex   sync.Mutex
+	samples []StatsSample
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewStatsCollector returns a collector for the nodes with ip addresses addrs,
+// sampling every period
+func NewStatsCollector(addrs []string, period time.Duration) *StatsCollector {
+	return &StatsCollector{
+		addrs:  addrs,
+		period: period,
+	}
+}
+
+// Start sampling, sampling continues until Stop is called
+func (sc *StatsCollector) Start() {
+	sc.stop = make(chan struct{})
+	sc.done = make(chan struct{})
+	go func() {
+		defer close(sc.done)
+		ticker := time.NewTicker(sc.period)
+		defer ticker.Stop()
+		for {
+			sc.collect()
+			select {
+			case <-ticker.C:
+			case <-sc.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop sampling, returns the samples collected
+func (sc *StatsCollector) Stop() []StatsSample {
+	if sc.stop != nil {
+		close(sc.stop)
+		<-sc.done
+		sc.stop = nil
+	}
+	return sc.Samples()
+}
+
+// Samples returns a copy of the samples collected so far
+func (sc *StatsCollector) Samples() []StatsSample {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	return append([]StatsSample{}, sc.samples...)
+}
+
+// collect samples every node concurrently, the calls are made once so that
+// an unresponsive node delays the sample by at most one call timeout per call
+func (sc *StatsCollector) collect() {
+	samples := make([]StatsSample, len(sc.addrs))
+	_ = forEachNode(sc.addrs, func(ix int, address string) error {
+		sample := StatsSample{
+			Timestamp: time.Now(),
+			Node:      address,
+		}
+		var err error
+		if sample.Usage, err = getResourceUsage(address, false); err != nil {
+			sample.Errors = append(sample.Errors, fmt.Sprintf("GetResourceUsage: %v", err))
+		}
+		if sample.Replicas, err = statReplicas(address, false); err != nil {
+			sample.Errors = append(sample.Errors, fmt.Sprintf("StatReplicas: %v", err))
+		}
+		if sample.NvmeControllers, err = statNvmeControllers(address, false); err != nil {
+			sample.Errors = append(sample.Errors, fmt.Sprintf("StatNvmeControllers: %v", err))
+		}
+		samples[ix] = sample
+		return nil
+	})
+	sc.mutex.Lock()
+	sc.samples = append(sc.samples, samples...)
+	sc.mutex.Unlock()
+}
+
+// WriteReports writes the samples collected so far to files in dir,
+// file names are prefixed with prefix.
+func (sc *StatsCollector) WriteReports(dir string, prefix string) error {
+	samples := sc.Samples()
+	data, err := json.MarshalIndent(samples, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, prefix+"-stats.json"), data, 0644); err != nil {
+		return err
+	}
+	if err = writeCsv(filepath.Join(dir, prefix+"-resource-usage.csv"), resourceUsageRecords(samples)); err != nil {
+		return err
+	}
+	if err = writeCsv(filepath.Join(dir, prefix+"-replica-stats.csv"), replicaStatsRecords(samples)); err != nil {
+		return err
+	}
+	err = writeCsv(filepath.Join(dir, prefix+"-nvme-controller-stats.csv"), nvmeControllerStatsRecords(samples))
+	if err == nil {
+		logf.Log.Info("Wrote statistics", "dir", dir, "prefix", prefix, "samples", len(samples))
+	}
+	return err
+}
+
+func writeCsv(fileName string, records [][]string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	err = w.WriteAll(records)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func u64(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func i64(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// rate returns the rate of change per second between two counter values
+func rate(prev uint64, cur uint64, elapsed time.Duration) string {
+	if cur < prev || elapsed <= 0 {
+		// counters were reset, for example mayastor restarted
+		return ""
+	}
+	return strconv.FormatFloat(float64(cur-prev)/elapsed.Seconds(), 'f', 2, 64)
+}
+
+func resourceUsageRecords(samples []StatsSample) [][]string {
+	records := [][]string{{"timestamp", "node", "soft_faults", "hard_faults", "swaps",
+		"in_block_ops", "out_block_ops", "ipc_msg_send", "ipc_msg_rcv", "signals", "vol_csw", "invol_csw"}}
+	for _, sample := range samples {
+		if sample.Usage == nil {
+			continue
+		}
+		u := sample.Usage
+		records = append(records, []string{sample.Timestamp.Format(time.RFC3339Nano), sample.Node,
+			i64(u.SoftFaults), i64(u.HardFaults), i64(u.Swaps), i64(u.InBlockOps), i64(u.OutBlockOps),
+			i64(u.IpcMsgSend), i64(u.IpcMsgRcv), i64(u.Signals), i64(u.VolCsw), i64(u.InvolCsw)})
+	}
+	return records
+}
+
+type timedCounters struct {
+	ts       time.Time
+	counters []uint64
+}
+
+func replicaStatsRecords(samples []StatsSample) [][]string {
+	records := [][]string{{"timestamp", "node", "uuid", "pool", "num_read_ops", "num_write_ops",
+		"bytes_read", "bytes_written", "read_iops", "write_iops", "read_bytes_per_sec", "write_bytes_per_sec"}}
+	prev := make(map[string]timedCounters)
+	for _, sample := range samples {
+		for _, rs := range sample.Replicas {
+			cur := timedCounters{sample.Timestamp, []uint64{rs.NumReadOps, rs.NumWriteOps, rs.BytesRead, rs.BytesWritten}}
+			record := []string{sample.Timestamp.Format(time.RFC3339Nano), sample.Node, rs.Uuid, rs.Pool,
+				u64(rs.NumReadOps), u64(rs.NumWriteOps), u64(rs.BytesRead), u64(rs.BytesWritten)}
+			record = append(record, rates(prev, sample.Node+"/"+rs.Uuid, cur)...)
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func nvmeControllerStatsRecords(samples []StatsSample) [][]string {
+	records := [][]string{{"timestamp", "node", "name", "num_read_ops", "num_write_ops", "bytes_read",
+		"bytes_written", "num_unmap_ops", "bytes_unmapped", "read_iops", "write_iops", "read_bytes_per_sec", "write_bytes_per_sec"}}
+	prev := make(map[string]timedCounters)
+	for _, sample := range samples {
+		for _, ns := range sample.NvmeControllers {
+			cur := timedCounters{sample.Timestamp, []uint64{ns.NumReadOps, ns.NumWriteOps, ns.BytesRead, ns.BytesWritten}}
+			record := []string{sample.Timestamp.Format(time.RFC3339Nano), sample.Node, ns.Name,
+				u64(ns.NumReadOps), u64(ns.NumWriteOps), u64(ns.BytesRead), u64(ns.BytesWritten),
+				u64(ns.NumUnmapOps), u64(ns.BytesUnmapped)}
+			record = append(record, rates(prev, sample.Node+"/"+ns.Name, cur)...)
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// rates returns the rates of change of the counters since the previous
+// sample for key, and records cur as the previous sample
+func rates(prev map[string]timedCounters, key string, cur timedCounters) []string {
+	result := make([]string, len(cur.counters))
+	if p, ok := prev[key]; ok {
+		elapsed := cur.ts.Sub(p.ts)
+		for ix := range cur.counters {
+			result[ix] = rate(p.counters[ix], cur.counters[ix], elapsed)
+		}
+	}
+	prev[key] = cur
+	return result
+}
@@ -0,0 +1,225 @@
+package mayastorclient
+
+// Connection manager for gRPC connections to mayastor.
+// One connection is cached per node and shared by all calls to that node,
+// connections which have failed or been shut down are replaced on next use.
+// A cached connection which has not been checked for E2EConfig.Grpc.HealthCheckSecs
+// is health checked before it is reused, and replaced if the check fails.
+// The port, TLS credentials, keepalive and default call deadline are
+// configured in E2EConfig.Grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"mayastor-e2e/common/e2e_config"
+
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	healthGrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	grpcStatus "google.golang.org/grpc/status"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type connManager struct {
+	mutex       sync.Mutex
+	conns       map[string]*grpc.ClientConn
+	checked     map[string]time.Time
+	dialOpts    []grpc.DialOption
+	callTimeout time.Duration
+}
+
+var connMgr = connManager{
+	conns:   make(map[string]*grpc.ClientConn),
+	checked: make(map[string]time.Time),
+}
+
+// transportCredentials returns TLS credentials if a CA certificate is configured,
+// client certificates are used (mTLS) if a client certificate and key are configured.
+func transportCredentials() (credentials.TransportCredentials, error) {
+	cfg := e2e_config.GetConfig().Grpc
+	if cfg.CaCertFile == "" {
+		return nil, nil
+	}
+	caCert, err := ioutil.ReadFile(cfg.CaCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gRPC CA certificate %s, %v", cfg.CaCertFile, err)
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to parse gRPC CA certificate %s", cfg.CaCertFile)
+	}
+	tlsConfig := &tls.Config{
+		RootCAs:    certPool,
+		ServerName: cfg.ServerName,
+	}
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC client certificate %s %s, %v", cfg.ClientCertFile, cfg.ClientKeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// init initialises the dial options and call timeout from the configuration,
+// must be called with the mutex held.
+func (cm *connManager) init() error {
+	if cm.dialOpts != nil {
+		return nil
+	}
+	cfg := e2e_config.GetConfig().Grpc
+	creds, err := transportCredentials()
+	if err != nil {
+		return err
+	}
+	opts := []grpc.DialOption{
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(cfg.KeepaliveSecs) * time.Second,
+			Timeout:             time.Duration(cfg.KeepaliveTimeoutSecs) * time.Second,
+			PermitWithoutStream: true,
+		}),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	cm.dialOpts = opts
+	if cm.callTimeout == 0 {
+		cm.callTimeout = time.Duration(cfg.CallTimeoutSecs) * time.Second
+	}
+	return nil
+}
+
+// healthCheck checks that mayastor responds on the connection conn.
+// Mayastor versions which do not serve the gRPC health service respond with Unimplemented,
+// which also shows that the connection works.
+func healthCheck(conn *grpc.ClientConn) error {
+	timeout := time.Duration(e2e_config.GetConfig().Grpc.KeepaliveTimeoutSecs) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	response, err := healthGrpc.NewHealthClient(conn).Check(ctx, &healthGrpc.HealthCheckRequest{})
+	if err != nil {
+		if status, ok := grpcStatus.FromError(err); ok && status.Code() == grpcCodes.Unimplemented {
+			return nil
+		}
+		return niceError(err)
+	}
+	if response.Status != healthGrpc.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health check status is %v", response.Status)
+	}
+	return nil
+}
+
+// getConnection returns the cached connection for the node with ip address address,
+// a new connection is created if there is none or the cached connection has failed.
+// The health check is made without holding the mutex, so that calls to other nodes
+// are not delayed by an unresponsive node.
+func getConnection(address string) (*grpc.ClientConn, error) {
+	connMgr.mutex.Lock()
+	if err := connMgr.init(); err != nil {
+		connMgr.mutex.Unlock()
+		return nil, err
+	}
+	conn, ok := connMgr.conns[address]
+	if ok {
+		switch conn.GetState() {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			logf.Log.Info("Replacing gRPC connection", "address", address, "state", conn.GetState())
+			connMgr.remove(address)
+			ok = false
+		}
+	}
+	interval := time.Duration(e2e_config.GetConfig().Grpc.HealthCheckSecs) * time.Second
+	check := ok && interval != 0 && time.Since(connMgr.checked[address]) >= interval
+	if check {
+		// concurrent callers reuse the connection while it is checked
+		connMgr.checked[address] = time.Now()
+	}
+	connMgr.mutex.Unlock()
+
+	if ok {
+		if !check {
+			return conn, nil
+		}
+		err := healthCheck(conn)
+		if err == nil {
+			return conn, nil
+		}
+		logf.Log.Info("Replacing gRPC connection", "address", address, "health check error", err)
+	}
+
+	connMgr.mutex.Lock()
+	defer connMgr.mutex.Unlock()
+	if cached, found := connMgr.conns[address]; found {
+		if cached != conn {
+			// replaced by a concurrent caller
+			return cached, nil
+		}
+		connMgr.remove(address)
+	}
+	addrPort := fmt.Sprintf("%s:%d", address, e2e_config.GetConfig().Grpc.Port)
+	conn, err := grpc.Dial(addrPort, connMgr.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	connMgr.conns[address] = conn
+	connMgr.checked[address] = time.Now()
+	return conn, nil
+}
+
+// remove closes and removes the cached connection for address, must be called with the mutex held.
+func (cm *connManager) remove(address string) {
+	if conn, ok := cm.conns[address]; ok {
+		_ = conn.Close()
+		delete(cm.conns, address)
+		delete(cm.checked, address)
+	}
+}
+
+// callContext returns a context with the per-call deadline
+func callContext() (context.Context, context.CancelFunc) {
+	connMgr.mutex.Lock()
+	timeout := connMgr.callTimeout
+	connMgr.mutex.Unlock()
+	if timeout == 0 {
+		timeout = time.Duration(e2e_config.GetConfig().Grpc.CallTimeoutSecs) * time.Second
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// SetCallTimeout sets the deadline for subsequent gRPC calls, returns the previous deadline.
+// A timeout of 0 restores the configured deadline.
+func SetCallTimeout(timeout time.Duration) time.Duration {
+	connMgr.mutex.Lock()
+	defer connMgr.mutex.Unlock()
+	prev := connMgr.callTimeout
+	if prev == 0 {
+		prev = time.Duration(e2e_config.GetConfig().Grpc.CallTimeoutSecs) * time.Second
+	}
+	connMgr.callTimeout = timeout
+	return prev
+}
+
+// CloseConnections closes all cached connections
+func CloseConnections() {
+	connMgr.mutex.Lock()
+	defer connMgr.mutex.Unlock()
+	for address, conn := range connMgr.conns {
+		if err := conn.Close(); err != nil {
+			logf.Log.Info("CloseConnections", "address", address, "error on close", err)
+		}
+	}
+	connMgr.conns = make(map[string]*grpc.ClientConn)
+	connMgr.checked = make(map[string]time.Time)
+}
@@ -0,0 +1,85 @@
+package mayastorclient
+
+// Concurrent execution of gRPC calls on a set of nodes.
+// Cluster-wide listings call every node concurrently, with at most
+// E2EConfig.Grpc.MaxConcurrency calls in flight. The calls are made once,
+// without retryBackoff, so that an unreachable node delays the listing by
+// one call timeout and is reported in NodeErrors rather than stalling it.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"mayastor-e2e/common/e2e_config"
+)
+
+// NodeErrors errors of a call on multiple nodes, keyed by node ip address.
+// Results from the nodes without errors are returned alongside NodeErrors.
+type NodeErrors map[string]error
+
+// Addresses returns the ip addresses of the nodes on which the call failed, sorted
+func (ne NodeErrors) Addresses() []string {
+	var addrs []string
+	for address := range ne {
+		addrs = append(addrs, address)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func (ne NodeErrors) Error() string {
+	var errs []string
+	for _, address := range ne.Addresses() {
+		errs = append(errs, fmt.Sprintf("%s: %v", address, ne[address]))
+	}
+	return strings.Join(errs, ";")
+}
+
+// GetNodeErrors returns the per node errors of err, nil if err is not a NodeErrors
+func GetNodeErrors(err error) NodeErrors {
+	if ne, ok := err.(NodeErrors); ok {
+		return ne
+	}
+	return nil
+}
+
+// forEachNode calls fn concurrently for every address, ix is the index of the address in addrs.
+// Returns NodeErrors if any call failed, nil otherwise.
+func forEachNode(addrs []string, fn func(ix int, address string) error) error {
+	return fanOut(addrs, e2e_config.GetConfig().Grpc.MaxConcurrency, fn)
+}
+
+// fanOut calls fn for every address using at most workers goroutines, 0 means one per address
+func fanOut(addrs []string, workers int, fn func(ix int, address string) error) error {
+	if workers <= 0 || workers > len(addrs) {
+		workers = len(addrs)
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	nodeErrs := make(NodeErrors)
+	indices := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ix := range indices {
+				if err := fn(ix, addrs[ix]); err != nil {
+					mutex.Lock()
+					nodeErrs[addrs[ix]] = err
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for ix := range addrs {
+		indices <- ix
+	}
+	close(indices)
+	wg.Wait()
+	if len(nodeErrs) != 0 {
+		return nodeErrs
+	}
+	return nil
+}
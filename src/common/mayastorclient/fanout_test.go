@@ -0,0 +1,53 @@
+package mayastorclient
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOut(t *testing.T) {
+	addrs := []string{"10.1.0.2", "10.1.0.3", "10.1.0.4", "10.1.0.5", "10.1.0.6"}
+	var inFlight, maxInFlight int32
+	results := make([]string, len(addrs))
+	err := fanOut(addrs, 2, func(ix int, address string) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			prev := atomic.LoadInt32(&maxInFlight)
+			if n <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if address == "10.1.0.3" || address == "10.1.0.6" {
+			return fmt.Errorf("unreachable")
+		}
+		results[ix] = address
+		return nil
+	})
+	if maxInFlight > 2 {
+		t.Errorf("%d calls in flight, expected at most 2", maxInFlight)
+	}
+	nodeErrs := GetNodeErrors(err)
+	if nodeErrs == nil {
+		t.Fatalf("expected NodeErrors, got %v", err)
+	}
+	if addresses := nodeErrs.Addresses(); !reflect.DeepEqual(addresses, []string{"10.1.0.3", "10.1.0.6"}) {
+		t.Errorf("failed nodes %v", addresses)
+	}
+	if err.Error() != "10.1.0.3: unreachable;10.1.0.6: unreachable" {
+		t.Errorf("unexpected error string %s", err.Error())
+	}
+	if !reflect.DeepEqual(results, []string{"10.1.0.2", "", "10.1.0.4", "10.1.0.5", ""}) {
+		t.Errorf("unexpected results %v", results)
+	}
+	if err = fanOut(addrs, 0, func(ix int, address string) error { return nil }); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err = fanOut(nil, 0, func(ix int, address string) error { return nil }); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
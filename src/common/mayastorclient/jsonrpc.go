@@ -0,0 +1,61 @@
+package mayastorclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// SPDK json-rpc methods which report the data plane state useful for diagnosing failures
+var SpdkDiagnosticMethods = []string{
+	"bdev_get_bdevs",
+	"nvmf_get_subsystems",
+	"bdev_lvol_get_lvstores",
+}
+
+// JsonRpc invokes the SPDK json-rpc method on the node with ip address address
+// and returns the result. params is marshalled to JSON, if params is nil
+// the method is called without parameters.
+func JsonRpc(address string, method string, params interface{}) (json.RawMessage, error) {
+	return jsonRpc(address, method, params, true)
+}
+
+// jsonRpc invokes the SPDK json-rpc method, if retry is true the call is retried
+// while the deadline is exceeded
+func jsonRpc(address string, method string, params interface{}, retry bool) (json.RawMessage, error) {
+	var err error
+	var paramsJson []byte
+	if params != nil {
+		if paramsJson, err = json.Marshal(params); err != nil {
+			return nil, fmt.Errorf("failed to marshal json-rpc %s params, %v", method, err)
+		}
+	}
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("JsonRpc", "error", err)
+		return nil, err
+	}
+	c := mayastorGrpc.NewJsonRpcClient(conn)
+
+	req := mayastorGrpc.JsonRpcRequest{Method: method, Params: string(paramsJson)}
+	var response *mayastorGrpc.JsonRpcReply
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
+		response, err = c.JsonRpcCall(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to JsonRpc %s on %s", method, address)
+		} else {
+			return json.RawMessage(response.Result), nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("JsonRpc", "method", method, "error", err)
+	}
+	return nil, err
+}
@@ -5,20 +5,19 @@ import (
 	"fmt"
 	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
 
-	"google.golang.org/grpc"
-
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // MayastorNexus Mayastor Nexus data
 type MayastorNexus struct {
-	Name      string                  `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Uuid      string                  `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
-	Size      uint64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
-	State     mayastorGrpc.NexusState `protobuf:"varint,3,opt,name=state,proto3,enum=mayastor.NexusState" json:"state,omitempty"`
-	Children  []*mayastorGrpc.Child   `protobuf:"bytes,4,rep,name=children,proto3" json:"children,omitempty"`
-	DeviceUri string                  `protobuf:"bytes,5,opt,name=device_uri,json=deviceUri,proto3" json:"device_uri,omitempty"`
-	Rebuilds  uint32                  `protobuf:"varint,6,opt,name=rebuilds,proto3" json:"rebuilds,omitempty"`
+	Name      string                    `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Uuid      string                    `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Size      uint64                    `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	State     mayastorGrpc.NexusState   `protobuf:"varint,3,opt,name=state,proto3,enum=mayastor.NexusState" json:"state,omitempty"`
+	Children  []*mayastorGrpc.Child     `protobuf:"bytes,4,rep,name=children,proto3" json:"children,omitempty"`
+	DeviceUri string                    `protobuf:"bytes,5,opt,name=device_uri,json=deviceUri,proto3" json:"device_uri,omitempty"`
+	Rebuilds  uint32                    `protobuf:"varint,6,opt,name=rebuilds,proto3" json:"rebuilds,omitempty"`
+	AnaState  mayastorGrpc.NvmeAnaState `protobuf:"varint,8,opt,name=ana_state,json=anaState,proto3,enum=mayastor.NvmeAnaState" json:"ana_state,omitempty"`
 }
 
 func (msn MayastorNexus) String() string {
@@ -27,33 +26,23 @@ func (msn MayastorNexus) String() string {
 		descChildren = fmt.Sprintf("%s(%v); ", descChildren, child)
 	}
 	descChildren += "]"
-	return fmt.Sprintf("Uuid=%s; Size=%d; State=%v; DeviceUri=%s, Rebuilds=%d; Children=%v",
-		msn.Uuid, msn.Size, msn.State, msn.DeviceUri, msn.Rebuilds, descChildren)
+	return fmt.Sprintf("Uuid=%s; Size=%d; State=%v; DeviceUri=%s, Rebuilds=%d; AnaState=%v; Children=%v",
+		msn.Uuid, msn.Size, msn.State, msn.DeviceUri, msn.Rebuilds, msn.AnaState, descChildren)
 }
 
-func listNexuses(address string) ([]MayastorNexus, error) {
+func listNexuses(address string, retry bool) ([]MayastorNexus, error) {
 	var nexusInfos []MayastorNexus
 	var err error
 
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		logf.Log.Info("listNexuses", "error", err)
 		return nexusInfos, err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("ListNexuses", "error on close", err)
-		}
-	}(conn)
-
 	c := mayastorGrpc.NewMayastorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
-	defer cancel()
 
 	var response *mayastorGrpc.ListNexusV2Reply
-	retryBackoff(func() error {
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
 		response, err = c.ListNexusV2(ctx, &null)
 		return err
 	})
@@ -69,6 +58,7 @@ func listNexuses(address string) ([]MayastorNexus, error) {
 					Children:  nexus.Children,
 					DeviceUri: nexus.DeviceUri,
 					Rebuilds:  nexus.Rebuilds,
+					AnaState:  nexus.AnaState,
 				}
 				nexusInfos = append(nexusInfos, ni)
 			}
@@ -84,50 +74,36 @@ func listNexuses(address string) ([]MayastorNexus, error) {
 }
 
 // ListNexuses given a list of node ip addresses, enumerate the set of nexuses on mayastor using gRPC on each of those nodes
-// returns accumulated errors if gRPC communication failed.
+// the nodes are called concurrently, returns NodeErrors and the results from the other nodes if gRPC communication failed.
 func ListNexuses(addrs []string) ([]MayastorNexus, error) {
-	var accErr error
+	results := make([][]MayastorNexus, len(addrs))
+	err := forEachNode(addrs, func(ix int, address string) (err error) {
+		results[ix], err = listNexuses(address, false)
+		return err
+	})
 	var nexusInfos []MayastorNexus
-	for _, address := range addrs {
-		nexusInfo, err := listNexuses(address)
-		if err == nil {
-			nexusInfos = append(nexusInfos, nexusInfo...)
-		} else {
-			if accErr != nil {
-				accErr = fmt.Errorf("%v;%v", accErr, err)
-			} else {
-				accErr = err
-			}
-		}
+	for _, result := range results {
+		nexusInfos = append(nexusInfos, result...)
 	}
-	return nexusInfos, accErr
+	return nexusInfos, err
 }
 
 func FaultNexusChild(address string, Uuid string, Uri string) error {
 	var err error
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		logf.Log.Info("FaultNexusChild", "error", err)
 		return err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("FaultNexusChild", "error on close", err)
-		}
-	}(conn)
-
 	c := mayastorGrpc.NewMayastorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
-	defer cancel()
 
 	faultRequest := mayastorGrpc.FaultNexusChildRequest{
 		Uuid: Uuid,
 		Uri:  Uri,
 	}
 	var response *mayastorGrpc.Null
-	retryBackoff(func() error {
+	// not retried, an attempt which exceeded its deadline may have succeeded
+	err = callWithRetry(false, func(ctx context.Context) (err error) {
 		response, err = c.FaultNexusChild(ctx, &faultRequest)
 		return err
 	})
@@ -149,7 +125,7 @@ func FaultNexusChild(address string, Uuid string, Uri string) error {
 func FindNexus(uuid string, addrs []string) (*MayastorNexus, error) {
 	var accErr error
 	for _, address := range addrs {
-		nexusInfos, err := listNexuses(address)
+		nexusInfos, err := listNexuses(address, true)
 		if err == nil {
 			for _, ni := range nexusInfos {
 				if ni.Uuid == uuid {
@@ -166,3 +142,128 @@ func FindNexus(uuid string, addrs []string) (*MayastorNexus, error) {
 	}
 	return nil, accErr
 }
+
+// nexus controller id range and reservation key used by CreateNexusV2,
+// the same as those used by the control plane
+const (
+	nexusMinCntlId = 1
+	nexusMaxCntlId = 0xffef
+	nexusResvKey   = 0x12345678
+)
+
+// CreateNexusV2 create a nexus with name and uuid on the node with ip address address,
+// children is the list of uris of the nexus children.
+func CreateNexusV2(address string, name string, uuid string, size uint64, children []string) (*MayastorNexus, error) {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("CreateNexusV2", "error", err)
+		return nil, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.CreateNexusV2Request{
+		Name:      name,
+		Uuid:      uuid,
+		Size:      size,
+		MinCntlId: nexusMinCntlId,
+		MaxCntlId: nexusMaxCntlId,
+		ResvKey:   nexusResvKey,
+		Children:  children,
+	}
+	var response *mayastorGrpc.Nexus
+	// not retried, a retry after an attempt which exceeded its deadline but succeeded would fail
+	err = callWithRetry(false, func(ctx context.Context) error {
+		response, err = c.CreateNexusV2(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to CreateNexusV2")
+		} else {
+			return &MayastorNexus{
+				Name:      name,
+				Uuid:      response.Uuid,
+				Size:      response.Size,
+				State:     response.State,
+				Children:  response.Children,
+				DeviceUri: response.DeviceUri,
+				Rebuilds:  response.Rebuilds,
+			}, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("CreateNexusV2", "error", err)
+	}
+	return nil, err
+}
+
+// DestroyNexus destroy the nexus with uuid on the node with ip address address
+func DestroyNexus(address string, uuid string) error {
+	req := mayastorGrpc.DestroyNexusRequest{Uuid: uuid}
+	return nullResponseCall(address, "DestroyNexus", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.DestroyNexus(ctx, &req)
+	})
+}
+
+// AddChildNexus add the child with uri to the nexus with uuid on the node with ip address address,
+// if norebuild is false a rebuild of the child is started.
+func AddChildNexus(address string, uuid string, uri string, norebuild bool) (*mayastorGrpc.Child, error) {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("AddChildNexus", "error", err)
+		return nil, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.AddChildNexusRequest{
+		Uuid:      uuid,
+		Uri:       uri,
+		Norebuild: norebuild,
+	}
+	var response *mayastorGrpc.Child
+	// not retried, a retry after an attempt which exceeded its deadline but succeeded would fail
+	err = callWithRetry(false, func(ctx context.Context) error {
+		response, err = c.AddChildNexus(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to AddChildNexus")
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("AddChildNexus", "error", err)
+	}
+	return response, err
+}
+
+// RemoveChildNexus remove the child with uri from the nexus with uuid on the node with ip address address
+func RemoveChildNexus(address string, uuid string, uri string) error {
+	req := mayastorGrpc.RemoveChildNexusRequest{Uuid: uuid, Uri: uri}
+	return nullResponseCall(address, "RemoveChildNexus", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.RemoveChildNexus(ctx, &req)
+	})
+}
+
+// ChildOperation perform action (offline or online) on the child with uri of the nexus with uuid
+// on the node with ip address address
+func ChildOperation(address string, uuid string, uri string, action mayastorGrpc.ChildAction) error {
+	req := mayastorGrpc.ChildNexusRequest{Uuid: uuid, Uri: uri, Action: action}
+	return nullResponseCall(address, "ChildOperation", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.ChildOperation(ctx, &req)
+	})
+}
+
+// OfflineNexusChild take the child with uri of the nexus with uuid offline
+func OfflineNexusChild(address string, uuid string, uri string) error {
+	return ChildOperation(address, uuid, uri, mayastorGrpc.ChildAction_offline)
+}
+
+// OnlineNexusChild bring the child with uri of the nexus with uuid online
+func OnlineNexusChild(address string, uuid string, uri string) error {
+	return ChildOperation(address, uuid, uri, mayastorGrpc.ChildAction_online)
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
 
-	"google.golang.org/grpc"
-
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -25,28 +23,18 @@ func (msr NvmeController) String() string {
 		msr.Name, msr.State, msr.Size, msr.BlkSize)
 }
 
-func listNvmeController(address string) ([]NvmeController, error) {
+func listNvmeController(address string, retry bool) ([]NvmeController, error) {
 	var nvmeControllers []NvmeController
 	var err error
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		logf.Log.Info("listReplica", "error", err)
 		return nvmeControllers, err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("listReplicas", "error on close", err)
-		}
-	}(conn)
-
 	c := mayastorGrpc.NewMayastorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
-	defer cancel()
 
 	var response *mayastorGrpc.ListNvmeControllersReply
-	retryBackoff(func() error {
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
 		response, err = c.ListNvmeControllers(ctx, &null)
 		return err
 	})
@@ -73,21 +61,16 @@ func listNvmeController(address string) ([]NvmeController, error) {
 }
 
 // ListNvmeControllers given a list of node ip addresses, enumerate the set of nvmeControllers on mayastor using gRPC on each of those nodes
-// returns accumulated errors if gRPC communication failed.
+// the nodes are called concurrently, returns NodeErrors and the results from the other nodes if gRPC communication failed.
 func ListNvmeControllers(addrs []string) ([]NvmeController, error) {
-	var accErr error
+	results := make([][]NvmeController, len(addrs))
+	err := forEachNode(addrs, func(ix int, address string) (err error) {
+		results[ix], err = listNvmeController(address, false)
+		return err
+	})
 	var nvmeControllers []NvmeController
-	for _, address := range addrs {
-		nvmeController, err := listNvmeController(address)
-		if err == nil {
-			nvmeControllers = append(nvmeControllers, nvmeController...)
-		} else {
-			if accErr != nil {
-				accErr = fmt.Errorf("%v;%v", accErr, err)
-			} else {
-				accErr = err
-			}
-		}
+	for _, result := range results {
+		nvmeControllers = append(nvmeControllers, result...)
 	}
-	return nvmeControllers, accErr
+	return nvmeControllers, err
 }
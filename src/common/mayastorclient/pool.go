@@ -6,7 +6,6 @@ import (
 	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
 	"time"
 
-	"google.golang.org/grpc"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -29,28 +28,18 @@ func (msp MayastorPool) String() string {
 		msp.Name, msp.Disks, msp.State, msp.Used, msp.Capacity)
 }
 
-func listPool(address string) ([]MayastorPool, error) {
+func listPool(address string, retry bool) ([]MayastorPool, error) {
 	var poolInfos []MayastorPool
 	var err error
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		logf.Log.Info("listPool", "error", err)
 		return poolInfos, err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("listPool", "error on close", err)
-		}
-	}(conn)
-
 	c := mayastorGrpc.NewMayastorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
-	defer cancel()
 
 	var response *mayastorGrpc.ListPoolsReply
-	retryBackoff(func() error {
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
 		response, err = c.ListPools(ctx, &null)
 		return err
 	})
@@ -79,7 +68,7 @@ func listPool(address string) ([]MayastorPool, error) {
 }
 
 func GetPool(name, addr string) (*MayastorPool, error) {
-	poolInfo, err := listPool(addr)
+	poolInfo, err := listPool(addr, true)
 	if err != nil {
 		return nil, err
 	}
@@ -92,29 +81,24 @@ func GetPool(name, addr string) (*MayastorPool, error) {
 }
 
 // ListPools given a list of node ip addresses, enumerate the set of pools on mayastor using gRPC on each of those nodes
-// returns accumulated errors if gRPC communication failed.
+// the nodes are called concurrently, returns NodeErrors and the results from the other nodes if gRPC communication failed.
 func ListPools(addrs []string) ([]MayastorPool, error) {
-	var accErr error
+	results := make([][]MayastorPool, len(addrs))
+	err := forEachNode(addrs, func(ix int, address string) (err error) {
+		results[ix], err = listPool(address, false)
+		return err
+	})
 	var poolInfos []MayastorPool
-	for _, address := range addrs {
-		poolInfo, err := listPool(address)
-		if err == nil {
-			poolInfos = append(poolInfos, poolInfo...)
-		} else {
-			if accErr != nil {
-				accErr = fmt.Errorf("%v;%v", accErr, err)
-			} else {
-				accErr = err
-			}
-		}
+	for _, result := range results {
+		poolInfos = append(poolInfos, result...)
 	}
-	return poolInfos, accErr
+	return poolInfos, err
 }
 
 func DestroyAllPools(addrs []string) error {
 
 	for _, addr := range addrs {
-		poolInfo, err := listPool(addr)
+		poolInfo, err := listPool(addr, true)
 		if err != nil {
 			return err
 		}
@@ -134,19 +118,11 @@ func DestroyAllPools(addrs []string) error {
 
 func DestroyPool(name, addr string) error {
 	var err error
-	addrPort := fmt.Sprintf("%s:%d", addr, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(addr)
 	if err != nil {
 		logf.Log.Info("destroyPool", "error", err)
 		return err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("destroyPool", "error on close", err)
-		}
-	}(conn)
-
 	c := mayastorGrpc.NewMayastorClient(conn)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,138 @@
+package mayastorclient
+
+// Selection of the devices used for pools.
+// With PoolPlacement "device" a pool is created on PoolDevice on every mayastor node.
+// With PoolPlacement "discover" the block devices on each mayastor node are listed
+// using mayastor, and a pool is created on every unused device matching PoolDiscovery,
+// so that one configuration can be used on clusters with different disk layouts.
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+
+	"mayastor-e2e/common/e2e_config"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	PoolPlacementDevice   = "device"
+	PoolPlacementDiscover = "discover"
+)
+
+type poolDeviceFilter struct {
+	minSize    uint64
+	maxSize    uint64
+	model      *regexp.Regexp
+	path       *regexp.Regexp
+	maxDevices int
+}
+
+func newPoolDeviceFilter() (*poolDeviceFilter, error) {
+	cfg := e2e_config.GetConfig().PoolDiscovery
+	filter := poolDeviceFilter{
+		minSize:    cfg.MinSizeMb * 1024 * 1024,
+		maxSize:    cfg.MaxSizeMb * 1024 * 1024,
+		maxDevices: cfg.MaxDevicesPerNode,
+	}
+	var err error
+	if cfg.ModelPattern != "" {
+		if filter.model, err = regexp.Compile(cfg.ModelPattern); err != nil {
+			return nil, fmt.Errorf("invalid pool discovery model pattern %s, %v", cfg.ModelPattern, err)
+		}
+	}
+	if cfg.PathPattern != "" {
+		if filter.path, err = regexp.Compile(cfg.PathPattern); err != nil {
+			return nil, fmt.Errorf("invalid pool discovery path pattern %s, %v", cfg.PathPattern, err)
+		}
+	}
+	return &filter, nil
+}
+
+func (f *poolDeviceFilter) pathMatches(dev BlockDevice) bool {
+	if f.path == nil || f.path.MatchString(dev.Devname) {
+		return true
+	}
+	for _, link := range dev.Devlinks {
+		if f.path.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+func deviceInUse(dev BlockDevice, inUse map[string]bool) bool {
+	if inUse[dev.Devname] {
+		return true
+	}
+	for _, link := range dev.Devlinks {
+		if inUse[link] {
+			return true
+		}
+	}
+	return false
+}
+
+// selectPoolDevices returns the names of the devices which match the filter
+// and are not in use, sorted by name.
+func (f *poolDeviceFilter) selectPoolDevices(devices []BlockDevice, inUse map[string]bool) []string {
+	var selected []string
+	for _, dev := range devices {
+		if !dev.Available || dev.Fstype != "" || dev.Mountpoint != "" || deviceInUse(dev, inUse) {
+			continue
+		}
+		if dev.Size < f.minSize || (f.maxSize != 0 && dev.Size > f.maxSize) {
+			continue
+		}
+		if f.model != nil && !f.model.MatchString(dev.Model) {
+			continue
+		}
+		if !f.pathMatches(dev) {
+			continue
+		}
+		selected = append(selected, dev.Devname)
+	}
+	sort.Strings(selected)
+	if f.maxDevices != 0 && len(selected) > f.maxDevices {
+		selected = selected[:f.maxDevices]
+	}
+	return selected
+}
+
+// poolDiskPath returns the device path of a pool disk, which may be a URI, for example aio:///dev/sdb
+func poolDiskPath(disk string) string {
+	u, err := url.Parse(disk)
+	if err != nil || u.Path == "" {
+		return disk
+	}
+	return u.Path
+}
+
+// DiscoverPoolDevices returns the unused devices on the node with ip address address
+// which match the pool discovery configuration.
+// Devices used by existing pools on the node are excluded.
+func DiscoverPoolDevices(address string) ([]string, error) {
+	filter, err := newPoolDeviceFilter()
+	if err != nil {
+		return nil, err
+	}
+	devices, err := ListBlockDevices(address, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list block devices on %s, error: %v", address, err)
+	}
+	pools, err := ListPools([]string{address})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pools on %s, error: %v", address, err)
+	}
+	inUse := make(map[string]bool)
+	for _, pool := range pools {
+		for _, disk := range pool.Disks {
+			inUse[poolDiskPath(disk)] = true
+		}
+	}
+	selected := filter.selectPoolDevices(devices, inUse)
+	logf.Log.Info("DiscoverPoolDevices", "address", address, "devices", devices, "selected", selected)
+	return selected, nil
+}
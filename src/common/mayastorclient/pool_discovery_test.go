@@ -0,0 +1,61 @@
+package mayastorclient
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+const gib = 1024 * 1024 * 1024
+
+var testBlockDevices = []BlockDevice{
+	{Devname: "/dev/sdc", Model: "Virtual_disk", Size: 20 * gib, Available: true,
+		Devlinks: []string{"/dev/disk/by-id/scsi-0002", "/dev/disk/by-path/pci-0000:00:10.0-scsi-0:0:2:0"}},
+	{Devname: "/dev/sdb", Model: "Virtual_disk", Size: 10 * gib, Available: true,
+		Devlinks: []string{"/dev/disk/by-id/scsi-0001", "/dev/disk/by-path/pci-0000:00:10.0-scsi-0:0:1:0"}},
+	{Devname: "/dev/sda", Model: "Virtual_disk", Size: 40 * gib, Available: false},
+	{Devname: "/dev/sdd", Model: "Virtual_disk", Size: 20 * gib, Available: true, Fstype: "ext4"},
+	{Devname: "/dev/nvme0n1", Model: "Samsung SSD 970", Size: 500 * gib, Available: true,
+		Devlinks: []string{"/dev/disk/by-id/nvme-Samsung_SSD_970"}},
+	{Devname: "/dev/nvme1n1", Model: "Samsung SSD 970", Size: 500 * gib, Available: true},
+}
+
+func TestSelectPoolDevices(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   poolDeviceFilter
+		inUse    map[string]bool
+		expected []string
+	}{
+		{"all unused", poolDeviceFilter{}, nil,
+			[]string{"/dev/nvme0n1", "/dev/nvme1n1", "/dev/sdb", "/dev/sdc"}},
+		{"in use by pool", poolDeviceFilter{}, map[string]bool{"/dev/disk/by-id/scsi-0001": true, "/dev/nvme1n1": true},
+			[]string{"/dev/nvme0n1", "/dev/sdc"}},
+		{"size", poolDeviceFilter{minSize: 15 * gib, maxSize: 100 * gib}, nil,
+			[]string{"/dev/sdc"}},
+		{"model", poolDeviceFilter{model: regexp.MustCompile("^Samsung")}, nil,
+			[]string{"/dev/nvme0n1", "/dev/nvme1n1"}},
+		{"path matches link", poolDeviceFilter{path: regexp.MustCompile("^/dev/disk/by-path/pci-0000:00:10.0-scsi")}, nil,
+			[]string{"/dev/sdb", "/dev/sdc"}},
+		{"max devices", poolDeviceFilter{maxDevices: 1, path: regexp.MustCompile("^/dev/sd")}, nil,
+			[]string{"/dev/sdb"}},
+	}
+	for _, tc := range tests {
+		selected := tc.filter.selectPoolDevices(testBlockDevices, tc.inUse)
+		if !reflect.DeepEqual(selected, tc.expected) {
+			t.Errorf("%s: selected %v, expected %v", tc.name, selected, tc.expected)
+		}
+	}
+}
+
+func TestPoolDiskPath(t *testing.T) {
+	for disk, expected := range map[string]string{
+		"/dev/sdb":                          "/dev/sdb",
+		"aio:///dev/sdb":                    "/dev/sdb",
+		"uring:///dev/nvme0n1?blk_size=512": "/dev/nvme0n1",
+	} {
+		if path := poolDiskPath(disk); path != expected {
+			t.Errorf("poolDiskPath(%s) = %s, expected %s", disk, path, expected)
+		}
+	}
+}
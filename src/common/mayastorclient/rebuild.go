@@ -0,0 +1,168 @@
+package mayastorclient
+
+import (
+	"context"
+	"fmt"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RebuildStats rebuild statistics for a nexus child
+type RebuildStats struct {
+	BlocksTotal     uint64 `json:"blocks_total"`
+	BlocksRecovered uint64 `json:"blocks_recovered"`
+	Progress        uint64 `json:"progress"`
+	SegmentSizeBlks uint64 `json:"segment_size_blks"`
+	BlockSize       uint64 `json:"block_size"`
+	TasksTotal      uint64 `json:"tasks_total"`
+	TasksActive     uint64 `json:"tasks_active"`
+}
+
+func (rs RebuildStats) String() string {
+	return fmt.Sprintf("BlocksTotal=%d; BlocksRecovered=%d; Progress=%d%%; SegmentSizeBlks=%d; BlockSize=%d; TasksTotal=%d; TasksActive=%d",
+		rs.BlocksTotal, rs.BlocksRecovered, rs.Progress, rs.SegmentSizeBlks, rs.BlockSize, rs.TasksTotal, rs.TasksActive)
+}
+
+// GetRebuildState returns the rebuild state (ready/running/completed etc.) of
+// the nexus child with uri childUri, on the node with ip address address
+func GetRebuildState(address string, nexusUuid string, childUri string) (string, error) {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("GetRebuildState", "error", err)
+		return "", err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.RebuildStateRequest{
+		Uuid: nexusUuid,
+		Uri:  childUri,
+	}
+	var response *mayastorGrpc.RebuildStateReply
+	// not retried, callers poll the rebuild while it runs
+	err = callWithRetry(false, func(ctx context.Context) (err error) {
+		response, err = c.GetRebuildState(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to GetRebuildState")
+		} else {
+			return response.State, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("GetRebuildState", "error", err)
+	}
+	return "", err
+}
+
+// GetRebuildStats returns the rebuild statistics of the nexus child with uri childUri,
+// on the node with ip address address
+func GetRebuildStats(address string, nexusUuid string, childUri string) (*RebuildStats, error) {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("GetRebuildStats", "error", err)
+		return nil, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+	ctx, cancel := callContext()
+	defer cancel()
+
+	req := mayastorGrpc.RebuildStatsRequest{
+		Uuid: nexusUuid,
+		Uri:  childUri,
+	}
+	// not retried, callers poll the statistics while the rebuild runs
+	response, err := c.GetRebuildStats(ctx, &req)
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to GetRebuildStats")
+		} else {
+			return &RebuildStats{
+				BlocksTotal:     response.BlocksTotal,
+				BlocksRecovered: response.BlocksRecovered,
+				Progress:        response.Progress,
+				SegmentSizeBlks: response.SegmentSizeBlks,
+				BlockSize:       response.BlockSize,
+				TasksTotal:      response.TasksTotal,
+				TasksActive:     response.TasksActive,
+			}, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("GetRebuildStats", "error", err)
+	}
+	return nil, err
+}
+
+// GetRebuildProgress returns the rebuild progress percentage of the nexus child
+// with uri childUri, on the node with ip address address
+func GetRebuildProgress(address string, nexusUuid string, childUri string) (uint32, error) {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("GetRebuildProgress", "error", err)
+		return 0, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.RebuildProgressRequest{
+		Uuid: nexusUuid,
+		Uri:  childUri,
+	}
+	var response *mayastorGrpc.RebuildProgressReply
+	// not retried, callers poll the rebuild while it runs
+	err = callWithRetry(false, func(ctx context.Context) (err error) {
+		response, err = c.GetRebuildProgress(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to GetRebuildProgress")
+		} else {
+			return response.Progress, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("GetRebuildProgress", "error", err)
+	}
+	return 0, err
+}
+
+// StartRebuild starts a rebuild of the nexus child with uri childUri
+func StartRebuild(address string, nexusUuid string, childUri string) error {
+	req := mayastorGrpc.StartRebuildRequest{Uuid: nexusUuid, Uri: childUri}
+	return nullResponseCall(address, "StartRebuild", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.StartRebuild(ctx, &req)
+	})
+}
+
+// StopRebuild stops the rebuild of the nexus child with uri childUri
+func StopRebuild(address string, nexusUuid string, childUri string) error {
+	req := mayastorGrpc.StopRebuildRequest{Uuid: nexusUuid, Uri: childUri}
+	return nullResponseCall(address, "StopRebuild", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.StopRebuild(ctx, &req)
+	})
+}
+
+// PauseRebuild pauses the rebuild of the nexus child with uri childUri
+func PauseRebuild(address string, nexusUuid string, childUri string) error {
+	req := mayastorGrpc.PauseRebuildRequest{Uuid: nexusUuid, Uri: childUri}
+	return nullResponseCall(address, "PauseRebuild", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.PauseRebuild(ctx, &req)
+	})
+}
+
+// ResumeRebuild resumes a paused rebuild of the nexus child with uri childUri
+func ResumeRebuild(address string, nexusUuid string, childUri string) error {
+	req := mayastorGrpc.ResumeRebuildRequest{Uuid: nexusUuid, Uri: childUri}
+	return nullResponseCall(address, "ResumeRebuild", func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error) {
+		return c.ResumeRebuild(ctx, &req)
+	})
+}
@@ -6,19 +6,27 @@ import (
 	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
 	"time"
 
-	"google.golang.org/grpc"
-
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // MayastorReplica Mayastor Replica data
+// Name is the name of the replica on the mayastor node, for replicas created
+// using the v1 protocol the name is the replica uuid.
+// Size is the logical size of the replica, the protocol does not report the
+// space allocated to individual thin provisioned replicas, use the used
+// capacity of the pool instead.
 type MayastorReplica struct {
-	Uuid  string                            `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`                                       // uuid of the replica
-	Pool  string                            `protobuf:"bytes,2,opt,name=pool,proto3" json:"pool,omitempty"`                                       // name of the pool
-	Thin  bool                              `protobuf:"varint,3,opt,name=thin,proto3" json:"thin,omitempty"`                                      // thin provisioning
-	Size  uint64                            `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`                                      // size of the replica in bytes
-	Share mayastorGrpc.ShareProtocolReplica `protobuf:"varint,5,opt,name=share,proto3,enum=mayastor.ShareProtocolReplica" json:"share,omitempty"` // protocol used for exposing the replica
-	Uri   string                            `protobuf:"bytes,6,opt,name=uri,proto3" json:"uri,omitempty"`                                         // uri usable by nexus to access it
+	Name  string                            `json:"name,omitempty"`  // name of the replica
+	Uuid  string                            `json:"uuid,omitempty"`  // uuid of the replica
+	Pool  string                            `json:"pool,omitempty"`  // name of the pool
+	Thin  bool                              `json:"thin,omitempty"`  // thin provisioning
+	Size  uint64                            `json:"size,omitempty"`  // size of the replica in bytes
+	Share mayastorGrpc.ShareProtocolReplica `json:"share,omitempty"` // protocol used for exposing the replica
+	Uri   string                            `json:"uri,omitempty"`   // uri usable by nexus to access it
+	// PoolUuid and Allocated, the bytes allocated from the pool, are obtained
+	// from SPDK, they are unset if SPDK does not report them
+	PoolUuid  string `json:"poolUuid,omitempty"`
+	Allocated uint64 `json:"allocated,omitempty"`
 }
 
 type MayastorReplicaArray []MayastorReplica
@@ -28,33 +36,23 @@ func (msr MayastorReplicaArray) Less(i, j int) bool { return msr[i].Pool < msr[j
 func (msr MayastorReplicaArray) Swap(i, j int)      { msr[i], msr[j] = msr[j], msr[i] }
 
 func (msr MayastorReplica) String() string {
-	return fmt.Sprintf("Uuid=%s; Pool=%s; Thin=%v; Size=%d; Share=%s; Uri=%s;",
-		msr.Uuid, msr.Pool, msr.Thin, msr.Size, msr.Share, msr.Uri)
+	return fmt.Sprintf("Name=%s; Uuid=%s; Pool=%s; PoolUuid=%s; Thin=%v; Size=%d; Allocated=%d; Share=%s; Uri=%s;",
+		msr.Name, msr.Uuid, msr.Pool, msr.PoolUuid, msr.Thin, msr.Size, msr.Allocated, msr.Share, msr.Uri)
 }
 
-func listReplica(address string) ([]MayastorReplica, error) {
+func listReplica(address string, retry bool) ([]MayastorReplica, error) {
 	var replicaInfos []MayastorReplica
 	var err error
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		logf.Log.Info("listReplica", "error", err)
 		return replicaInfos, err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("listReplicas", "error on close", err)
-		}
-	}(conn)
-
 	c := mayastorGrpc.NewMayastorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
-	defer cancel()
 
-	var response *mayastorGrpc.ListReplicasReply
-	retryBackoff(func() error {
-		response, err = c.ListReplicas(ctx, &null)
+	var response *mayastorGrpc.ListReplicasReplyV2
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
+		response, err = c.ListReplicasV2(ctx, &null)
 		return err
 	})
 
@@ -62,6 +60,7 @@ func listReplica(address string) ([]MayastorReplica, error) {
 		if response != nil {
 			for _, replica := range response.Replicas {
 				ri := MayastorReplica{
+					Name:  replica.Name,
 					Uuid:  replica.Uuid,
 					Pool:  replica.Pool,
 					Thin:  replica.Thin,
@@ -71,8 +70,11 @@ func listReplica(address string) ([]MayastorReplica, error) {
 				}
 				replicaInfos = append(replicaInfos, ri)
 			}
+			if len(replicaInfos) != 0 {
+				setReplicaSpace(address, replicaInfos, retry)
+			}
 		} else {
-			err = fmt.Errorf("nil response for ListReplicas on %s", address)
+			err = fmt.Errorf("nil response for ListReplicasV2 on %s", address)
 			logf.Log.Info("listReplicas", "error", err)
 		}
 	} else {
@@ -82,21 +84,15 @@ func listReplica(address string) ([]MayastorReplica, error) {
 	return replicaInfos, err
 }
 
-// RmReplica remove a replica identified by node and uuid
+// RmReplica remove a replica identified by node and uuid,
+// use RmListedReplica for replicas listed using ListReplicas
 func RmReplica(address string, uuid string) error {
 	logf.Log.Info("RmReplica", "address", address, "UUID", uuid)
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		logf.Log.Info("rmReplicas", "error", err)
 		return err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("RmReplicas", "error on close", err)
-		}
-	}(conn)
 	c := mayastorGrpc.NewMayastorClient(conn)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
@@ -111,23 +107,26 @@ func RmReplica(address string, uuid string) error {
 	return niceError(err)
 }
 
+// RmListedReplica remove a replica listed using ListReplicas on the node with ip address address.
+// The DestroyReplica call identifies the replica by uuid, replicas with a name which is not their
+// uuid are not removed, as the replica identified by the uuid may be a different replica.
+func RmListedReplica(address string, replica MayastorReplica) error {
+	if replica.Name != replica.Uuid {
+		return fmt.Errorf("replica %s on %s has uuid %s, cannot remove a replica with a name other than its uuid", replica.Name, address, replica.Uuid)
+	}
+	return RmReplica(address, replica.Uuid)
+}
+
 // CreateReplicaExt create a replica on a mayastor node
 func CreateReplicaExt(address string, uuid string, size uint64, pool string, thin bool, shareProto mayastorGrpc.ShareProtocolReplica) error {
 	logf.Log.Info("CreateReplica", "address", address, "UUID", uuid, "size", size, "pool", pool, "Thin", thin, "Share", shareProto)
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
 	var err error
 
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		logf.Log.Info("createReplica", "error", err)
 		return err
 	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
-		if err != nil {
-			logf.Log.Info("CreateReplicaExt", "error on close", err)
-		}
-	}(conn)
 	c := mayastorGrpc.NewMayastorClient(conn)
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -156,23 +155,18 @@ func CreateReplica(address string, uuid string, size uint64, pool string) error
 }
 
 // ListReplicas given a list of node ip addresses, enumerate the set of replicas on mayastor using gRPC on each of those nodes
-// returns accumulated errors if gRPC communication failed.
+// the nodes are called concurrently, returns NodeErrors and the results from the other nodes if gRPC communication failed.
 func ListReplicas(addrs []string) ([]MayastorReplica, error) {
-	var accErr error
+	results := make([][]MayastorReplica, len(addrs))
+	err := forEachNode(addrs, func(ix int, address string) (err error) {
+		results[ix], err = listReplica(address, false)
+		return err
+	})
 	var replicaInfos []MayastorReplica
-	for _, address := range addrs {
-		replicaInfo, err := listReplica(address)
-		if err == nil {
-			replicaInfos = append(replicaInfos, replicaInfo...)
-		} else {
-			if accErr != nil {
-				accErr = fmt.Errorf("%v;%v", accErr, err)
-			} else {
-				accErr = err
-			}
-		}
+	for _, result := range results {
+		replicaInfos = append(replicaInfos, result...)
 	}
-	return replicaInfos, accErr
+	return replicaInfos, err
 }
 
 // RmNodeReplicas given a list of node ip addresses, delete the set of replicas on mayastor using gRPC on each of those nodes
@@ -180,10 +174,10 @@ func ListReplicas(addrs []string) ([]MayastorReplica, error) {
 func RmNodeReplicas(addrs []string) error {
 	var accErr error
 	for _, address := range addrs {
-		replicaInfos, err := listReplica(address)
+		replicaInfos, err := listReplica(address, true)
 		if err == nil {
 			for _, replicaInfo := range replicaInfos {
-				err = RmReplica(address, replicaInfo.Uuid)
+				err = RmListedReplica(address, replicaInfo)
 			}
 		}
 		if err != nil {
@@ -203,10 +197,10 @@ func FindReplicas(uuid string, addrs []string) ([]MayastorReplica, error) {
 	var accErr error
 	var replicaInfos []MayastorReplica
 	for _, address := range addrs {
-		replicaInfo, err := listReplica(address)
+		replicaInfo, err := listReplica(address, true)
 		if err == nil {
 			for _, repl := range replicaInfo {
-				if repl.Uuid == uuid {
+				if repl.Uuid == uuid || repl.Name == uuid {
 					replicaInfos = append(replicaInfos, repl)
 				}
 			}
@@ -220,3 +214,35 @@ func FindReplicas(uuid string, addrs []string) ([]MayastorReplica, error) {
 	}
 	return replicaInfos, accErr
 }
+
+// ShareReplica shares or unshares a replica on a mayastor node, returns the uri of the replica
+func ShareReplica(address string, uuid string, shareProto mayastorGrpc.ShareProtocolReplica) (string, error) {
+	logf.Log.Info("ShareReplica", "address", address, "UUID", uuid, "Share", shareProto)
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("ShareReplica", "error", err)
+		return "", err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.ShareReplicaRequest{Uuid: uuid, Share: shareProto}
+	var response *mayastorGrpc.ShareReplicaReply
+	// not retried, an attempt which exceeded its deadline may have succeeded
+	err = callWithRetry(false, func(ctx context.Context) (err error) {
+		response, err = c.ShareReplica(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to ShareReplica")
+		} else {
+			return response.Uri, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("ShareReplica", "error", err)
+	}
+	return "", err
+}
@@ -0,0 +1,103 @@
+package mayastorclient
+
+// Space accounting of replicas.
+// ListReplicasV2 reports the logical size of a replica but neither the space
+// allocated from the pool nor the pool uuid. Replicas are SPDK lvols in the
+// lvol store of the pool, so these are obtained from SPDK using json-rpc.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type spdkLvolStore struct {
+	Uuid        string `json:"uuid"`
+	Name        string `json:"name"`
+	ClusterSize uint64 `json:"cluster_size"`
+}
+
+type spdkBdev struct {
+	Name           string   `json:"name"`
+	Aliases        []string `json:"aliases"`
+	DriverSpecific struct {
+		Lvol *struct {
+			LvolStoreUuid        string  `json:"lvol_store_uuid"`
+			NumAllocatedClusters *uint64 `json:"num_allocated_clusters"`
+		} `json:"lvol"`
+	} `json:"driver_specific"`
+}
+
+// replicaSpace the pool uuid and allocated bytes of a replica
+type replicaSpace struct {
+	poolUuid  string
+	allocated uint64
+}
+
+// listReplicaSpace returns the space accounting of the lvols on the node with
+// ip address address, keyed by the alias of the lvol <pool name>/<lvol name>
+func listReplicaSpace(address string, retry bool) (map[string]replicaSpace, error) {
+	lvolStores, err := jsonRpc(address, "bdev_lvol_get_lvstores", nil, retry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lvol stores on %s, %v", address, err)
+	}
+	bdevs, err := jsonRpc(address, "bdev_get_bdevs", nil, retry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bdevs on %s, %v", address, err)
+	}
+	return parseReplicaSpace(lvolStores, bdevs)
+}
+
+// parseReplicaSpace returns the space accounting of lvols from the results of
+// bdev_lvol_get_lvstores and bdev_get_bdevs
+func parseReplicaSpace(lvolStoresJson json.RawMessage, bdevsJson json.RawMessage) (map[string]replicaSpace, error) {
+	var lvolStores []spdkLvolStore
+	if err := json.Unmarshal(lvolStoresJson, &lvolStores); err != nil {
+		return nil, fmt.Errorf("failed to parse lvol stores, %v", err)
+	}
+	clusterSizes := make(map[string]uint64)
+	for _, lvs := range lvolStores {
+		clusterSizes[lvs.Uuid] = lvs.ClusterSize
+	}
+	var bdevs []spdkBdev
+	if err := json.Unmarshal(bdevsJson, &bdevs); err != nil {
+		return nil, fmt.Errorf("failed to parse bdevs, %v", err)
+	}
+	spaces := make(map[string]replicaSpace)
+	for _, bdev := range bdevs {
+		lvol := bdev.DriverSpecific.Lvol
+		if lvol == nil {
+			continue
+		}
+		space := replicaSpace{poolUuid: lvol.LvolStoreUuid}
+		// older versions of SPDK do not report the allocated clusters
+		if lvol.NumAllocatedClusters != nil {
+			space.allocated = *lvol.NumAllocatedClusters * clusterSizes[lvol.LvolStoreUuid]
+		}
+		for _, alias := range bdev.Aliases {
+			spaces[alias] = space
+		}
+	}
+	return spaces, nil
+}
+
+// setReplicaSpace sets the pool uuid and allocated bytes of the replicas on the node with
+// ip address address. Failure is logged, the fields are then left unset.
+func setReplicaSpace(address string, replicas []MayastorReplica, retry bool) {
+	spaces, err := listReplicaSpace(address, retry)
+	if err != nil {
+		logf.Log.Info("setReplicaSpace", "error", err)
+		return
+	}
+	for ix, replica := range replicas {
+		space, ok := spaces[replica.Pool+"/"+replica.Name]
+		if !ok {
+			space, ok = spaces[replica.Pool+"/"+replica.Uuid]
+		}
+		if ok {
+			replicas[ix].PoolUuid = space.poolUuid
+			replicas[ix].Allocated = space.allocated
+		}
+	}
+}
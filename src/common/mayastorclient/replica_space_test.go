@@ -0,0 +1,32 @@
+package mayastorclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReplicaSpace(t *testing.T) {
+	lvolStores := []byte(`[{"uuid": "lvs-1", "name": "pool-1", "cluster_size": 4194304}]`)
+	bdevs := []byte(`[
+		{"name": "aio:///dev/sdb", "aliases": [], "driver_specific": {"aio": {"filename": "/dev/sdb"}}},
+		{"name": "lvol-1", "aliases": ["pool-1/replica-1"],
+			"driver_specific": {"lvol": {"lvol_store_uuid": "lvs-1", "thin_provision": true, "num_allocated_clusters": 3}}},
+		{"name": "lvol-2", "aliases": ["pool-1/replica-2"],
+			"driver_specific": {"lvol": {"lvol_store_uuid": "lvs-1", "thin_provision": false}}}
+	]`)
+	spaces, err := parseReplicaSpace(lvolStores, bdevs)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := map[string]replicaSpace{
+		"pool-1/replica-1": {poolUuid: "lvs-1", allocated: 3 * 4194304},
+		"pool-1/replica-2": {poolUuid: "lvs-1"},
+	}
+	if !reflect.DeepEqual(spaces, expected) {
+		t.Errorf("expected %v, got %v", expected, spaces)
+	}
+
+	if _, err = parseReplicaSpace([]byte(""), bdevs); err == nil {
+		t.Errorf("expected an error parsing an empty result")
+	}
+}
@@ -0,0 +1,91 @@
+package mayastorclient
+
+import (
+	"context"
+	"fmt"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+	"strings"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// snapshots are created on each replica of a nexus and are listed as replicas,
+// named <replica uuid>-snap-<snapshot time>
+const snapshotNameSeparator = "-snap-"
+
+// ParseSnapshotName returns the source replica and snapshot time of a snapshot name,
+// ok is false if the name is not a snapshot name.
+func ParseSnapshotName(name string) (source string, snapTime string, ok bool) {
+	ix := strings.LastIndex(name, snapshotNameSeparator)
+	if ix <= 0 || ix+len(snapshotNameSeparator) == len(name) {
+		return "", "", false
+	}
+	return name[:ix], name[ix+len(snapshotNameSeparator):], true
+}
+
+// IsSnapshot returns true if the replica is a snapshot
+func (msr MayastorReplica) IsSnapshot() bool {
+	_, _, ok := ParseSnapshotName(msr.Name)
+	return ok
+}
+
+// CreateSnapshot creates a snapshot of all replicas of the nexus with uuid on the node with ip address address,
+// returns the name of the snapshot
+func CreateSnapshot(address string, uuid string) (string, error) {
+	logf.Log.Info("CreateSnapshot", "address", address, "UUID", uuid)
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("CreateSnapshot", "error", err)
+		return "", err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	req := mayastorGrpc.CreateSnapshotRequest{Uuid: uuid}
+	var response *mayastorGrpc.CreateSnapshotReply
+	// not retried, an attempt which exceeded its deadline may have succeeded
+	err = callWithRetry(false, func(ctx context.Context) (err error) {
+		response, err = c.CreateSnapshot(ctx, &req)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to CreateSnapshot")
+		} else {
+			return response.Name, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("CreateSnapshot", "error", err)
+	}
+	return "", err
+}
+
+// ListSnapshots given a list of node ip addresses, enumerate the set of replica snapshots
+// on mayastor using gRPC on each of those nodes
+// returns accumulated errors if gRPC communication failed.
+func ListSnapshots(addrs []string) ([]MayastorReplica, error) {
+	var snapshots []MayastorReplica
+	replicas, err := ListReplicas(addrs)
+	for _, replica := range replicas {
+		if replica.IsSnapshot() {
+			snapshots = append(snapshots, replica)
+		}
+	}
+	return snapshots, err
+}
+
+// ListReplicasExcludingSnapshots given a list of node ip addresses, enumerate the set of replicas
+// which are not snapshots on mayastor using gRPC on each of those nodes
+// returns accumulated errors if gRPC communication failed.
+func ListReplicasExcludingSnapshots(addrs []string) ([]MayastorReplica, error) {
+	var replicas []MayastorReplica
+	all, err := ListReplicas(addrs)
+	for _, replica := range all {
+		if !replica.IsSnapshot() {
+			replicas = append(replicas, replica)
+		}
+	}
+	return replicas, err
+}
@@ -5,5 +5,3 @@ import (
 )
 
 var null = mayastorGrpc.Null{}
-
-const mayastorPort = 10124
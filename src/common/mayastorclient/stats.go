@@ -0,0 +1,194 @@
+package mayastorclient
+
+import (
+	"context"
+	"fmt"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ResourceUsage resource usage of the mayastor process
+type ResourceUsage struct {
+	SoftFaults  int64 `json:"soft_faults"`
+	HardFaults  int64 `json:"hard_faults"`
+	Swaps       int64 `json:"swaps"`
+	InBlockOps  int64 `json:"in_block_ops"`
+	OutBlockOps int64 `json:"out_block_ops"`
+	IpcMsgSend  int64 `json:"ipc_msg_send"`
+	IpcMsgRcv   int64 `json:"ipc_msg_rcv"`
+	Signals     int64 `json:"signals"`
+	VolCsw      int64 `json:"vol_csw"`
+	InvolCsw    int64 `json:"invol_csw"`
+}
+
+// ReplicaStats IO counters for a replica
+type ReplicaStats struct {
+	Uuid         string `json:"uuid"`
+	Pool         string `json:"pool"`
+	NumReadOps   uint64 `json:"num_read_ops"`
+	NumWriteOps  uint64 `json:"num_write_ops"`
+	BytesRead    uint64 `json:"bytes_read"`
+	BytesWritten uint64 `json:"bytes_written"`
+}
+
+func (rs ReplicaStats) String() string {
+	return fmt.Sprintf("Uuid=%s; Pool=%s; NumReadOps=%d; NumWriteOps=%d; BytesRead=%d; BytesWritten=%d;",
+		rs.Uuid, rs.Pool, rs.NumReadOps, rs.NumWriteOps, rs.BytesRead, rs.BytesWritten)
+}
+
+// NvmeControllerStats IO counters for an NVMe controller
+type NvmeControllerStats struct {
+	Name          string `json:"name"`
+	NumReadOps    uint64 `json:"num_read_ops"`
+	NumWriteOps   uint64 `json:"num_write_ops"`
+	BytesRead     uint64 `json:"bytes_read"`
+	BytesWritten  uint64 `json:"bytes_written"`
+	NumUnmapOps   uint64 `json:"num_unmap_ops"`
+	BytesUnmapped uint64 `json:"bytes_unmapped"`
+}
+
+func (ns NvmeControllerStats) String() string {
+	return fmt.Sprintf("Name=%s; NumReadOps=%d; NumWriteOps=%d; BytesRead=%d; BytesWritten=%d; NumUnmapOps=%d; BytesUnmapped=%d;",
+		ns.Name, ns.NumReadOps, ns.NumWriteOps, ns.BytesRead, ns.BytesWritten, ns.NumUnmapOps, ns.BytesUnmapped)
+}
+
+func getResourceUsage(address string, retry bool) (*ResourceUsage, error) {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("getResourceUsage", "error", err)
+		return nil, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	var response *mayastorGrpc.GetResourceUsageReply
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
+		response, err = c.GetResourceUsage(ctx, &null)
+		return err
+	})
+
+	if err == nil {
+		if response == nil || response.Usage == nil {
+			err = fmt.Errorf("nil response for GetResourceUsage on %s", address)
+		} else {
+			usage := response.Usage
+			return &ResourceUsage{
+				SoftFaults:  usage.SoftFaults,
+				HardFaults:  usage.HardFaults,
+				Swaps:       usage.Swaps,
+				InBlockOps:  usage.InBlockOps,
+				OutBlockOps: usage.OutBlockOps,
+				IpcMsgSend:  usage.IpcMsgSend,
+				IpcMsgRcv:   usage.IpcMsgRcv,
+				Signals:     usage.Signals,
+				VolCsw:      usage.VolCsw,
+				InvolCsw:    usage.InvolCsw,
+			}, nil
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("getResourceUsage", "error", err)
+	}
+	return nil, err
+}
+
+// GetResourceUsage returns the resource usage of mayastor on the node with ip address address
+func GetResourceUsage(address string) (*ResourceUsage, error) {
+	return getResourceUsage(address, true)
+}
+
+func statReplicas(address string, retry bool) ([]ReplicaStats, error) {
+	var replicaStats []ReplicaStats
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("statReplicas", "error", err)
+		return replicaStats, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	var response *mayastorGrpc.StatReplicasReply
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
+		response, err = c.StatReplicas(ctx, &null)
+		return err
+	})
+
+	if err == nil {
+		if response != nil {
+			for _, replica := range response.Replicas {
+				rs := ReplicaStats{
+					Uuid: replica.Uuid,
+					Pool: replica.Pool,
+				}
+				if replica.Stats != nil {
+					rs.NumReadOps = replica.Stats.NumReadOps
+					rs.NumWriteOps = replica.Stats.NumWriteOps
+					rs.BytesRead = replica.Stats.BytesRead
+					rs.BytesWritten = replica.Stats.BytesWritten
+				}
+				replicaStats = append(replicaStats, rs)
+			}
+		} else {
+			err = fmt.Errorf("nil response for StatReplicas on %s", address)
+			logf.Log.Info("statReplicas", "error", err)
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("statReplicas", "error", err)
+	}
+	return replicaStats, err
+}
+
+// StatReplicas returns the IO counters for the replicas on the node with ip address address
+func StatReplicas(address string) ([]ReplicaStats, error) {
+	return statReplicas(address, true)
+}
+
+func statNvmeControllers(address string, retry bool) ([]NvmeControllerStats, error) {
+	var controllerStats []NvmeControllerStats
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info("statNvmeControllers", "error", err)
+		return controllerStats, err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	var response *mayastorGrpc.StatNvmeControllersReply
+	err = callWithRetry(retry, func(ctx context.Context) (err error) {
+		response, err = c.StatNvmeControllers(ctx, &null)
+		return err
+	})
+
+	if err == nil {
+		if response != nil {
+			for _, controller := range response.Controllers {
+				ns := NvmeControllerStats{
+					Name: controller.Name,
+				}
+				if controller.Stats != nil {
+					ns.NumReadOps = controller.Stats.NumReadOps
+					ns.NumWriteOps = controller.Stats.NumWriteOps
+					ns.BytesRead = controller.Stats.BytesRead
+					ns.BytesWritten = controller.Stats.BytesWritten
+					ns.NumUnmapOps = controller.Stats.NumUnmapOps
+					ns.BytesUnmapped = controller.Stats.BytesUnmapped
+				}
+				controllerStats = append(controllerStats, ns)
+			}
+		} else {
+			err = fmt.Errorf("nil response for StatNvmeControllers on %s", address)
+			logf.Log.Info("statNvmeControllers", "error", err)
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info("statNvmeControllers", "error", err)
+	}
+	return controllerStats, err
+}
+
+// StatNvmeControllers returns the IO counters for the NVMe controllers on the node with ip address address
+func StatNvmeControllers(address string) ([]NvmeControllerStats, error) {
+	return statNvmeControllers(address, true)
+}
@@ -6,7 +6,6 @@ import (
 	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
 	"time"
 
-	"google.golang.org/grpc"
 	grpcCodes "google.golang.org/grpc/codes"
 	grpcStatus "google.golang.org/grpc/status"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -37,15 +36,10 @@ var canConnect bool = false
 
 func mayastorInfo(address string) (*mayastorGrpc.MayastorInfoRequest, error) {
 	var err error
-	addrPort := fmt.Sprintf("%s:%d", address, mayastorPort)
-	conn, err := grpc.Dial(addrPort, grpc.WithInsecure())
+	conn, err := getConnection(address)
 	if err != nil {
 		return nil, err
 	}
-	defer func(conn *grpc.ClientConn) {
-		_ = conn.Close()
-	}(conn)
-
 	c := mayastorGrpc.NewMayastorClient(conn)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -78,8 +72,6 @@ func CanConnect() bool {
 	return canConnect
 }
 
-const ctxTimeout = 30 * time.Second
-
 // retry a function upto 6 times with exponential backoff,
 // starting at 5 seconds if the error(s) returned are
 // is deadline_exceeded.
@@ -94,3 +86,53 @@ func retryBackoff(f func() (err error)) {
 		timeout *= 2
 	}
 }
+
+// callWithRetry makes a gRPC call with a fresh deadline for every attempt, if retry is true
+// the call is retried using retryBackoff while the deadline is exceeded. Calls made on
+// every node of a fan-out are not retried, the caller receives NodeErrors instead.
+func callWithRetry(retry bool, call func(ctx context.Context) error) error {
+	attempt := func() error {
+		ctx, cancel := callContext()
+		defer cancel()
+		return call(ctx)
+	}
+	if !retry {
+		return attempt()
+	}
+	var err error
+	retryBackoff(func() error {
+		err = attempt()
+		return err
+	})
+	return err
+}
+
+// nullResponseCall makes a gRPC call which returns Null on the node with ip address address,
+// name is the name of the call used in log messages and errors.
+// The calls which return Null change state, so are not retried: an attempt which exceeded
+// its deadline may have succeeded and a retry would then fail.
+func nullResponseCall(address string, name string, call func(ctx context.Context, c mayastorGrpc.MayastorClient) (*mayastorGrpc.Null, error)) error {
+	var err error
+	conn, err := getConnection(address)
+	if err != nil {
+		logf.Log.Info(name, "error", err)
+		return err
+	}
+	c := mayastorGrpc.NewMayastorClient(conn)
+
+	var response *mayastorGrpc.Null
+	err = callWithRetry(false, func(ctx context.Context) error {
+		response, err = call(ctx, c)
+		return err
+	})
+
+	if err == nil {
+		if response == nil {
+			err = fmt.Errorf("nil response to %s", name)
+		}
+	} else {
+		err = niceError(err)
+		logf.Log.Info(name, "error", err)
+	}
+	return err
+}
@@ -90,6 +90,11 @@ type MayastorVolumeInterface interface {
 	IsMsvDeleted(uuid string) bool
 	CheckForMsvs() (bool, error)
 	CheckAllMsvsAreHealthy() error
+	CreateMsv(uuid string, sizeBytes int64, replicaCount int) (*MayastorVolume, error)
+	PublishMsv(uuid string, node string, protocol ShareProto) (string, error)
+	UnpublishMsv(uuid string) error
+	ShareMsv(uuid string, protocol ShareProto) (string, error)
+	UnshareMsv(uuid string) error
 }
 
 type MayastorNodeInterface interface {
@@ -3,7 +3,6 @@ package basic_volume_io
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"mayastor-e2e/common"
 	"mayastor-e2e/common/e2e_config"
@@ -11,13 +10,10 @@ import (
 
 	. "github.com/onsi/gomega"
 
-	coreV1 "k8s.io/api/core/v1"
 	storageV1 "k8s.io/api/storage/v1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-var defTimeoutSecs = "120s"
-
 func BasicVolumeIOTest(protocol common.ShareProto, volumeType common.VolumeType, mode storageV1.VolumeBindingMode) {
 	params := e2e_config.GetConfig().BasicVolumeIO
 	log.Log.Info("Test", "parameters", params)
@@ -38,61 +34,28 @@ func BasicVolumeIOTest(protocol common.ShareProto, volumeType common.VolumeType,
 	Expect(err).ToNot(HaveOccurred(), "failed to create pvc %s", volName)
 	log.Log.Info("Volume", "uid", uid)
 
-	// Create the fio Pod
-	fioPodName := "fio-" + volName
-	pod := k8stest.CreateFioPodDef(fioPodName, volName, volumeType, common.NSDefault)
-	Expect(pod).ToNot(BeNil())
-
-	var args = []string{
-		"--",
-	}
-	switch volumeType {
-	case common.VolFileSystem:
-		args = append(args, fmt.Sprintf("--filename=%s", common.FioFsFilename))
-		args = append(args, fmt.Sprintf("--size=%dm", params.FsVolSizeMb))
-	case common.VolRawBlock:
-		args = append(args, fmt.Sprintf("--filename=%s", common.FioBlockFilename))
+	// Create the workload pod
+	sizeMb := 0
+	if volumeType == common.VolFileSystem {
+		sizeMb = params.FsVolSizeMb
 	}
-	args = append(args, common.GetFioArgs()...)
-	log.Log.Info("fio", "arguments", args)
-	pod.Spec.Containers[0].Args = args
-
-	pod, err = k8stest.CreatePod(pod, common.NSDefault)
+	workload, err := k8stest.NewWorkload(params.Workload, k8stest.WorkloadParams{SizeMb: sizeMb})
+	Expect(err).ToNot(HaveOccurred(), "failed to configure workload %s", params.Workload)
+	podName := workload.Name() + "-" + volName
+	err = k8stest.StartWorkload(workload, podName, volName, volumeType)
 	Expect(err).ToNot(HaveOccurred())
-	Expect(pod).ToNot(BeNil())
-
-	// Wait for the fio Pod to transition to running
-	Eventually(func() bool {
-		return k8stest.IsPodRunning(fioPodName, common.NSDefault)
-	},
-		defTimeoutSecs,
-		"1s",
-	).Should(Equal(true))
-	log.Log.Info("fio test pod is running.")
+	log.Log.Info("workload pod is running.", "workload", workload.Name())
 
 	msvc_err := k8stest.MsvConsistencyCheck(uid)
 	Expect(msvc_err).ToNot(HaveOccurred(), "%v", msvc_err)
 
 	log.Log.Info("Waiting for run to complete", "timeout", params.FioTimeout)
-	tSecs := 0
-	var phase coreV1.PodPhase
-	for {
-		if tSecs > params.FioTimeout {
-			break
-		}
-		time.Sleep(1 * time.Second)
-		tSecs += 1
-		phase, err = k8stest.CheckPodCompleted(fioPodName, common.NSDefault)
-		Expect(err).To(BeNil(), "CheckPodComplete got error %s", err)
-		if phase != coreV1.PodRunning {
-			break
-		}
-	}
-	Expect(phase == coreV1.PodSucceeded).To(BeTrue(), "fio pod phase is %s", phase)
-	log.Log.Info("fio completed", "duration", tSecs)
+	result, err := k8stest.WaitWorkload(workload, podName, params.FioTimeout)
+	Expect(err).ToNot(HaveOccurred(), "workload %s failed", workload.Name())
+	log.Log.Info("workload completed", "result", result)
 
-	// Delete the fio pod
-	err = k8stest.DeletePod(fioPodName, common.NSDefault)
+	// Delete the workload pod
+	err = k8stest.DeletePod(podName, common.NSDefault)
 	Expect(err).ToNot(HaveOccurred())
 
 	// Delete the volume
@@ -31,6 +31,6 @@ func GetThinkTimeBlocks(idx int) int {
 
 func GetIOSoakFioArgs() []string {
 	args := common.GetFioArgs()
-	args = append(args, []string{"--status-interval=120", "--output-format=terse"}...)
+	args = append(args, []string{"--status-interval=120", "--output-format=json"}...)
 	return args
 }
@@ -252,9 +252,26 @@ func IOSoakTest(protocols []common.ShareProto,
 	Expect(allReady).To(BeTrue(), "Timeout waiting to jobs to be ready")
 
 	logf.Log.Info("Waiting for test execution to complete on all test pods")
+	statsCollector := k8stest.StartStatsCollector()
 	err = monitor()
+	k8stest.StopStatsCollector(statsCollector, "io_soak")
 	Expect(err).To(BeNil(), "Failed runs")
 
+	logf.Log.Info("All runs complete, recording fio results")
+	var regressions []string
+	for _, job := range jobs {
+		// results are recorded for performance tracking, IO errors have been checked by monitor,
+		// the soak fails on regressions only if FioResults.FailOnRegression is set
+		if err := k8stest.RecordFioPodResult(job.getPodName()); err != nil {
+			if k8stest.IsFioRegression(err) {
+				regressions = append(regressions, err.Error())
+			} else {
+				logf.Log.Info("Failed to record fio result", "job", job.describe(), "error", err)
+			}
+		}
+	}
+	Expect(regressions).To(BeEmpty(), "Fio performance regressions")
+
 	logf.Log.Info("All runs complete, deleting test pods")
 	DestroyDisruptors()
 	DisruptorsDeinit()
@@ -1,6 +1,7 @@
 package maximum_vols_io
 
 import (
+	"fmt"
 	"testing"
 
 	"mayastor-e2e/common/k8stest"
@@ -59,7 +60,12 @@ func (c *maxVolConfig) maxVolumeTest() {
 	c.createSC()
 	c = c.createPVC()
 	c.createFioPods()
-	c.checkFioPodsComplete()
+	// the statistics are also written if the fio pods fail
+	func() {
+		statsCollector := k8stest.StartStatsCollector()
+		defer k8stest.StopStatsCollector(statsCollector, fmt.Sprintf("maximum_vols_io-%d", c.replicas))
+		c.checkFioPodsComplete()
+	}()
 	c.deleteFioPods()
 	c.deletePVC()
 	c.deleteSC()
@@ -0,0 +1,116 @@
+package nvme_ana
+
+import (
+	"testing"
+	"time"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/k8stest"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestNvmeAna(t *testing.T) {
+	// Initialise test and set class and file names for reports
+	k8stest.InitTesting(t, "NVMe ANA state test", "nvme_ana")
+}
+
+// checkIo sets the ANA state of the volume nexus and checks whether IO
+// continues (ioExpected == true) or pauses
+func checkIo(uuid string, nodeAddr string, anaState mayastorGrpc.NvmeAnaState, ioExpected bool) {
+	observeSecs := e2e_config.GetConfig().NvmeAna.ObserveSecs
+	err := k8stest.SetMsvNvmeAnaState(uuid, anaState)
+	Expect(err).ToNot(HaveOccurred(), "failed to set ANA state %v", err)
+	state, err := k8stest.GetMsvNvmeAnaState(uuid)
+	Expect(err).ToNot(HaveOccurred(), "failed to get ANA state %v", err)
+	Expect(state).To(Equal(anaState), "ANA state")
+
+	// allow for the host to observe the state change and for IOs in flight to complete
+	time.Sleep(5 * time.Second)
+	before, err := getWriteIos(nodeAddr, uuid)
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	time.Sleep(time.Duration(observeSecs) * time.Second)
+	after, err := getWriteIos(nodeAddr, uuid)
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	logf.Log.Info("IO", "ana state", anaState, "writes", after-before)
+	if ioExpected {
+		Expect(after).To(BeNumerically(">", before), "IO paused in ANA state %v", anaState)
+	} else {
+		Expect(after).To(Equal(before), "IO continued in ANA state %v", anaState)
+	}
+}
+
+func nvmeAnaTest() {
+	params := e2e_config.GetConfig().NvmeAna
+	timeout, err := time.ParseDuration(params.Timeout)
+	Expect(err).ToNot(HaveOccurred(), "invalid timeout %s", params.Timeout)
+
+	// fio writes and verifies data throughout the test
+	fio, err := k8stest.NewWorkload(k8stest.WorkloadFio, k8stest.WorkloadParams{
+		DurationSecs: params.DurationSecs,
+		SizeMb:       params.FioSizeMb,
+	})
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	wv, cleanup, err := k8stest.DeployWorkloadVolume("nvme-ana", "nvme-ana-vol", 1, params.VolMb, fio, int(timeout.Seconds()))
+	defer func() {
+		err := cleanup()
+		Expect(err).ToNot(HaveOccurred(), "%v", err)
+	}()
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	uuid := wv.Uuid
+
+	// IO is observed on the node hosting the fio pod using the e2e-agent
+	nodeAddr, err := k8stest.GetPodHostIp(wv.PodName, common.NSDefault)
+	Expect(err).ToNot(HaveOccurred(), "failed to get fio pod host ip %v", err)
+
+	state, err := k8stest.GetMsvNvmeAnaState(uuid)
+	Expect(err).ToNot(HaveOccurred(), "failed to get ANA state %v", err)
+	Expect(state).To(Equal(mayastorGrpc.NvmeAnaState_NVME_ANA_OPTIMIZED_STATE), "initial ANA state")
+
+	checkIo(uuid, nodeAddr, mayastorGrpc.NvmeAnaState_NVME_ANA_NON_OPTIMIZED_STATE, true)
+	checkIo(uuid, nodeAddr, mayastorGrpc.NvmeAnaState_NVME_ANA_INACCESSIBLE_STATE, false)
+	checkIo(uuid, nodeAddr, mayastorGrpc.NvmeAnaState_NVME_ANA_OPTIMIZED_STATE, true)
+
+	// fio verifies the data, so must complete successfully
+	_, err = k8stest.WaitWorkload(fio, wv.PodName, params.DurationSecs)
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+}
+
+var _ = Describe("NVMe ANA state tests", func() {
+
+	BeforeEach(func() {
+		// Check ready to run
+		err := k8stest.BeforeEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		// Check resource leakage.
+		err := k8stest.AfterEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should pause and resume IO as the nexus ANA state changes", func() {
+		nvmeAnaTest()
+	})
+})
+
+var _ = BeforeSuite(func(done Done) {
+	err := k8stest.SetupTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to setup test environment in BeforeSuite : SetupTestEnv %v", err)
+
+	close(done)
+}, 60)
+
+var _ = AfterSuite(func() {
+	// NB This only tears down the local structures for talking to the cluster,
+	// not the kubernetes cluster itself.	By("tearing down the test environment")
+	err := k8stest.TeardownTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to tear down test environment in AfterSuite : TeardownTestEnv %v", err)
+
+})
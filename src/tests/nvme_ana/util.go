@@ -0,0 +1,39 @@
+package nvme_ana
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	agent "mayastor-e2e/common/e2e-agent"
+)
+
+// getWriteIos returns the number of write IOs completed on the nvme namespaces of the volume
+// on the node with ip address nodeAddr, from the namespace stat files in sysfs
+func getWriteIos(nodeAddr string, uuid string) (uint64, error) {
+	namespaces, err := agent.FindNvmeNamespaces(nodeAddr, uuid)
+	if err != nil {
+		return 0, err
+	}
+	if len(namespaces) == 0 {
+		return 0, fmt.Errorf("no nvme namespace found for volume %s on %s", uuid, nodeAddr)
+	}
+	var writeIos uint64
+	for _, namespace := range namespaces {
+		stat, err := agent.Exec(nodeAddr, "cat "+namespace+"/stat")
+		if err != nil {
+			return 0, err
+		}
+		fields := strings.Fields(stat)
+		// field 5 is the number of write IOs completed
+		if len(fields) < 5 {
+			return 0, fmt.Errorf("unexpected stat output for volume %s namespace %s: %q", uuid, namespace, stat)
+		}
+		ios, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		writeIos += ios
+	}
+	return writeIos, nil
+}
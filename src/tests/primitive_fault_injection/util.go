@@ -306,15 +306,12 @@ func (c *primitiveFaultInjectionConfig) verifyMsvStatus() {
 		"5s",
 	).Should(Not(BeNil()))
 
-	Eventually(func() string {
-		msv, err := k8stest.GetMSV(string(pvc.ObjectMeta.UID))
-		Expect(err).ToNot(HaveOccurred(), "%v", err)
-		return msv.Status.State
-	},
-		defTimeoutSecs,
-		"5s",
-	).Should(Equal(controlplane.VolStateDegraded()))
-
+	timeout, err := time.ParseDuration(defTimeoutSecs)
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	err = k8stest.ExpectMsv(string(pvc.ObjectMeta.UID)).
+		ThenState(controlplane.VolStateDegraded(), timeout).
+		Verify()
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
 }
 
 // use the e2e-agent run on each non-nexus node:
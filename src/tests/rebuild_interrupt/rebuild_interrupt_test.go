@@ -0,0 +1,187 @@
+package rebuild_interrupt
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/controlplane"
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/k8stest"
+	"mayastor-e2e/common/mayastorclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestRebuildInterrupt(t *testing.T) {
+	// Initialise test and set class and file names for reports
+	k8stest.InitTesting(t, "Interrupted rebuild test", "rebuild_interrupt")
+}
+
+// rebuildInterrupt interrupts the rebuild of the nexus child identified by target
+type rebuildInterrupt func(uuid string, target *k8stest.RebuildTarget)
+
+func rebuildInterruptTest(name string, interrupt rebuildInterrupt) {
+	params := e2e_config.GetConfig().RebuildInterrupt
+	timeout, err := time.ParseDuration(params.Timeout)
+	Expect(err).ToNot(HaveOccurred(), "invalid timeout %s", params.Timeout)
+
+	// fio writes and verifies data throughout the test
+	fio, err := k8stest.NewWorkload(k8stest.WorkloadFio, k8stest.WorkloadParams{
+		DurationSecs: params.DurationSecs,
+		SizeMb:       params.FioSizeMb,
+	})
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	wv, cleanup, err := k8stest.DeployWorkloadVolume("rebuild-interrupt-"+name, "rebuild-interrupt-vol-"+name, 1, params.VolMb, fio, int(timeout.Seconds()))
+	defer func() {
+		err := cleanup()
+		Expect(err).ToNot(HaveOccurred(), "%v", err)
+	}()
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	uuid := wv.Uuid
+
+	// Add another child which kicks off a rebuild.
+	err = k8stest.SetMsvReplicaCount(uuid, 2)
+	Expect(err).ToNot(HaveOccurred(), "Update the number of replicas")
+	target, err := k8stest.WaitForRebuildStart(uuid, int(timeout.Seconds()))
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	logf.Log.Info("Rebuild started", "nexus node", target.NexusNode, "child", target.ChildUri)
+
+	// The volume is degraded while the rebuild runs, it must recover after the interruption
+	transitions := k8stest.ExpectMsv(uuid).
+		ThenState(controlplane.VolStateDegraded(), timeout).
+		ThenState(controlplane.VolStateHealthy(), timeout).
+		Never("all children faulted", k8stest.AllMsvChildrenInState(controlplane.ChildStateFaulted())).
+		Start()
+	defer transitions.Cancel()
+
+	interrupt(uuid, target)
+
+	err = transitions.Wait()
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	Expect(k8stest.IsPodRunning(wv.PodName, common.NSDefault)).To(BeTrue(), "fio pod %s is not running", wv.PodName)
+
+	// fio must not have seen any errors
+	_, err = k8stest.WaitWorkload(fio, wv.PodName, params.DurationSecs)
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+}
+
+// pauseRebuild pauses the rebuild, checks that no progress is made, then resumes the rebuild
+func pauseRebuild(uuid string, target *k8stest.RebuildTarget) {
+	pauseSecs := e2e_config.GetConfig().RebuildInterrupt.PauseSecs
+	err := mayastorclient.PauseRebuild(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	if err != nil {
+		// a small volume on fast storage may be rebuilt before the rebuild is paused
+		if rebuilding, stateErr := target.Rebuilding(); stateErr == nil && !rebuilding {
+			Skip("the rebuild completed before it could be paused, increase rebuildInterrupt.volMb and fioSizeMb")
+		}
+	}
+	Expect(err).ToNot(HaveOccurred(), "failed to pause rebuild %v", err)
+
+	state, err := mayastorclient.GetRebuildState(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	Expect(err).ToNot(HaveOccurred(), "failed to get rebuild state %v", err)
+	logf.Log.Info("Rebuild paused", "state", state)
+	before, err := mayastorclient.GetRebuildProgress(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	Expect(err).ToNot(HaveOccurred(), "failed to get rebuild progress %v", err)
+	time.Sleep(time.Duration(pauseSecs) * time.Second)
+	after, err := mayastorclient.GetRebuildProgress(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	Expect(err).ToNot(HaveOccurred(), "failed to get rebuild progress %v", err)
+	Expect(after).To(Equal(before), "rebuild progressed while paused")
+
+	err = mayastorclient.ResumeRebuild(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	Expect(err).ToNot(HaveOccurred(), "failed to resume rebuild %v", err)
+}
+
+// restartDuringRebuild pauses the rebuild then restarts the mayastor pod on the node
+// of the replica being rebuilt
+func restartDuringRebuild(uuid string, target *k8stest.RebuildTarget) {
+	replicaNode := getReplicaNode(uuid, target.ChildUri)
+	Expect(replicaNode).ToNot(Equal(""), "replica for child %s not found", target.ChildUri)
+	if replicaNode == target.NexusNode {
+		// restarting the pod would also restart the nexus
+		Skip("the replica being rebuilt is local to the nexus")
+	}
+
+	err := mayastorclient.PauseRebuild(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	Expect(err).ToNot(HaveOccurred(), "failed to pause rebuild %v", err)
+
+	msPodName := getMayastorPodName(replicaNode)
+	Expect(msPodName).ToNot(Equal(""), "mayastor pod on %s not found", replicaNode)
+	logf.Log.Info("Restarting", "pod", msPodName, "node", replicaNode)
+	err = k8stest.DeletePod(msPodName, common.NSMayastor())
+	Expect(err).ToNot(HaveOccurred(), "failed to delete pod %s", msPodName)
+
+	ready, err := k8stest.MayastorReady(2, 540)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ready).To(BeTrue(), "mayastor is not ready")
+}
+
+// stopRebuild stops the rebuild and removes the child being rebuilt from the nexus,
+// the control plane then adds a child to restore the replica count of the volume
+func stopRebuild(uuid string, target *k8stest.RebuildTarget) {
+	params := e2e_config.GetConfig().RebuildInterrupt
+	err := mayastorclient.StopRebuild(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	Expect(err).ToNot(HaveOccurred(), "failed to stop rebuild %v", err)
+
+	err = mayastorclient.RemoveChildNexus(target.NexusAddress, target.NexusUuid, target.ChildUri)
+	Expect(err).ToNot(HaveOccurred(), "failed to remove child %s, %v", target.ChildUri, err)
+	logf.Log.Info("Removed", "child", target.ChildUri)
+
+	// WaitForRebuild completes when all children are online, so wait for the child to be added
+	Eventually(func() int {
+		children, err := k8stest.GetMsvNexusChildren(uuid)
+		if err == nil {
+			return len(children)
+		}
+		return 0
+	}, params.Timeout, params.PollPeriod).Should(Equal(2))
+	report, err := k8stest.WaitForRebuild(uuid)
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	logf.Log.Info("Rebuild", "report", fmt.Sprint(report))
+}
+
+var _ = Describe("Interrupted rebuild tests", func() {
+
+	BeforeEach(func() {
+		// Check ready to run
+		err := k8stest.BeforeEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		// Check resource leakage.
+		err := k8stest.AfterEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should complete a rebuild which is paused and resumed while fio verifies data", func() {
+		rebuildInterruptTest("pause", pauseRebuild)
+	})
+
+	It("should complete a rebuild interrupted by a mayastor pod restart while fio verifies data", func() {
+		rebuildInterruptTest("restart", restartDuringRebuild)
+	})
+
+	It("should complete a rebuild after the rebuild is stopped and the child re-added while fio verifies data", func() {
+		rebuildInterruptTest("stop", stopRebuild)
+	})
+})
+
+var _ = BeforeSuite(func(done Done) {
+	err := k8stest.SetupTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to setup test environment in BeforeSuite : SetupTestEnv %v", err)
+
+	close(done)
+}, 60)
+
+var _ = AfterSuite(func() {
+	// NB This only tears down the local structures for talking to the cluster,
+	// not the kubernetes cluster itself.	By("tearing down the test environment")
+	err := k8stest.TeardownTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to tear down test environment in AfterSuite : TeardownTestEnv %v", err)
+
+})
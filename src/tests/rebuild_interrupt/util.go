@@ -0,0 +1,37 @@
+package rebuild_interrupt
+
+import (
+	"strings"
+
+	"mayastor-e2e/common"
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/k8stest"
+
+	. "github.com/onsi/gomega"
+)
+
+// getReplicaNode returns the name of the node hosting the replica for a nexus child
+func getReplicaNode(uuid string, childUri string) string {
+	replicas, err := k8stest.GetMsvReplicas(uuid)
+	Expect(err).ToNot(HaveOccurred(), "failed to get replicas for %s", uuid)
+	childUri = strings.Split(childUri, "?")[0]
+	for _, replica := range replicas {
+		if strings.Split(replica.Uri, "?")[0] == childUri {
+			return replica.Node
+		}
+	}
+	return ""
+}
+
+// getMayastorPodName return the name of the mayastor pod on a node
+func getMayastorPodName(nodeName string) string {
+	podPrefix := e2e_config.GetConfig().Product.PodName + "-"
+	podList, err := k8stest.ListPod(common.NSMayastor())
+	Expect(err).ToNot(HaveOccurred(), "failed to list pods")
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName == nodeName && pod.GenerateName == podPrefix {
+			return pod.Name
+		}
+	}
+	return ""
+}
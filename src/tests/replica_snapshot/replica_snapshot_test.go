@@ -0,0 +1,109 @@
+package replica_snapshot
+
+import (
+	"testing"
+	"time"
+
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/k8stest"
+	"mayastor-e2e/common/mayastorclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReplicaSnapshot(t *testing.T) {
+	// Initialise test and set class and file names for reports
+	k8stest.InitTesting(t, "Replica snapshot test", "replica_snapshot")
+}
+
+func replicaSnapshotTest() {
+	params := e2e_config.GetConfig().ReplicaSnapshot
+	timeout, err := time.ParseDuration(params.Timeout)
+	Expect(err).ToNot(HaveOccurred(), "invalid timeout %s", params.Timeout)
+
+	// fio writes and verifies data throughout the test
+	fio, err := k8stest.NewWorkload(k8stest.WorkloadFio, k8stest.WorkloadParams{
+		DurationSecs: params.DurationSecs,
+		SizeMb:       params.FioSizeMb,
+	})
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	wv, cleanup, err := k8stest.DeployWorkloadVolume("replica-snapshot", "replica-snapshot-vol", params.Replicas, params.VolMb, fio, int(timeout.Seconds()))
+	defer func() {
+		err := cleanup()
+		Expect(err).ToNot(HaveOccurred(), "%v", err)
+	}()
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+	uuid := wv.Uuid
+
+	// snapshots are deleted before the volume, also if the test fails
+	var snapshots []*k8stest.MsvSnapshot
+	defer func() {
+		for _, snapshot := range snapshots {
+			err := k8stest.DeleteMsvSnapshot(snapshot)
+			Expect(err).ToNot(HaveOccurred(), "%v", err)
+		}
+		remaining, err := mayastorclient.ListSnapshots(k8stest.GetMayastorNodeIPAddresses())
+		Expect(err).ToNot(HaveOccurred(), "failed to list snapshots %v", err)
+		Expect(remaining).To(BeEmpty(), "snapshots were not deleted")
+	}()
+
+	// snapshot the volume while fio is running,
+	// the snapshots of all replicas must be identical
+	var checksums []string
+	for ix := 0; ix < params.Snapshots; ix++ {
+		time.Sleep(time.Duration(params.IntervalSecs) * time.Second)
+		snapshot, err := k8stest.SnapshotMsv(uuid)
+		if snapshot != nil {
+			snapshots = append(snapshots, snapshot)
+		}
+		Expect(err).ToNot(HaveOccurred(), "failed to snapshot volume %s, %v", uuid, err)
+		checksums = append(checksums, checksumSnapshot(snapshot))
+		logf.Log.Info("Snapshot", "name", snapshot.Name, "checksum", checksums[ix])
+	}
+
+	// fio verifies the data, so must complete successfully
+	_, err = k8stest.WaitWorkload(fio, wv.PodName, params.DurationSecs)
+	Expect(err).ToNot(HaveOccurred(), "%v", err)
+
+	// the snapshots must not have been modified by subsequent writes to the volume
+	for ix, snapshot := range snapshots {
+		Expect(checksumSnapshot(snapshot)).To(Equal(checksums[ix]), "snapshot %s has changed", snapshot.Name)
+	}
+}
+
+var _ = Describe("Replica snapshot tests", func() {
+
+	BeforeEach(func() {
+		// Check ready to run
+		err := k8stest.BeforeEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		// Check resource leakage.
+		err := k8stest.AfterEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should create consistent snapshots of a volume under fio load", func() {
+		replicaSnapshotTest()
+	})
+})
+
+var _ = BeforeSuite(func(done Done) {
+	err := k8stest.SetupTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to setup test environment in BeforeSuite : SetupTestEnv %v", err)
+
+	close(done)
+}, 60)
+
+var _ = AfterSuite(func() {
+	// NB This only tears down the local structures for talking to the cluster,
+	// not the kubernetes cluster itself.	By("tearing down the test environment")
+	err := k8stest.TeardownTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to tear down test environment in AfterSuite : TeardownTestEnv %v", err)
+
+})
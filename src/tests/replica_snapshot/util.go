@@ -0,0 +1,23 @@
+package replica_snapshot
+
+import (
+	"mayastor-e2e/common/k8stest"
+
+	. "github.com/onsi/gomega"
+)
+
+// checksumSnapshot returns the checksum of the snapshot, which must be the same for all replicas
+func checksumSnapshot(snapshot *k8stest.MsvSnapshot) string {
+	var first string
+	for ix, replica := range snapshot.Replicas {
+		cksum, err := k8stest.ChecksumSnapshot(replica)
+		Expect(err).ToNot(HaveOccurred(), "failed to checksum snapshot %s on %s, %v", replica.Name, replica.Address, err)
+		if ix == 0 {
+			first = cksum
+		} else {
+			Expect(cksum).To(Equal(first), "snapshot %s checksums differ, %s: %s, %s: %s",
+				snapshot.Name, snapshot.Replicas[0].Address, first, replica.Address, cksum)
+		}
+	}
+	return first
+}
@@ -0,0 +1,122 @@
+package thin_provisioning
+
+import (
+	"testing"
+
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/k8stest"
+	"mayastor-e2e/common/mayastorclient"
+	mayastorGrpc "mayastor-e2e/common/mayastorclient/protobuf"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestThinProvisioning(t *testing.T) {
+	// Initialise test and set class and file names for reports
+	k8stest.InitTesting(t, "Thin provisioning test", "thin_provisioning")
+}
+
+// thinProvisioningTest overcommits a pool with thin provisioned replicas,
+// then writes to one replica incrementally, checking that the pool usage
+// tracks the data written rather than the logical size of the replicas.
+func thinProvisioningTest() {
+	params := e2e_config.GetConfig().ThinProvisioning
+	addrs := k8stest.GetMayastorNodeIPAddresses()
+	Expect(addrs).ToNot(BeEmpty(), "no mayastor nodes")
+	address := addrs[0]
+	pools, err := mayastorclient.ListPools([]string{address})
+	Expect(err).ToNot(HaveOccurred(), "failed to list pools on %s, %v", address, err)
+	Expect(pools).ToNot(BeEmpty(), "no pools on %s", address)
+	pool := pools[0]
+	baseline := pool.Used
+	logf.Log.Info("Pool", "pool", pool)
+
+	// total logical size of the replicas is OvercommitPercent of the pool capacity
+	replicaSize := (pool.Capacity * uint64(params.OvercommitPercent) / 100 / uint64(params.Replicas)) / mb * mb
+	var replicaUuids []string
+	defer func() {
+		for _, replicaUuid := range replicaUuids {
+			err := mayastorclient.RmReplica(address, replicaUuid)
+			Expect(err).ToNot(HaveOccurred(), "failed to remove replica %s, %v", replicaUuid, err)
+		}
+		used := getPool(address, pool.Name).Used
+		Expect(used).To(Equal(baseline), "pool usage after removing replicas")
+	}()
+	for ix := 0; ix < params.Replicas; ix++ {
+		replicaUuid := string(uuid.NewUUID())
+		err = mayastorclient.CreateReplicaExt(address, replicaUuid, replicaSize, pool.Name, true, mayastorGrpc.ShareProtocolReplica_REPLICA_NVMF)
+		Expect(err).ToNot(HaveOccurred(), "failed to create thin replica of size %d on pool %s, %v", replicaSize, pool.Name, err)
+		replicaUuids = append(replicaUuids, replicaUuid)
+	}
+
+	for _, replicaUuid := range replicaUuids {
+		replica := getReplica(address, replicaUuid)
+		Expect(replica.Thin).To(BeTrue(), "replica %s is not thin provisioned", replicaUuid)
+		Expect(replica.Size).To(Equal(replicaSize), "replica %s logical size", replicaUuid)
+		Expect(replica.PoolUuid).ToNot(BeEmpty(), "replica %s pool uuid", replicaUuid)
+	}
+	slack := uint64(params.SlackMb) * mb
+	used := getPool(address, pool.Name).Used
+	Expect(used-baseline).To(BeNumerically("<=", slack),
+		"pool usage increased by %d on creating thin replicas", used-baseline)
+
+	// write incrementally to the first replica, the pool usage must track the data written
+	replica := getReplica(address, replicaUuids[0])
+	for step := 1; step <= params.Steps; step++ {
+		err = writeReplica(address, replica.Uri, (step-1)*params.StepMb, params.StepMb)
+		Expect(err).ToNot(HaveOccurred(), "failed to write to replica %s, %v", replica.Name, err)
+		written := uint64(step*params.StepMb) * mb
+		used = getPool(address, pool.Name).Used
+		logf.Log.Info("Pool usage", "written", written, "used", used-baseline)
+		Expect(used-baseline).To(BeNumerically(">=", written), "pool usage less than data written")
+		Expect(used-baseline).To(BeNumerically("<=", written+slack), "pool usage exceeds data written")
+
+		// the allocation of the replica must also track the data written
+		allocated := getReplica(address, replicaUuids[0]).Allocated
+		if allocated == 0 {
+			logf.Log.Info("Replica allocation is not reported", "replica", replica.Name)
+			continue
+		}
+		Expect(allocated).To(BeNumerically(">=", written), "replica allocation less than data written")
+		Expect(allocated).To(BeNumerically("<=", written+slack), "replica allocation exceeds data written")
+	}
+}
+
+var _ = Describe("Thin provisioning tests", func() {
+
+	BeforeEach(func() {
+		// Check ready to run
+		err := k8stest.BeforeEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		// Check resource leakage.
+		err := k8stest.AfterEachCheck()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should allocate pool space for thin provisioned replicas as data is written", func() {
+		thinProvisioningTest()
+	})
+})
+
+var _ = BeforeSuite(func(done Done) {
+	err := k8stest.SetupTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to setup test environment in BeforeSuite : SetupTestEnv %v", err)
+
+	close(done)
+}, 60)
+
+var _ = AfterSuite(func() {
+	// NB This only tears down the local structures for talking to the cluster,
+	// not the kubernetes cluster itself.	By("tearing down the test environment")
+	err := k8stest.TeardownTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to tear down test environment in AfterSuite : TeardownTestEnv %v", err)
+
+})
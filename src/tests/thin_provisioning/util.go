@@ -0,0 +1,80 @@
+package thin_provisioning
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	agent "mayastor-e2e/common/e2e-agent"
+	"mayastor-e2e/common/mayastorclient"
+
+	. "github.com/onsi/gomega"
+)
+
+const mb = 1024 * 1024
+
+// getPool returns the pool on the node with ip address address
+func getPool(address string, poolName string) mayastorclient.MayastorPool {
+	pool, err := mayastorclient.GetPool(poolName, address)
+	Expect(err).ToNot(HaveOccurred(), "failed to get pool %s on %s, %v", poolName, address, err)
+	Expect(pool).ToNot(BeNil(), "pool %s not found on %s", poolName, address)
+	return *pool
+}
+
+// getReplica returns the replica with uuid on the node with ip address address
+func getReplica(address string, uuid string) mayastorclient.MayastorReplica {
+	replicas, err := mayastorclient.FindReplicas(uuid, []string{address})
+	Expect(err).ToNot(HaveOccurred(), "failed to list replicas on %s, %v", address, err)
+	Expect(len(replicas)).To(Equal(1), "replica %s not found on %s", uuid, address)
+	return replicas[0]
+}
+
+// replicaNqn returns the nqn from a replica uri,
+// e.g. nvmf://10.1.0.2:8420/nqn.2019-05.io.openebs:<uuid>?uuid=<uuid>
+func replicaNqn(uri string) (string, error) {
+	ix := strings.Index(uri, "nqn.")
+	if ix < 0 {
+		return "", fmt.Errorf("no nqn in replica uri %s", uri)
+	}
+	nqn := uri[ix:]
+	if ix = strings.Index(nqn, "?"); ix >= 0 {
+		nqn = nqn[:ix]
+	}
+	return nqn, nil
+}
+
+// writeReplica writes sizeMb of random data at offsetMb to the replica with uri,
+// the replica is connected over nvmf from the node with ip address address for the duration of the write
+func writeReplica(address string, uri string, offsetMb int, sizeMb int) error {
+	nqn, err := replicaNqn(uri)
+	if err != nil {
+		return err
+	}
+	out, err := agent.Exec(address, fmt.Sprintf("nvme connect -a %s -t tcp -s 8420 -n %s", address, nqn))
+	if err != nil {
+		return fmt.Errorf("nvme connect failed %v, %s", err, out)
+	}
+	defer func() {
+		_, _ = agent.Exec(address, "nvme disconnect -n "+nqn)
+	}()
+	// the device appears asynchronously
+	var namespaces []string
+	for ix := 0; ix < 30 && len(namespaces) == 0; ix++ {
+		if ix != 0 {
+			time.Sleep(time.Second)
+		}
+		if namespaces, err = agent.FindNvmeNamespaces(address, nqn); err != nil {
+			return err
+		}
+	}
+	if len(namespaces) == 0 {
+		return fmt.Errorf("nvme device for %s not found", nqn)
+	}
+	device := "/dev/" + filepath.Base(namespaces[0])
+	cmd := fmt.Sprintf("dd if=/dev/urandom of=%s bs=1M seek=%d count=%d oflag=direct conv=fsync", device, offsetMb, sizeMb)
+	if _, err = agent.Exec(address, cmd); err != nil {
+		return fmt.Errorf("%s failed %v", cmd, err)
+	}
+	return nil
+}
@@ -2,7 +2,6 @@ package validate_integrity_test
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 	"time"
 
@@ -65,10 +64,9 @@ func blankCentreBlock(nodeIP string, device string, sizeMB int) {
 		seekParam,
 		blockSizeParam,
 	}
-	args := strings.Join(cmdArgs, " ")
-	logf.Log.Info("Executing", "cmd", args)
+	logf.Log.Info("Executing", "cmd", cmdArgs)
 
-	_, err := client.Exec(nodeIP, args)
+	_, err := client.ExecCommand(nodeIP, client.ExecRequest{Argv: cmdArgs})
 	if err != nil {
 		logf.Log.Info("Running agent failed", "error", err)
 	}
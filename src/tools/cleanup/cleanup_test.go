@@ -1,7 +1,6 @@
 package cleanup
 
 import (
-	"mayastor-e2e/common/e2e_config"
 	"testing"
 
 	"mayastor-e2e/common/k8stest"
@@ -24,7 +23,7 @@ var _ = Describe("Mayastor setup", func() {
 	It("should clean up test artefacts in the cluster", func() {
 		cleaned := k8stest.CleanUp()
 		Expect(cleaned).To(BeTrue())
-		if len(e2e_config.GetConfig().PoolDevice) != 0 {
+		if k8stest.PoolsConfigured() {
 			err := k8stest.RestoreConfiguredPools()
 			Expect(err).To(BeNil(), "Not all pools are online after restoration")
 		}
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"mayastor-e2e/common/e2e_config"
+
 	coreV1 "k8s.io/api/core/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -60,8 +62,11 @@ func MakePriviligedContainer(image string, name string, args []string) coreV1.Co
 	}
 }
 
+// DeployFio runs fio with profile on a volume, fio runs fioLoops times
+// with the think time between blocks if specified
 func DeployFio(
 	fioImage string,
+	profile e2e_config.FioProfile,
 	fioPodName string,
 	pvcName string,
 	volumeType VolumeType,
@@ -126,7 +131,7 @@ func DeployFio(
 
 	// 1) directives for all fio jobs
 	podArgs = append(podArgs, []string{"---", "fio"}...)
-	podArgs = append(podArgs, GetDefaultFioArguments()...)
+	podArgs = append(podArgs, profile.Options()...)
 
 	if volumeType == VolFileSystem {
 		// for FS play safe use filesize which is 75% of volume size
@@ -1,5 +1,7 @@
 package k8sclient
 
+import "mayastor-e2e/common/e2e_config"
+
 var nsMayastor = "mayastor"       //e2e_config.GetConfig().Platform.MayastorNamespace
 var fioImage = "mayadata/e2e-fio" //e2e_config.GetConfig().E2eFioImage
 var fsxImage = "mayadata/e2e-fsx" //e2e_config.GetConfig().E2eFsxImage
@@ -9,32 +11,15 @@ func NSMayastor() string {
 	return nsMayastor
 }
 
-// default fio arguments for E2E fio runs
-var fioArgs = []string{
-	"--name=benchtest",
-	"--numjobs=1",
-}
-
-var fioParams = []string{
-	"--direct=1",
-	"--rw=randrw",
-	"--ioengine=libaio",
-	"--bs=4k",
-	"--iodepth=16",
-	"--verify=crc32",
-	"--verify_fatal=1",
-	"--verify_async=2",
-}
-
 // GetFioArgs return the default command line for fio - for use with Mayastor,
 // for single volume
 func GetFioArgs() []string {
-	return append(fioArgs, fioParams...)
+	return e2e_config.DefaultFioProfile().Args()
 }
 
 // GetDefaultFioArguments return the default settings (arguments) for fio - for use with Mayastor
 func GetDefaultFioArguments() []string {
-	return fioParams
+	return e2e_config.DefaultFioProfile().Options()
 }
 
 func GetFioImage() string {
@@ -5,6 +5,8 @@ import (
 	"os"
 	"sync"
 
+	"mayastor-e2e/common/e2e_config"
+
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"gopkg.in/yaml.v2"
@@ -51,6 +53,8 @@ type ExtendedTestConfig struct {
 	SendEvent    int    `yaml:"sendEvent" env-default:"1" env:"SENDEVENT"`
 	SendXrayTest int    `yaml:"sendXrayTest" env-default:"1" env:"SENDXRAYTEST"`
 	XrayTestID   string `yaml:"test" env:"e2e_test"`
+	// Fio the profile of the fio workloads
+	Fio e2e_config.FioProfile `yaml:"fio"`
 
 	// Individual Test parameters
 	SteadyState struct {
@@ -64,6 +64,7 @@ func testVolume(
 		// deploy fio
 		if err = k8sclient.DeployFio(
 			testConductor.Config.E2eFioImage,
+			testConductor.Config.Fio,
 			fio_name,
 			pvc_name,
 			vol_type,
@@ -18,7 +18,10 @@ import (
 
 func checkDeviceState(nodeIp string, poolDevice string, state string) error {
 	cmd := "cat /sys/block/" + poolDevice + "/device/state"
-	res, _ := e2eagent.Exec(nodeIp, cmd)
+	res, err := e2eagent.Exec(nodeIp, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to get the state of %s, error: %v", poolDevice, err)
+	}
 	res = strings.TrimRight(res, "\n")
 	if res != state {
 		return fmt.Errorf("unexpected state: expected %s, got %s", state, res)
@@ -109,6 +112,7 @@ func perturbVolume(
 	// deploy fio
 	if err = k8sclient.DeployFio(
 		testConductor.Config.E2eFioImage,
+		testConductor.Config.Fio,
 		fio_name,
 		pvc_name,
 		vol_type,
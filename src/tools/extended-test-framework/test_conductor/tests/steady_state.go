@@ -73,6 +73,7 @@ func SteadyStateTest(testConductor *tc.TestConductor) error {
 	// deploy fio
 	if err = k8sclient.DeployFio(
 		testConductor.Config.E2eFioImage,
+		testConductor.Config.Fio,
 		fio_name,
 		pvc_name,
 		vol_type,
@@ -3,6 +3,8 @@ package tests
 import (
 	"fmt"
 	"hash/fnv"
+	e2ecommon "mayastor-e2e/common"
+	"mayastor-e2e/common/consistency"
 	e2eagent "mayastor-e2e/common/e2e-agent"
 	"mayastor-e2e/tools/extended-test-framework/common/custom_resources"
 	"mayastor-e2e/tools/extended-test-framework/common/mini_mcp_client"
@@ -40,8 +42,6 @@ const MCP_MSV_FAULTED = "Faulted"
 const MCP_MSV_ONLINE = "Online"
 const MCP_MSV_UNKNOWN = "Unknown"
 
-const PV_PREFIX = "pvc-"
-
 type deviceDescriptor struct {
 	node   string
 	device string
@@ -53,103 +53,135 @@ var violations = []models.WorkloadViolationEnum{
 	models.WorkloadViolationEnumNOTPRESENT,
 }
 
-var faultedPVCs = map[string]bool{}
+// the number of consecutive checks in which an inconsistency must be found before
+// it is reported, the layers are updated asynchronously so transient
+// inconsistencies are expected while resources change state
+const inconsistencyPersistChecks = 6
+
+// the number of consecutive checks in which each inconsistency was found, keyed by kind and id
+var inconsistencyCounts = map[string]int{}
 
-func checkPVCexists(vol_id string) (bool, error) {
-	pvc_list, err := k8sclient.ListPVCs(k8sclient.NSDefault)
+func listCrdPools() ([]consistency.Pool, error) {
+	crdPools, err := custom_resources.ListMsPools()
 	if err != nil {
-		return false, err
-	}
-	for _, pvc := range pvc_list.Items {
-		if pvc.Spec.VolumeName == PV_PREFIX+vol_id {
-			return true, err
-		}
+		return nil, err
 	}
-	return false, err
+	return consistency.CrdPools(crdPools)
 }
 
-func checkPVCexistsViaNexusUuid(ms_ip string, nexus_uuid string) (bool, string, error) {
-
-	vols, err := mini_mcp_client.GetVolumes(ms_ip)
+func listNodeAddresses() (map[string]string, error) {
+	nodes, err := k8sclient.GetNodeLocs()
 	if err != nil {
-		return false, "", fmt.Errorf("failed to get volumes, error: %v", err)
+		return nil, err
 	}
-	// iterate through the volumes until we get the one with the right target uuid
-	for _, vol := range vols {
-		if vol.State.Target.Uuid == nexus_uuid {
-			vol_presence, err := checkPVCexists(vol.State.Uuid)
-			return vol_presence, vol.State.Uuid, err
+	addrs := make(map[string]string)
+	for _, node := range nodes {
+		if node.MayastorNode {
+			addrs[node.NodeName] = node.IPAddress
 		}
 	}
-	return false, "", err
+	return addrs, nil
 }
 
-func CheckMSVwithCP(ms_ip string) error {
-	vols, err := mini_mcp_client.GetVolumes(ms_ip)
+func listCrdVolumes() ([]e2ecommon.MayastorVolume, error) {
+	crdVols, err := custom_resources.CRD_ListMsVols()
 	if err != nil {
-		return fmt.Errorf("failed to get volumes, error: %v", err)
-	}
-	for _, vol := range vols {
-		switch vol.State.Status {
-
-		case MCP_MSV_FAULTED:
-			_, done := faultedPVCs[vol.State.Uuid]
-			if !done {
-				logf.Log.Info("Faulted MSV detected", "uuid", vol.State.Uuid)
-				exists, err := checkPVCexists(vol.State.Uuid)
-				if err != nil {
-					return fmt.Errorf("failed to check PVC exists, error: %v", err)
-				}
-				if exists {
-					faultedPVCs[vol.State.Uuid] = true
-					return fmt.Errorf("found faulted volume, uuid %s", vol.State.Uuid)
-				} else {
-					logf.Log.Info("Spurious faulted volume", "uuid", vol.State.Uuid)
-				}
-			}
-		case MCP_MSV_ONLINE:
-		case MCP_MSV_DEGRADED:
-		case MCP_MSV_UNKNOWN:
+		return nil, err
+	}
+	var vols []e2ecommon.MayastorVolume
+	for _, crdVol := range crdVols {
+		var children []e2ecommon.NexusChild
+		for _, child := range crdVol.Status.Nexus.Children {
+			children = append(children, e2ecommon.NexusChild{State: child.State, Uri: child.Uri})
+		}
+		var replicas []e2ecommon.Replica
+		for _, replica := range crdVol.Status.Replicas {
+			replicas = append(replicas, e2ecommon.Replica{
+				Node:    replica.Node,
+				Offline: replica.Offline,
+				Pool:    replica.Pool,
+				Uri:     replica.Uri,
+			})
+		}
+		vols = append(vols, e2ecommon.MayastorVolume{
+			Name: crdVol.Name,
+			Status: e2ecommon.MayastorVolumeStatus{
+				Nexus: e2ecommon.Nexus{
+					Children:  children,
+					DeviceUri: crdVol.Status.Nexus.DeviceUri,
+					Node:      crdVol.Status.Nexus.Node,
+					State:     crdVol.Status.Nexus.State,
+					Uuid:      crdVol.Name,
+				},
+				Reason:   crdVol.Status.Reason,
+				Replicas: replicas,
+				Size:     crdVol.Status.Size,
+				State:    crdVol.Status.State,
+			},
+		})
+	}
+	return vols, nil
+}
+
+// findings of faulted volumes and nexuses, which are reported with the inconsistencies
+const (
+	faultedVolume consistency.FindingKind = "FaultedVolume"
+	faultedNexus  consistency.FindingKind = "FaultedNexus"
+)
 
-		default:
-			return fmt.Errorf("MSV unexpected state %s in volume %s", vol.State.Status, vol.State.Uuid)
+// faultFindings returns a finding for every faulted volume CR, and for every faulted nexus
+// of a volume CR, nexuses which do not belong to a volume are reported as inconsistencies
+func faultFindings(state *consistency.ClusterState) []consistency.Finding {
+	var findings []consistency.Finding
+	volumes := make(map[string]bool)
+	for _, msv := range state.CrdVolumes {
+		volumes[msv.Name] = true
+		if strings.EqualFold(msv.Status.State, MCP_MSV_FAULTED) {
+			findings = append(findings, consistency.Finding{Kind: faultedVolume, Id: msv.Name, Detail: "volume is faulted"})
+		}
+	}
+	for _, node := range state.Nodes {
+		for _, nexus := range node.Nexuses {
+			if nexus.State.String() == MCP_NEXUS_FAULTED && (volumes[nexus.Uuid] || volumes[nexus.Name]) {
+				findings = append(findings, consistency.Finding{Kind: faultedNexus, Id: nexus.Uuid, Address: node.Address, Detail: "nexus is faulted"})
+			}
 		}
 	}
-	return nil
+	return findings
 }
 
-func CheckMSVwithGrpc(ms_ips []string) error {
-	nexuses, err := k8sclient.GetNexuses(ms_ips)
-	if err != nil {
-		return fmt.Errorf("Nexus grpc check failed, err: %v", err)
+// CheckClusterConsistency cross-references the pool and volume CRs with the pools, replicas
+// and nexuses reported by mayastor, and checks that no volume or nexus is faulted.
+// An inconsistency or fault is reported once, when it has been found by
+// inconsistencyPersistChecks consecutive checks.
+// The control plane layer is not checked.
+func CheckClusterConsistency(ms_ips []string) error {
+	state := consistency.Gather(ms_ips, consistency.Sources{
+		CrdPools:      listCrdPools,
+		CrdVolumes:    listCrdVolumes,
+		NodeAddresses: listNodeAddresses,
+	})
+	report := state.Check()
+	if len(report.Errors) != 0 {
+		// the findings for resources on nodes which could not be listed are suppressed
+		logf.Log.Info("Cluster consistency check incomplete", "errors", report.Errors)
 	}
-	for _, nexus := range nexuses {
-		switch nexus.State.String() {
-
-		case MCP_NEXUS_FAULTED:
-			logf.Log.Info("Faulted nexus detected", "uuid", nexus.Uuid)
-			// a nexus may exist after the PVC is gone. Check that the nexus is not an orphan
-			exists, vol_uuid, err := checkPVCexistsViaNexusUuid(ms_ips[0], nexus.Uuid)
-			if err != nil {
-				return fmt.Errorf("failed to check PVC exists for nexus %s, error: %v", nexus.Uuid, err)
-			}
-			_, done := faultedPVCs[vol_uuid]
-			if !done {
-				if exists {
-					faultedPVCs[vol_uuid] = true
-					return fmt.Errorf("Nexus %s is not healthy, state is %s, vol uuid is %s", nexus.Uuid, nexus.State.String(), vol_uuid)
-				} else {
-					logf.Log.Info("Spurious faulted nexus", "nexus uuid", nexus.Uuid)
-				}
-			}
-		case MCP_NEXUS_UNKNOWN:
-		case MCP_NEXUS_DEGRADED:
-		case MCP_NEXUS_ONLINE:
-
-		default:
-			return fmt.Errorf("Nexus unexpected state %s", nexus.State.String())
+	counts := map[string]int{}
+	var msgs []string
+	for _, finding := range append(report.Findings, faultFindings(state)...) {
+		key := string(finding.Kind) + "/" + finding.Id
+		if _, seen := counts[key]; seen {
+			continue
+		}
+		counts[key] = inconsistencyCounts[key] + 1
+		if counts[key] == inconsistencyPersistChecks {
+			msgs = append(msgs, finding.String())
 		}
 	}
+	inconsistencyCounts = counts
+	if len(msgs) != 0 {
+		return fmt.Errorf("Cluster inconsistent: %s", strings.Join(msgs, "; "))
+	}
 	return nil
 }
 
@@ -276,17 +308,12 @@ func MonitorCRs(
 		}
 		ms_ips, err := k8sclient.GetMayastorNodeIPs()
 		if err != nil {
-			return fmt.Errorf("MSV grpc check failed to get nodes, err: %s", err.Error())
+			return fmt.Errorf("cluster consistency check failed to get nodes, err: %s", err.Error())
 		}
 		if len(ms_ips) == 0 {
 			return fmt.Errorf("No MS nodes found")
 		}
-		if err := CheckMSVwithGrpc(ms_ips); err != nil {
-			if senderr := SendEventTestFail(testConductor, err.Error()); senderr != nil {
-				logf.Log.Info("failed to send fail event", "error", senderr)
-			}
-		}
-		if err := CheckMSVwithCP(ms_ips[0]); err != nil {
+		if err := CheckClusterConsistency(ms_ips); err != nil {
 			if senderr := SendEventTestFail(testConductor, err.Error()); senderr != nil {
 				logf.Log.Info("failed to send fail event", "error", senderr)
 			}
@@ -343,7 +370,7 @@ func killMayastor(index int, nodecount int) error {
 	}
 	locs, err := k8sclient.GetNodeLocs()
 	if err != nil {
-		return fmt.Errorf("MSV grpc check failed to get nodes, err: %s", err.Error())
+		return fmt.Errorf("cluster consistency check failed to get nodes, err: %s", err.Error())
 	}
 	if len(locs) < nodecount {
 		return fmt.Errorf("Expected %d ips, found %d", nodecount, len(locs))
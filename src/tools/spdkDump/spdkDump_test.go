@@ -0,0 +1,52 @@
+package spdkDump
+
+import (
+	"testing"
+
+	"mayastor-e2e/common/e2e_config"
+	"mayastor-e2e/common/k8stest"
+	"mayastor-e2e/common/mayastorclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// This is run as a test but is really a utility to dump the SPDK
+// bdev, nvmf subsystem and lvol store state of mayastor instances
+// using json-rpc calls through gRPC
+func TestSpdkDump(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SPDK state dump")
+}
+
+var _ = Describe("Mayastor utility: SPDK state dump", func() {
+	It("should use json-rpc to dump the SPDK state of mayastor instances", func() {
+		Expect(mayastorclient.CanConnect()).To(BeTrue(), "unable to connect to all mayastor instances")
+		dir := e2e_config.GetConfig().SpdkDumpDir
+		if dir == "" {
+			dir = e2e_config.GetConfig().ReportsDir
+		}
+		Expect(dir).ToNot(BeEmpty(), "SPDK dump directory is not configured")
+		logf.Log.Info("Dumping SPDK state", "dir", dir)
+		err := k8stest.DumpSpdkState(dir)
+		Expect(err).ToNot(HaveOccurred(), "%v", err)
+	})
+})
+
+var _ = BeforeSuite(func(done Done) {
+	logf.SetLogger(zap.New(zap.UseDevMode(true), zap.WriteTo(GinkgoWriter)))
+	err := k8stest.SetupTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to setup test environment in BeforeSuite : SetupTestEnv %v", err)
+	close(done)
+}, 60)
+
+var _ = AfterSuite(func() {
+	// NB This only tears down the local structures for talking to the cluster,
+	// not the kubernetes cluster itself.
+	By("tearing down the test environment")
+	err := k8stest.TeardownTestEnv()
+	Expect(err).ToNot(HaveOccurred(), "failed to tear down test environment in AfterSuite : TeardownTestEnv %v", err)
+})
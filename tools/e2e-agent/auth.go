@@ -0,0 +1,194 @@
+package main
+
+// Authentication, command allow-list and audit log of requests.
+// If AGENT_SECRET is set every request must carry the header
+// "Authorization: Bearer <AGENT_SECRET>". If EXEC_ALLOW_LIST is set, a comma
+// separated list of executables, only those executables can be run using /exec
+// and /v2/exec. Every request is logged to the agent log prefixed with "audit:",
+// and appended to AUDIT_LOG_FILE if it is set. The environment and standard input
+// of commands are redacted from the audit log as they may carry credentials.
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	UnauthorizedErrorCode = 401
+	ForbiddenErrorCode    = 403
+)
+
+// the maximum length of a request body included in the audit log
+const auditMaxBody = 4096
+
+var auditLock sync.Mutex
+var auditFile *os.File
+
+// execAllowList the executables which can be run, all executables if empty
+var execAllowList map[string]bool
+
+// SetupAuth reads the authentication, allow-list and audit configuration from the environment
+func SetupAuth() error {
+	if os.Getenv("AGENT_SECRET") == "" {
+		log.Printf("AGENT_SECRET is not set, requests are not authenticated")
+	}
+	for _, name := range strings.Split(os.Getenv("EXEC_ALLOW_LIST"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if execAllowList == nil {
+				execAllowList = make(map[string]bool)
+			}
+			execAllowList[name] = true
+		}
+	}
+	if execAllowList != nil {
+		log.Printf("Executables allowed: %v", execAllowList)
+	}
+	if path := os.Getenv("AUDIT_LOG_FILE"); path != "" {
+		var err error
+		auditFile, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// IsExecAllowed returns true if the executable of argv is in the allow-list
+func IsExecAllowed(argv []string) bool {
+	if execAllowList == nil {
+		return true
+	}
+	return len(argv) != 0 && execAllowList[filepath.Base(argv[0])]
+}
+
+// authenticate checks that the request carries the agent secret as a bearer token,
+// requests are not authenticated if the agent secret is not set
+func authenticate(r *http.Request) bool {
+	secret := os.Getenv("AGENT_SECRET")
+	if secret == "" {
+		return true
+	}
+	const scheme = "Bearer "
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, scheme) {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, scheme)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// redactBody returns the request body with the values of the environment
+// variables and the standard input of a command redacted
+func redactBody(body []byte) []byte {
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return body
+	}
+	redacted := false
+	if env, ok := fields["env"].([]interface{}); ok && len(env) != 0 {
+		for ix, v := range env {
+			name := fmt.Sprint(v)
+			if eq := strings.Index(name, "="); eq >= 0 {
+				name = name[:eq]
+			}
+			env[ix] = name + "=<redacted>"
+		}
+		redacted = true
+	}
+	if stdin, ok := fields["stdin"].(string); ok && stdin != "" {
+		fields["stdin"] = "<redacted>"
+		redacted = true
+	}
+	if !redacted {
+		return body
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// statusRecorder records the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	Remote   string    `json:"remote"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Body     string    `json:"body,omitempty"`
+	Status   int       `json:"status"`
+	Duration string    `json:"duration"`
+}
+
+func audit(record auditRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	log.Printf("audit: %s", data)
+	if auditFile == nil {
+		return
+	}
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	if _, err = auditFile.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
+
+// authMiddleware rejects requests which are not authenticated,
+// and audits every request
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		record := auditRecord{
+			Time:   start,
+			Remote: r.RemoteAddr,
+			Method: r.Method,
+			Path:   r.URL.Path,
+		}
+		if r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				log.Printf("Failed to read request body: %v", err)
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			body = redactBody(body)
+			if len(body) > auditMaxBody {
+				body = body[:auditMaxBody]
+			}
+			record.Body = strings.TrimSpace(string(body))
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		if authenticate(r) {
+			next.ServeHTTP(recorder, r)
+		} else {
+			recorder.WriteHeader(UnauthorizedErrorCode)
+			fmt.Fprint(recorder, "not authenticated")
+		}
+		record.Status = recorder.status
+		record.Duration = time.Since(start).String()
+		audit(record)
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	defer os.Unsetenv("AGENT_SECRET")
+	handler := authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+
+	tests := []struct {
+		name          string
+		secret        string
+		authorization string
+		status        int
+	}{
+		{name: "no header", secret: "s3cret", status: UnauthorizedErrorCode},
+		{name: "bearer", secret: "s3cret", authorization: "Bearer s3cret", status: http.StatusOK},
+		{name: "wrong secret", secret: "s3cret", authorization: "Bearer s3cre", status: UnauthorizedErrorCode},
+		{name: "secret prefix", secret: "s3cret", authorization: "Bearer s3cret2", status: UnauthorizedErrorCode},
+		{name: "other scheme", secret: "s3cret", authorization: "Basic s3cret", status: UnauthorizedErrorCode},
+		{name: "no scheme", secret: "s3cret", authorization: "s3cret", status: UnauthorizedErrorCode},
+		{name: "empty token", secret: "s3cret", authorization: "Bearer ", status: UnauthorizedErrorCode},
+		// authentication is opt-in
+		{name: "no secret", status: http.StatusOK},
+		{name: "no secret with header", authorization: "Bearer s3cret", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("AGENT_SECRET", test.secret)
+			req := httptest.NewRequest("GET", "/faults", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != test.status {
+				t.Errorf("status %d, expected %d", recorder.Code, test.status)
+			}
+		})
+	}
+}
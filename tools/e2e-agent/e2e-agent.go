@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -42,6 +43,8 @@ func Setup() error {
 			return err
 		}
 	}
+	RemoveStaleNetworkFaults()
+	RestoreFaults()
 	return nil
 }
 
@@ -54,47 +57,60 @@ func UngracefulReboot() error {
 	return err
 }
 
-// GracefulReboot reboots the host gracefully
-// It is not yet supported
+// GracefulReboot reboots the host gracefully, services are stopped by systemd
+// and file systems are unmounted. If systemd fails to reboot the host,
+// the file systems are synced and remounted read only, and the host is rebooted
+// using sysrq shortly after returning so that the request can be answered.
+// Returns an error if the reboot could not be started.
 func GracefulReboot() error {
-	/*
-		// Send SIGTERM to all processes
-		data := []byte("e")
-		if err := ioutil.WriteFile(SYSRQ_TRIGGER_FILE, data, 0644); err != nil {
-			return err
-		}
-
-		time.Sleep(5 * time.Second)
-
-		// Send SIGKILL to remaining processes
-		data = []byte("i")
-		if err := ioutil.WriteFile(SYSRQ_TRIGGER_FILE, data, 0644); err != nil {
-			return err
+	log.Printf("Rebooting node gracefully")
+	// systemd stops the services before rebooting so the request can be answered
+	err := systemctl("reboot")
+	if err == nil {
+		return nil
+	}
+	log.Printf("systemd reboot failed, rebooting using sysrq: %v", err)
+	// sync and remount read only
+	for _, trigger := range []string{"s", "u"} {
+		if writeErr := ioutil.WriteFile(SYSRQ_TRIGGER_FILE, []byte(trigger), 0644); writeErr != nil {
+			return fmt.Errorf("%v, sysrq %s failed: %v", err, trigger, writeErr)
 		}
-
 		time.Sleep(5 * time.Second)
-
-		// Reboot the node
-		data = []byte("b")
-		if err := ioutil.WriteFile(SYSRQ_TRIGGER_FILE, data, 0644); err != nil {
-			return err
+	}
+	go func() {
+		time.Sleep(2 * time.Second)
+		if err := ioutil.WriteFile(SYSRQ_TRIGGER_FILE, []byte("b"), 0644); err != nil {
+			log.Printf("sysrq reboot failed: %v", err)
 		}
-	*/
+	}()
 	return nil
 }
 
-// DropConnectionsFromNodes creates rules to drop connections from other k8s nodes
-func DropConnectionsFromNodes(nodes []string) error {
+// DropConnectionsFromNodes creates rules to drop connections from other k8s nodes,
+// the rule for each node is a fault which is reverted when ttl expires
+func DropConnectionsFromNodes(nodes []string, ttl time.Duration) ([]Fault, error) {
 	log.Printf("Drop Connections from %v", nodes)
+	var faults []Fault
 	for _, node := range nodes {
 		// because we specify eth0 interface we can DROP even our IP w/o problems, because self communication uses lo
 		cmd := exec.Command("iptables", "-t", "mangle", "-I", "PREROUTING", "-i", "eth0", "-s", node, "-j", "DROP", "-m", "comment", "--comment", "mayastor-e2e-test")
 		_, err := cmd.Output()
 		if err != nil {
-			return err
+			// connections are dropped from all nodes or none
+			return nil, revertPartialFaults(faults, fmt.Errorf("failed to drop connections from %s: %v", node, err))
 		}
+		node := node
+		faults = append(faults, AddFault(FaultDropConnections, node, ttl, func() error {
+			return acceptConnectionsFromNode(node)
+		}))
 	}
-	return nil
+	return faults, nil
+}
+
+func acceptConnectionsFromNode(node string) error {
+	cmd := exec.Command("iptables", "-t", "mangle", "-D", "PREROUTING", "-i", "eth0", "-s", node, "-j", "DROP", "-m", "comment", "--comment", "mayastor-e2e-test")
+	_, err := cmd.Output()
+	return err
 }
 
 // AcceptConnectionsFromNodes removes the rules set by
@@ -102,11 +118,64 @@ func DropConnectionsFromNodes(nodes []string) error {
 func AcceptConnectionsFromNodes(nodes []string) error {
 	log.Printf("Accept Connections from %v", nodes)
 	for _, node := range nodes {
-		cmd := exec.Command("iptables", "-t", "mangle", "-D", "PREROUTING", "-i", "eth0", "-s", node, "-j", "DROP", "-m", "comment", "--comment", "mayastor-e2e-test")
-		_, err := cmd.Output()
-		if err != nil {
+		if err := acceptConnectionsFromNode(node); err != nil {
 			return err
 		}
+		ResolveFault(FaultDropConnections, node)
+	}
+	return nil
+}
+
+// SetDeviceState sets the state of a block device e.g. sdb,
+// the only accepted states are "running" and "offline".
+// Setting a device offline is a fault which is reverted when ttl expires.
+func SetDeviceState(device string, state string, ttl time.Duration) (*Fault, error) {
+	log.Printf("Set device %s %s", device, state)
+	err := ioutil.WriteFile("/host/sys/block/"+device+"/device/state", []byte(state), 0644)
+	if err != nil {
+		return nil, err
+	}
+	if state == "running" {
+		for ResolveFault(FaultDeviceOffline, device) {
+		}
+		return nil, nil
+	}
+	fault := AddFault(FaultDeviceOffline, device, ttl, func() error {
+		_, err := SetDeviceState(device, "running", 0)
+		return err
+	})
+	return &fault, nil
+}
+
+// CreateFaultyDevice creates a device mapper device from table,
+// the device is a fault which is removed when ttl expires
+func CreateFaultyDevice(name string, table string, ttl time.Duration) (Fault, []byte, error) {
+	f, err := ioutil.TempFile("", "table")
+	if err != nil {
+		return Fault{}, nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(table)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Fault{}, nil, err
+	}
+	output, err := exec.Command("dmsetup", "create", name, f.Name()).CombinedOutput()
+	if err != nil {
+		return Fault{}, output, err
+	}
+	fault := AddFault(FaultFaultyDevice, name, ttl, func() error {
+		return removeFaultyDevice(name)
+	})
+	return fault, output, nil
+}
+
+func removeFaultyDevice(name string) error {
+	output, err := exec.Command("dmsetup", "remove", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, output)
 	}
 	return nil
 }
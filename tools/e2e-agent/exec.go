@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// the timeout of commands for which the request does not specify one
+const defaultExecTimeoutSecs = 300
+
+// ExecRequest a command to execute, Argv is not interpreted by a shell
+type ExecRequest struct {
+	Argv []string `json:"argv"`
+	// Env variables in the form KEY=VALUE, added to the environment of the agent
+	Env   []string `json:"env"`
+	Stdin string   `json:"stdin"`
+	// Dir the working directory
+	Dir         string `json:"dir"`
+	TimeoutSecs int    `json:"timeoutSecs"`
+}
+
+// ExecResult the result of a command, ExitCode is -1 if the command timed out
+// or was killed by a signal
+type ExecResult struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exitCode"`
+	DurationMs int64  `json:"durationMs"`
+	TimedOut   bool   `json:"timedOut"`
+}
+
+// ExecCommand executes a command, the command and all processes it starts are
+// killed if it does not complete within the timeout. Returns an error only if the
+// command could not be started, the exit status is returned in the result.
+func ExecCommand(req ExecRequest) (ExecResult, error) {
+	var result ExecResult
+	if len(req.Argv) == 0 {
+		return result, fmt.Errorf("no command passed")
+	}
+	timeoutSecs := req.TimeoutSecs
+	if timeoutSecs <= 0 {
+		timeoutSecs = defaultExecTimeoutSecs
+	}
+	// the values of environment variables are not logged as they may be credentials
+	var envNames []string
+	for _, env := range req.Env {
+		envNames = append(envNames, strings.SplitN(env, "=", 2)[0])
+	}
+	log.Printf("Exec %q dir %q env %q timeout %ds", req.Argv, req.Dir, envNames, timeoutSecs)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(req.Argv[0], req.Argv[1:]...)
+	cmd.Env = append(os.Environ(), req.Env...)
+	cmd.Dir = req.Dir
+	cmd.Stdin = strings.NewReader(req.Stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// run the command in its own process group so that the processes it starts
+	// are killed on timeout, otherwise they keep its output open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return result, err
+	}
+	var timedOut int32
+	timer := time.AfterFunc(time.Duration(timeoutSecs)*time.Second, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			log.Printf("Failed to kill %q: %v", req.Argv, err)
+		}
+	})
+	err := cmd.Wait()
+	timer.Stop()
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.TimedOut = atomic.LoadInt32(&timedOut) != 0
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return result, err
+		}
+	}
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	if result.TimedOut {
+		result.ExitCode = -1
+	}
+	log.Printf("Exec %q exit code %d in %dms", req.Argv, result.ExitCode, result.DurationMs)
+	return result, nil
+}
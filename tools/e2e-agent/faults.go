@@ -0,0 +1,296 @@
+package main
+
+// Leases of the faults applied by the agent.
+// Every fault is registered with a function which reverts it, and is reverted
+// when its lease expires, so that a test which fails to revert a fault does not
+// leave the node faulted for subsequent tests. Faults can be listed and reverted
+// using the /faults endpoint.
+// The active faults are saved to FAULTS_FILE if it is set, which should be on the host,
+// so that the faults of a previous instance of the agent are restored on startup.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FaultDropConnections = "dropConnections"
+	FaultDeviceOffline   = "deviceOffline"
+	FaultFaultyDevice    = "faultyDevice"
+	FaultNetwork         = "network"
+)
+
+// the lease of faults for which the request does not specify one
+const defaultFaultTtlSecs = 1800
+
+// Fault a fault applied by the agent which is active until it is reverted
+// or its lease expires
+type Fault struct {
+	Id   string `json:"id"`
+	Kind string `json:"kind"`
+	// Target the node, device or port(s) which are faulted
+	Target  string    `json:"target"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+type activeFault struct {
+	fault  Fault
+	revert func() error
+	timer  *time.Timer
+}
+
+var faultsLock sync.Mutex
+var activeFaults = make(map[string]*activeFault)
+var faultCount int
+
+// the file to which the active faults are saved, faults are not saved if empty
+var faultsFile = os.Getenv("FAULTS_FILE")
+
+// faultTtl returns the lease of a fault, ttlSecs if set otherwise the default
+// which may be set with the environment variable FAULT_TTL_SECS
+func faultTtl(ttlSecs int) time.Duration {
+	if ttlSecs <= 0 {
+		ttlSecs = defaultFaultTtlSecs
+		if env, err := strconv.Atoi(os.Getenv("FAULT_TTL_SECS")); err == nil && env > 0 {
+			ttlSecs = env
+		}
+	}
+	return time.Duration(ttlSecs) * time.Second
+}
+
+// AddFault registers a fault which has been applied, revert is called
+// when the fault is reverted or its lease expires
+func AddFault(kind string, target string, ttl time.Duration, revert func() error) Fault {
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+	faultCount++
+	now := time.Now()
+	fault := Fault{
+		Id:      fmt.Sprintf("%s-%d", kind, faultCount),
+		Kind:    kind,
+		Target:  target,
+		Created: now,
+		Expires: now.Add(ttl),
+	}
+	leaseFault(fault, revert)
+	log.Printf("Added fault %+v", fault)
+	return fault
+}
+
+// leaseFault adds a fault to the active faults until fault.Expires, faultsLock must be held
+func leaseFault(fault Fault, revert func() error) {
+	activeFaults[fault.Id] = &activeFault{
+		fault:  fault,
+		revert: revert,
+		timer: time.AfterFunc(time.Until(fault.Expires), func() {
+			log.Printf("Lease of fault %s expired", fault.Id)
+			if err := RevertFault(fault.Id); err != nil {
+				log.Print(err)
+			}
+		}),
+	}
+	saveFaults()
+}
+
+// takeFault removes a fault from the active faults, faultsLock must be held
+func takeFault(id string) *activeFault {
+	f, ok := activeFaults[id]
+	if !ok {
+		return nil
+	}
+	f.timer.Stop()
+	delete(activeFaults, id)
+	saveFaults()
+	return f
+}
+
+// ResolveFault removes the oldest fault of kind on target from the active faults
+// without reverting it, it has been reverted by the request which undoes the fault.
+// Returns false if there is no such fault.
+func ResolveFault(kind string, target string) bool {
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+	var oldest *activeFault
+	for _, f := range activeFaults {
+		if f.fault.Kind == kind && f.fault.Target == target {
+			if oldest == nil || f.fault.Created.Before(oldest.fault.Created) {
+				oldest = f
+			}
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	takeFault(oldest.fault.Id)
+	log.Printf("Resolved fault %s", oldest.fault.Id)
+	return true
+}
+
+// RevertFault reverts a fault
+func RevertFault(id string) error {
+	faultsLock.Lock()
+	f := takeFault(id)
+	faultsLock.Unlock()
+	if f == nil {
+		return fmt.Errorf("no fault %s", id)
+	}
+	log.Printf("Reverting fault %s", id)
+	if err := f.revert(); err != nil {
+		return fmt.Errorf("failed to revert fault %s: %v", id, err)
+	}
+	return nil
+}
+
+// revertPartialFaults reverts the faults applied by a request which failed with err
+// before applying all its faults, returns err and the errors reverting the faults
+func revertPartialFaults(faults []Fault, err error) error {
+	var errs []error
+	for ix := len(faults) - 1; ix >= 0; ix-- {
+		if revertErr := RevertFault(faults[ix].Id); revertErr != nil {
+			errs = append(errs, revertErr)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v, applied faults not reverted: %v", err, errs)
+	}
+	return err
+}
+
+// RevertFaults reverts all faults, most recent first
+func RevertFaults() error {
+	var taken []*activeFault
+	faultsLock.Lock()
+	for _, fault := range sortedFaults() {
+		taken = append(taken, takeFault(fault.Id))
+	}
+	faultsLock.Unlock()
+	var errs []error
+	for _, f := range taken {
+		log.Printf("Reverting fault %s", f.fault.Id)
+		if err := f.revert(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to revert fault %s: %v", f.fault.Id, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to revert faults: %v", errs)
+	}
+	return nil
+}
+
+// ListFaults returns the active faults, most recent first
+func ListFaults() []Fault {
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+	return sortedFaults()
+}
+
+// saveFaults writes the active faults to faultsFile, faultsLock must be held
+func saveFaults() {
+	if faultsFile == "" {
+		return
+	}
+	data, err := json.Marshal(sortedFaults())
+	if err == nil {
+		// replace the file so that it is never partially written
+		tmpFile := faultsFile + ".tmp"
+		if err = ioutil.WriteFile(tmpFile, data, 0600); err == nil {
+			err = os.Rename(tmpFile, faultsFile)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save faults to %s: %v", faultsFile, err)
+	}
+}
+
+// staleFaultRevert returns the function which reverts a fault applied by a previous
+// instance of the agent, or nil if the fault does not outlive the agent
+func staleFaultRevert(fault Fault) func() error {
+	switch fault.Kind {
+	case FaultDropConnections:
+		return func() error { return acceptConnectionsFromNode(fault.Target) }
+	case FaultDeviceOffline:
+		return func() error {
+			_, err := SetDeviceState(fault.Target, "running", 0)
+			return err
+		}
+	case FaultFaultyDevice:
+		return func() error { return removeFaultyDevice(fault.Target) }
+	case FaultServiceStopped:
+		return func() error { return systemctl(ServiceStart, fault.Target) }
+	case FaultProcessStopped:
+		return func() error { return continueProcesses(fault.Target) }
+	}
+	// network faults are removed by RemoveStaleNetworkFaults
+	return nil
+}
+
+// RestoreFaults restores the leases of the faults saved by a previous instance
+// of the agent, faults whose lease has expired are reverted
+func RestoreFaults() {
+	if faultsFile == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(faultsFile), 0700); err != nil {
+		log.Printf("Failed to create the directory of %s: %v", faultsFile, err)
+		return
+	}
+	data, err := ioutil.ReadFile(faultsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read faults from %s: %v", faultsFile, err)
+		}
+		return
+	}
+	var faults []Fault
+	if err = json.Unmarshal(data, &faults); err != nil {
+		log.Printf("Failed to parse faults from %s: %v", faultsFile, err)
+		return
+	}
+	var expired []*activeFault
+	faultsLock.Lock()
+	for _, fault := range faults {
+		// do not reuse the ids of restored faults
+		if n, err := strconv.Atoi(fault.Id[strings.LastIndex(fault.Id, "-")+1:]); err == nil && n > faultCount {
+			faultCount = n
+		}
+		revert := staleFaultRevert(fault)
+		if revert == nil {
+			log.Printf("Dropped fault %+v", fault)
+		} else if time.Now().After(fault.Expires) {
+			expired = append(expired, &activeFault{fault: fault, revert: revert})
+		} else {
+			leaseFault(fault, revert)
+			log.Printf("Restored fault %+v", fault)
+		}
+	}
+	saveFaults()
+	faultsLock.Unlock()
+	for _, f := range expired {
+		log.Printf("Reverting expired fault %s", f.fault.Id)
+		if err := f.revert(); err != nil {
+			log.Printf("Failed to revert fault %s: %v", f.fault.Id, err)
+		}
+	}
+}
+
+// sortedFaults returns the active faults, most recent first, faultsLock must be held
+func sortedFaults() []Fault {
+	faults := []Fault{}
+	for _, f := range activeFaults {
+		faults = append(faults, f.fault)
+	}
+	sort.Slice(faults, func(i, j int) bool {
+		return faults[i].Created.After(faults[j].Created)
+	})
+	return faults
+}
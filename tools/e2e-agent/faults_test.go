@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetFaults drops the active faults without reverting them and saves faults to file
+func resetFaults(file string) {
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+	for _, f := range activeFaults {
+		f.timer.Stop()
+	}
+	activeFaults = make(map[string]*activeFault)
+	faultsFile = file
+}
+
+func TestFaultLease(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		revert   bool
+		reverted bool
+	}{
+		{name: "expired", ttl: 10 * time.Millisecond, reverted: true},
+		{name: "reverted", ttl: time.Hour, revert: true, reverted: true},
+		{name: "active", ttl: time.Hour},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetFaults("")
+			defer resetFaults("")
+			var reverts int32
+			fault := AddFault(FaultDeviceOffline, "sdz", test.ttl, func() error {
+				atomic.AddInt32(&reverts, 1)
+				return nil
+			})
+			if test.revert {
+				if err := RevertFault(fault.Id); err != nil {
+					t.Fatal(err)
+				}
+				if err := RevertFault(fault.Id); err == nil {
+					t.Errorf("reverted fault %s twice", fault.Id)
+				}
+			}
+			time.Sleep(100 * time.Millisecond)
+			expected := int32(0)
+			if test.reverted {
+				expected = 1
+			}
+			if n := atomic.LoadInt32(&reverts); n != expected {
+				t.Errorf("fault reverted %d times, expected %d", n, expected)
+			}
+			if active := len(ListFaults()) != 0; active == test.reverted {
+				t.Errorf("fault active %v, expected %v", active, !test.reverted)
+			}
+		})
+	}
+}
+
+func TestRevertPartialFaults(t *testing.T) {
+	resetFaults("")
+	defer resetFaults("")
+	var reverted []string
+	var faults []Fault
+	for _, target := range []string{"10.0.0.1", "10.0.0.2"} {
+		target := target
+		faults = append(faults, AddFault(FaultDropConnections, target, time.Hour, func() error {
+			reverted = append(reverted, target)
+			return nil
+		}))
+	}
+	err := revertPartialFaults(faults, os.ErrPermission)
+	if err != os.ErrPermission {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(reverted) != 2 || reverted[0] != "10.0.0.2" || reverted[1] != "10.0.0.1" {
+		t.Errorf("unexpected reverts %v", reverted)
+	}
+	if len(ListFaults()) != 0 {
+		t.Errorf("faults not removed %v", ListFaults())
+	}
+}
+
+func TestFaultsRestored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "e2e-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "faults", "faults.json")
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		t.Fatal(err)
+	}
+	resetFaults(file)
+	defer resetFaults("")
+
+	tests := []struct {
+		kind     string
+		ttl      time.Duration
+		restored bool
+	}{
+		{kind: FaultDeviceOffline, ttl: time.Hour, restored: true},
+		{kind: FaultServiceStopped, ttl: time.Hour, restored: true},
+		// network faults do not outlive the agent
+		{kind: FaultNetwork, ttl: time.Hour},
+		// the revert of the expired fault fails as the device does not exist
+		{kind: FaultDeviceOffline, ttl: 50 * time.Millisecond},
+	}
+	noop := func() error { return nil }
+	var saved []Fault
+	for _, test := range tests {
+		saved = append(saved, AddFault(test.kind, "e2e-agent-test", test.ttl, noop))
+	}
+	// stop the leases, as if the agent had exited
+	faultsLock.Lock()
+	for _, f := range activeFaults {
+		f.timer.Stop()
+	}
+	activeFaults = make(map[string]*activeFault)
+	faultCount = 0
+	faultsLock.Unlock()
+	time.Sleep(100 * time.Millisecond)
+
+	RestoreFaults()
+	restored := make(map[string]Fault)
+	for _, fault := range ListFaults() {
+		restored[fault.Id] = fault
+	}
+	for ix, test := range tests {
+		fault, ok := restored[saved[ix].Id]
+		if ok != test.restored {
+			t.Errorf("%s fault %s restored %v, expected %v", test.kind, saved[ix].Id, ok, test.restored)
+			continue
+		}
+		if ok && (fault.Kind != saved[ix].Kind || fault.Target != saved[ix].Target || !fault.Expires.Equal(saved[ix].Expires)) {
+			t.Errorf("restored fault %+v, saved %+v", fault, saved[ix])
+		}
+	}
+	// ids of restored faults are not reused
+	fault := AddFault(FaultDeviceOffline, "e2e-agent-test", time.Hour, noop)
+	for _, savedFault := range saved {
+		if fault.Id == savedFault.Id {
+			t.Errorf("fault id %s reused", fault.Id)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+// Network fault injection using tc netem.
+// Faults are applied to traffic sent on the device passed in the request, or else
+// the device of the route to the peers, or of the default route if no peers are passed.
+// The root qdisc of the device is replaced by a prio qdisc while faults are
+// active on it, bands 1:1 to 1:3 carry unaffected traffic
+// using the default priomap, each fault has its own band with a netem qdisc
+// and u32 filters selecting the peers and/or ports to which the fault applies.
+// Every fault is leased for its duration, the root qdisc of a device is restored
+// when the last fault on the device is removed. Bands are not shared by faults
+// on different devices.
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// the first band used for faults, lower bands carry unaffected traffic
+	netemFirstBand = 4
+	// the maximum number of bands of a prio qdisc
+	netemMaxBands = 16
+)
+
+// NetworkFault degrades the traffic sent by this node to Peers and/or
+// to and from Ports, at least one peer or port must be specified.
+type NetworkFault struct {
+	Id    string   `json:"id"`
+	Peers []string `json:"peers"`
+	Ports []int    `json:"ports"`
+	// Device the network device, if not set the device of the route to the peers is used
+	Device         string  `json:"device"`
+	DelayMs        int     `json:"delayMs"`
+	JitterMs       int     `json:"jitterMs"`
+	LossPercent    float64 `json:"lossPercent"`
+	CorruptPercent float64 `json:"corruptPercent"`
+	// ReorderPercent requires a delay, reordered packets are sent immediately
+	ReorderPercent float64 `json:"reorderPercent"`
+	RateKbit       int     `json:"rateKbit"`
+	// DurationSecs after which the fault is removed
+	DurationSecs int       `json:"durationSecs"`
+	Expires      time.Time `json:"expires"`
+}
+
+var netemLock sync.Mutex
+
+// netemFaults the active network faults by band
+var netemFaults = make(map[int]NetworkFault)
+
+func tc(args ...string) error {
+	cmd := exec.Command("tc", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tc %v failed: %v: %s", args, err, output)
+	}
+	return nil
+}
+
+func formatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64) + "%"
+}
+
+// netemArgs returns the netem parameters of fault
+func netemArgs(fault NetworkFault) []string {
+	var args []string
+	if fault.DelayMs != 0 {
+		args = append(args, "delay", fmt.Sprintf("%dms", fault.DelayMs))
+		if fault.JitterMs != 0 {
+			args = append(args, fmt.Sprintf("%dms", fault.JitterMs))
+		}
+	}
+	if fault.LossPercent != 0 {
+		args = append(args, "loss", formatPercent(fault.LossPercent))
+	}
+	if fault.CorruptPercent != 0 {
+		args = append(args, "corrupt", formatPercent(fault.CorruptPercent))
+	}
+	if fault.ReorderPercent != 0 {
+		args = append(args, "reorder", formatPercent(fault.ReorderPercent))
+	}
+	if fault.RateKbit != 0 {
+		args = append(args, "rate", fmt.Sprintf("%dkbit", fault.RateKbit))
+	}
+	return args
+}
+
+// netemMatches returns the u32 matches of the filters selecting the traffic of fault,
+// one filter per peer and port, packets to and from a port are selected
+// so that the fault applies to both the client and the server side of a connection.
+func netemMatches(fault NetworkFault) [][]string {
+	peers := [][]string{nil}
+	if len(fault.Peers) != 0 {
+		peers = nil
+		for _, peer := range fault.Peers {
+			peers = append(peers, []string{"match", "ip", "dst", peer + "/32"})
+		}
+	}
+	ports := [][]string{nil}
+	if len(fault.Ports) != 0 {
+		ports = nil
+		for _, port := range fault.Ports {
+			ports = append(ports,
+				[]string{"match", "ip", "dport", strconv.Itoa(port), "0xffff"},
+				[]string{"match", "ip", "sport", strconv.Itoa(port), "0xffff"},
+			)
+		}
+	}
+	var matches [][]string
+	for _, peer := range peers {
+		for _, port := range ports {
+			match := append([]string{}, peer...)
+			matches = append(matches, append(match, port...))
+		}
+	}
+	return matches
+}
+
+// ValidateNetworkFault checks that a fault selects some traffic,
+// degrades it, and expires
+func ValidateNetworkFault(fault NetworkFault) error {
+	if len(fault.Peers) == 0 && len(fault.Ports) == 0 {
+		return fmt.Errorf("no peers or ports passed")
+	}
+	for _, port := range fault.Ports {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	if len(netemArgs(fault)) == 0 {
+		return fmt.Errorf("no delay, loss, corruption, reordering or rate passed")
+	}
+	if fault.ReorderPercent != 0 && fault.DelayMs == 0 {
+		return fmt.Errorf("reordering requires a delay")
+	}
+	if fault.DurationSecs <= 0 {
+		return fmt.Errorf("no duration passed")
+	}
+	return nil
+}
+
+// routeDevice returns the device of the route printed by ip route
+func routeDevice(route string) (string, error) {
+	fields := strings.Fields(route)
+	for ix := 0; ix+1 < len(fields); ix++ {
+		if fields[ix] == "dev" {
+			return fields[ix+1], nil
+		}
+	}
+	return "", fmt.Errorf("no device in route %q", route)
+}
+
+// ipRouteDevice returns the device of the route printed by ip route with args
+func ipRouteDevice(args ...string) (string, error) {
+	output, err := exec.Command("ip", append([]string{"route"}, args...)...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("ip route %v failed: %v: %s", args, err, output)
+	}
+	return routeDevice(string(output))
+}
+
+// netemDevice returns the device of fault, the device of the route to its peers,
+// which must all be routed via the same device, or the device of the default route
+func netemDevice(fault NetworkFault) (string, error) {
+	if fault.Device != "" {
+		return fault.Device, nil
+	}
+	if len(fault.Peers) == 0 {
+		return ipRouteDevice("show", "default")
+	}
+	var device string
+	for _, peer := range fault.Peers {
+		peerDevice, err := ipRouteDevice("get", peer)
+		if err != nil {
+			return "", err
+		}
+		if device != "" && peerDevice != device {
+			return "", fmt.Errorf("peers are routed via %s and %s, pass the device", device, peerDevice)
+		}
+		device = peerDevice
+	}
+	return device, nil
+}
+
+// deviceInUse returns true if a fault is applied to device, netemLock must be held
+func deviceInUse(device string) bool {
+	for _, fault := range netemFaults {
+		if fault.Device == device {
+			return true
+		}
+	}
+	return false
+}
+
+// freeBand returns a band which is not used by a fault, netemLock must be held
+func freeBand() (int, error) {
+	for band := netemFirstBand; band <= netemMaxBands; band++ {
+		if _, used := netemFaults[band]; !used {
+			return band, nil
+		}
+	}
+	return 0, fmt.Errorf("too many network faults, at most %d are supported", netemMaxBands-netemFirstBand+1)
+}
+
+// ApplyNetworkFault applies a network fault, which is removed after its duration.
+// Returns the fault with its id and expiry time.
+func ApplyNetworkFault(fault NetworkFault) (NetworkFault, error) {
+	if err := ValidateNetworkFault(fault); err != nil {
+		return fault, err
+	}
+	device, err := netemDevice(fault)
+	if err != nil {
+		return fault, err
+	}
+	fault.Device = device
+	netemLock.Lock()
+	defer netemLock.Unlock()
+
+	band, err := freeBand()
+	if err != nil {
+		return fault, err
+	}
+	if !deviceInUse(device) {
+		// default priomap of a prio qdisc, faults use bands which are not in the priomap
+		err = tc("qdisc", "replace", "dev", device, "root", "handle", "1:", "prio",
+			"bands", strconv.Itoa(netemMaxBands), "priomap", "1", "2", "2", "2", "1", "2", "0", "0", "1", "1", "1", "1", "1", "1", "1", "1")
+		if err != nil {
+			return fault, err
+		}
+	}
+	netemFaults[band] = fault
+	log.Printf("Apply network fault %+v", fault)
+
+	args := append([]string{"qdisc", "add", "dev", device, "parent", fmt.Sprintf("1:%d", band),
+		"handle", fmt.Sprintf("%d:", band*10), "netem"}, netemArgs(fault)...)
+	err = tc(args...)
+	for _, match := range netemMatches(fault) {
+		if err != nil {
+			break
+		}
+		args = append([]string{"filter", "add", "dev", device, "parent", "1:", "protocol", "ip",
+			"prio", strconv.Itoa(band), "u32"}, match...)
+		err = tc(append(args, "flowid", fmt.Sprintf("1:%d", band))...)
+	}
+	if err != nil {
+		if removeErr := removeBand(band); removeErr != nil {
+			log.Print(removeErr)
+		}
+		return fault, err
+	}
+	leased := AddFault(FaultNetwork, networkFaultTarget(fault), time.Duration(fault.DurationSecs)*time.Second, func() error {
+		netemLock.Lock()
+		defer netemLock.Unlock()
+		return removeBand(band)
+	})
+	fault.Id = leased.Id
+	fault.Expires = leased.Expires
+	netemFaults[band] = fault
+	return fault, nil
+}
+
+func networkFaultTarget(fault NetworkFault) string {
+	return fmt.Sprintf("dev %s peers %v ports %v", fault.Device, fault.Peers, fault.Ports)
+}
+
+// removeBand removes the network fault using band, netemLock must be held
+func removeBand(band int) error {
+	fault, ok := netemFaults[band]
+	if !ok {
+		return fmt.Errorf("no network fault on band %d", band)
+	}
+	delete(netemFaults, band)
+	if !deviceInUse(fault.Device) {
+		// deleting the root qdisc removes all filters and netem qdiscs
+		return tc("qdisc", "del", "dev", fault.Device, "root")
+	}
+	var errs []error
+	if err := tc("filter", "del", "dev", fault.Device, "parent", "1:", "prio", strconv.Itoa(band)); err != nil {
+		errs = append(errs, err)
+	}
+	if err := tc("qdisc", "del", "dev", fault.Device, "parent", fmt.Sprintf("1:%d", band)); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to remove network fault on band %d: %v", band, errs)
+	}
+	return nil
+}
+
+// RemoveNetworkFault removes a network fault before it expires
+func RemoveNetworkFault(id string) error {
+	if !strings.HasPrefix(id, FaultNetwork+"-") {
+		return fmt.Errorf("%s is not a network fault", id)
+	}
+	return RevertFault(id)
+}
+
+// RemoveNetworkFaults removes all network faults
+func RemoveNetworkFaults() error {
+	var errs []error
+	for _, fault := range ListFaults() {
+		if fault.Kind != FaultNetwork {
+			continue
+		}
+		if err := RevertFault(fault.Id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to remove network faults: %v", errs)
+	}
+	return nil
+}
+
+// ListNetworkFaults returns the active network faults
+func ListNetworkFaults() []NetworkFault {
+	netemLock.Lock()
+	defer netemLock.Unlock()
+	faults := []NetworkFault{}
+	for _, fault := range netemFaults {
+		// omit a fault which is being applied
+		if fault.Id != "" {
+			faults = append(faults, fault)
+		}
+	}
+	return faults
+}
+
+// RemoveStaleNetworkFaults removes network faults left by a previous instance
+// of the agent on any device, which would otherwise never expire
+func RemoveStaleNetworkFaults() {
+	output, err := exec.Command("tc", "qdisc", "show").CombinedOutput()
+	if err != nil {
+		log.Printf("Failed to list qdiscs: %v: %s", err, output)
+		return
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		// qdisc prio 1: dev <device> root bands <bands> priomap ...
+		fields := strings.Fields(line)
+		if len(fields) < 8 || fields[1] != "prio" || fields[2] != "1:" || fields[5] != "root" || fields[7] != strconv.Itoa(netemMaxBands) {
+			continue
+		}
+		log.Printf("Removing stale network faults on %s", fields[4])
+		if err = tc("qdisc", "del", "dev", fields[4], "root"); err != nil {
+			log.Print(err)
+		}
+	}
+}
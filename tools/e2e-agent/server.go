@@ -8,22 +8,49 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
 
 	"github.com/gorilla/mux"
 )
 
+// TtlSecs of requests which apply a fault is the lease of the fault,
+// if not set the default lease is used
+
 type NodeList struct {
-	Nodes []string `json:"nodes"`
+	Nodes   []string `json:"nodes"`
+	TtlSecs int      `json:"ttlSecs"`
 }
 
 type Device struct {
-	Device string `json:"device"`
-	Table  string `json:"table"`
+	Device  string `json:"device"`
+	Table   string `json:"table"`
+	TtlSecs int    `json:"ttlSecs"`
 }
 
 type ControlledDevice struct {
-	Device string `json:"device"`
-	State  string `json:"state"`
+	Device  string `json:"device"`
+	State   string `json:"state"`
+	TtlSecs int    `json:"ttlSecs"`
+}
+
+// ServiceRequest starts, stops or restarts a service
+type ServiceRequest struct {
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	TtlSecs int    `json:"ttlSecs"`
+}
+
+// SignalRequest sends a signal to the processes with a name
+type SignalRequest struct {
+	Name    string `json:"name"`
+	Signal  string `json:"signal"`
+	TtlSecs int    `json:"ttlSecs"`
+}
+
+// SignalResponse the processes which were signalled, and the fault if they were stopped
+type SignalResponse struct {
+	Pids  []int  `json:"pids"`
+	Fault *Fault `json:"fault,omitempty"`
 }
 
 func homePage(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +70,9 @@ func main() {
 	if err := Setup(); err != nil {
 		log.Fatal(err)
 	}
+	if err := SetupAuth(); err != nil {
+		log.Fatal(err)
+	}
 	handleRequests()
 }
 
@@ -57,9 +87,19 @@ func handleRequests() {
 	router.HandleFunc("/acceptConnectionsFromNodes", acceptConnectionsFromNodes).Methods("POST")
 	router.HandleFunc("/createFaultyDevice", createFaultyDevice).Methods("POST")
 	router.HandleFunc("/exec", execCmd).Methods("POST")
+	router.HandleFunc("/v2/exec", execCmdV2).Methods("POST")
 	router.HandleFunc("/devicecontrol", controlDevice).Methods("POST")
 	router.HandleFunc("/killmayastor", killMayastor).Methods("POST")
-	log.Fatal(http.ListenAndServe(podIP+":"+restPort, router))
+	router.HandleFunc("/service", controlService).Methods("POST")
+	router.HandleFunc("/signalProcess", signalProcess).Methods("POST")
+	router.HandleFunc("/networkFault", applyNetworkFault).Methods("POST")
+	router.HandleFunc("/removeNetworkFault", removeNetworkFault).Methods("POST")
+	router.HandleFunc("/networkFaults", listNetworkFaults).Methods("GET")
+	router.HandleFunc("/faults", listFaults).Methods("GET")
+	router.HandleFunc("/faults", revertFaults).Methods("DELETE")
+	router.HandleFunc("/faults/{id}", revertFault).Methods("DELETE")
+	// requests which do not match a route are audited as well
+	log.Fatal(http.ListenAndServe(podIP+":"+restPort, authMiddleware(router)))
 }
 
 func ungracefulReboot(w http.ResponseWriter, r *http.Request) {
@@ -71,7 +111,12 @@ func ungracefulReboot(w http.ResponseWriter, r *http.Request) {
 }
 
 func gracefulReboot(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprint(w, "Graceful reboots are not yet supported")
+	if err := GracefulReboot(); err != nil {
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	fmt.Fprint(w, "Rebooting node\n")
 }
 
 func dropConnectionsFromNodes(w http.ResponseWriter, r *http.Request) {
@@ -80,12 +125,13 @@ func dropConnectionsFromNodes(w http.ResponseWriter, r *http.Request) {
 	if err := d.Decode(&list); err != nil {
 		fmt.Fprint(w, err.Error())
 	}
-	if err := DropConnectionsFromNodes(list.Nodes); err != nil {
+	faults, err := DropConnectionsFromNodes(list.Nodes, faultTtl(list.TtlSecs))
+	if err != nil {
 		w.WriteHeader(InternalServerErrorCode)
 		fmt.Fprint(w, err.Error())
 		return
 	}
-	fmt.Fprint(w, "Successfully stopped network services\n")
+	writeFaults(w, faults)
 }
 
 func acceptConnectionsFromNodes(w http.ResponseWriter, r *http.Request) {
@@ -116,6 +162,11 @@ func execCmd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	cmdArgs := strings.Split(cmdline.Cmd, " ")
+	if !IsExecAllowed(cmdArgs) {
+		w.WriteHeader(ForbiddenErrorCode)
+		fmt.Fprintf(w, "%s is not allowed", cmdArgs[0])
+		return
+	}
 	cmdName := cmdArgs[0]
 	if len(cmdArgs) > 1 {
 		cmd = exec.Command(cmdName, cmdArgs[1:]...)
@@ -132,63 +183,65 @@ func execCmd(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func createFaultyDevice(w http.ResponseWriter, r *http.Request) {
-	var (
-		device Device
-		cmd    *exec.Cmd
-	)
+func execCmdV2(w http.ResponseWriter, r *http.Request) {
+	var req ExecRequest
 	d := json.NewDecoder(r.Body)
-	if err := d.Decode(&device); err != nil {
+	if err := d.Decode(&req); err != nil {
+		w.WriteHeader(UnprocessableEntityErrorCode)
 		fmt.Fprint(w, err.Error())
+		return
 	}
-	if len(device.Device) == 0 {
+	if len(req.Argv) == 0 {
 		w.WriteHeader(UnprocessableEntityErrorCode)
-		fmt.Fprint(w, "no device passed")
+		fmt.Fprint(w, "no command passed")
 		return
 	}
-	if len(device.Table) == 0 {
-		w.WriteHeader(UnprocessableEntityErrorCode)
-		fmt.Fprint(w, "no table passed")
+	if !IsExecAllowed(req.Argv) {
+		w.WriteHeader(ForbiddenErrorCode)
+		fmt.Fprintf(w, "%s is not allowed", req.Argv[0])
 		return
 	}
-	f, err := os.Create("table")
+	result, err := ExecCommand(req)
 	if err != nil {
-		fmt.Println(err)
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
 		return
 	}
-	_, err = f.WriteString(device.Table)
-	if err != nil {
-		fmt.Println(err)
-		f.Close()
+	if err = json.NewEncoder(w).Encode(result); err != nil {
+		log.Print(err)
+	}
+}
+
+func createFaultyDevice(w http.ResponseWriter, r *http.Request) {
+	var device Device
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&device); err != nil {
+		fmt.Fprint(w, err.Error())
+	}
+	if len(device.Device) == 0 {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, "no device passed")
 		return
 	}
-	err = f.Close()
-	if err != nil {
-		fmt.Println(err)
+	if len(device.Table) == 0 {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, "no table passed")
 		return
 	}
 	devName := strings.Split(device.Device, "/")
 
-	cmdStr := "dmsetup create" + " " + devName[2] + " " + "table"
-	cmdArgs := strings.Split(cmdStr, " ")
-	cmdName := cmdArgs[0]
-	if len(cmdArgs) > 1 {
-		cmd = exec.Command(cmdName, cmdArgs[1:]...)
-	} else {
-		cmd = exec.Command(cmdName)
-	}
-	output, err := cmd.CombinedOutput()
+	fault, output, err := CreateFaultyDevice(devName[2], device.Table, faultTtl(device.TtlSecs))
 	if err != nil {
 		w.WriteHeader(InternalServerErrorCode)
 		fmt.Fprint(w, err.Error())
-	} else {
-		fmt.Fprint(w, string(output))
+		return
 	}
+	log.Printf("dmsetup create %s: %s", devName[2], output)
+	writeFaults(w, []Fault{fault})
 }
 
 func controlDevice(w http.ResponseWriter, r *http.Request) {
 	var device ControlledDevice
-	params := make([]string, 2)
 
 	d := json.NewDecoder(r.Body)
 	if err := d.Decode(&device); err != nil {
@@ -205,33 +258,160 @@ func controlDevice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cmdStr := "bash"
-	params[0] = "-c"
-	params[1] = "echo " + device.State + " > /host/sys/block/" + device.Device + "/device/state"
-
-	cmd := exec.Command(cmdStr, params...)
-	output, err := cmd.CombinedOutput()
+	fault, err := SetDeviceState(device.Device, device.State, faultTtl(device.TtlSecs))
 	if err != nil {
 		w.WriteHeader(InternalServerErrorCode)
 		fmt.Fprint(w, err.Error())
 		return
 	}
-	fmt.Fprint(w, string(output))
+	if fault != nil {
+		writeFaults(w, []Fault{*fault})
+	}
 }
 
 func killMayastor(w http.ResponseWriter, r *http.Request) {
-	params := make([]string, 2)
+	pids, _, err := SignalProcesses("mayastor", syscall.SIGKILL, 0)
+	if err != nil {
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	fmt.Fprintf(w, "Killed mayastor %v\n", pids)
+}
 
-	cmdStr := "bash"
-	params[0] = "-c"
-	params[1] = "MS=$(pidof mayastor) && kill -9 $MS"
+func controlService(w http.ResponseWriter, r *http.Request) {
+	var req ServiceRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	if !IsServiceAction(req.Action) {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, "invalid action")
+		return
+	}
+	fault, err := ControlService(req.Name, req.Action, faultTtl(req.TtlSecs))
+	if err != nil {
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	if fault != nil {
+		writeFaults(w, []Fault{*fault})
+		return
+	}
+	fmt.Fprintf(w, "Successfully ran %s on service %s\n", req.Action, req.Name)
+}
 
-	cmd := exec.Command(cmdStr, params...)
-	output, err := cmd.CombinedOutput()
+func signalProcess(w http.ResponseWriter, r *http.Request) {
+	var req SignalRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	if len(req.Name) == 0 {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, "no process name passed")
+		return
+	}
+	signal, err := ParseSignal(req.Signal)
+	if err != nil {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	pids, fault, err := SignalProcesses(req.Name, signal, faultTtl(req.TtlSecs))
+	if err != nil {
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	if err = json.NewEncoder(w).Encode(SignalResponse{Pids: pids, Fault: fault}); err != nil {
+		log.Print(err)
+	}
+}
+
+func applyNetworkFault(w http.ResponseWriter, r *http.Request) {
+	var fault NetworkFault
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&fault); err != nil {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	if err := ValidateNetworkFault(fault); err != nil {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	fault, err := ApplyNetworkFault(fault)
+	if err != nil {
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	if err = json.NewEncoder(w).Encode(fault); err != nil {
+		log.Print(err)
+	}
+}
+
+// removeNetworkFault removes the fault with the given id, or all faults if no id is passed
+func removeNetworkFault(w http.ResponseWriter, r *http.Request) {
+	var fault NetworkFault
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&fault); err != nil {
+		w.WriteHeader(UnprocessableEntityErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	var err error
+	if fault.Id == "" {
+		err = RemoveNetworkFaults()
+	} else {
+		err = RemoveNetworkFault(fault.Id)
+	}
 	if err != nil {
 		w.WriteHeader(InternalServerErrorCode)
 		fmt.Fprint(w, err.Error())
 		return
 	}
-	fmt.Fprint(w, string(output))
+	fmt.Fprint(w, "Successfully removed network faults\n")
+}
+
+func listNetworkFaults(w http.ResponseWriter, r *http.Request) {
+	if err := json.NewEncoder(w).Encode(ListNetworkFaults()); err != nil {
+		log.Print(err)
+	}
+}
+
+func writeFaults(w http.ResponseWriter, faults []Fault) {
+	if err := json.NewEncoder(w).Encode(faults); err != nil {
+		log.Print(err)
+	}
+}
+
+func listFaults(w http.ResponseWriter, r *http.Request) {
+	writeFaults(w, ListFaults())
+}
+
+func revertFaults(w http.ResponseWriter, r *http.Request) {
+	if err := RevertFaults(); err != nil {
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	fmt.Fprint(w, "Successfully reverted faults\n")
+}
+
+func revertFault(w http.ResponseWriter, r *http.Request) {
+	if err := RevertFault(mux.Vars(r)["id"]); err != nil {
+		w.WriteHeader(InternalServerErrorCode)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+	fmt.Fprint(w, "Successfully reverted fault\n")
 }
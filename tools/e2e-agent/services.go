@@ -0,0 +1,227 @@
+package main
+
+// Control of host services and processes.
+// Services are controlled with systemctl in the namespaces of the host init process,
+// processes are found by name in /proc which is the host /proc as the agent uses
+// the host PID namespace. Stopping a service or a process is a fault which is
+// reverted when its lease expires.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	FaultServiceStopped = "serviceStopped"
+	FaultProcessStopped = "processStopped"
+)
+
+const (
+	ServiceKubelet = "kubelet"
+	// ServiceContainerRuntime the first active unit of containerRuntimes
+	ServiceContainerRuntime = "container-runtime"
+)
+
+// actions on a service
+const (
+	ServiceStart   = "start"
+	ServiceStop    = "stop"
+	ServiceRestart = "restart"
+)
+
+var containerRuntimes = []string{"containerd", "crio", "docker"}
+
+// the services which can be controlled
+var controlledServices = map[string]bool{
+	ServiceKubelet: true,
+	"containerd":   true,
+	"crio":         true,
+	"docker":       true,
+}
+
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// hostCommand returns a command which runs in the namespaces of the host init process
+func hostCommand(name string, args ...string) *exec.Cmd {
+	return exec.Command("nsenter", append([]string{"--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid", "--", name}, args...)...)
+}
+
+func systemctl(args ...string) error {
+	output, err := hostCommand("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %v failed: %v: %s", args, err, output)
+	}
+	return nil
+}
+
+// resolveService returns the unit of a service
+func resolveService(name string) (string, error) {
+	if name == ServiceContainerRuntime {
+		for _, unit := range containerRuntimes {
+			if hostCommand("systemctl", "is-active", "--quiet", unit).Run() == nil {
+				return unit, nil
+			}
+		}
+		return "", fmt.Errorf("no active container runtime, tried %v", containerRuntimes)
+	}
+	if !controlledServices[name] {
+		return "", fmt.Errorf("service %s cannot be controlled", name)
+	}
+	return name, nil
+}
+
+// IsServiceAction returns true if action is an action on a service
+func IsServiceAction(action string) bool {
+	return action == ServiceStart || action == ServiceStop || action == ServiceRestart
+}
+
+// ControlService starts, stops or restarts a service. Stopping a service is a fault
+// which is reverted when ttl expires, starting or restarting the service reverts it.
+// Returns the fault if the service was stopped.
+func ControlService(name string, action string, ttl time.Duration) (*Fault, error) {
+	if !IsServiceAction(action) {
+		return nil, fmt.Errorf("invalid action %s", action)
+	}
+	unit, err := resolveService(name)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Service %s %s", unit, action)
+	args := []string{action, unit}
+	if unit != ServiceKubelet {
+		// restarting the container runtime may restart this agent,
+		// do not wait for the job so that the response can be sent
+		args = append([]string{"--no-block"}, args...)
+	}
+	if err = systemctl(args...); err != nil {
+		return nil, err
+	}
+	if action != ServiceStop {
+		for ResolveFault(FaultServiceStopped, unit) {
+		}
+		return nil, nil
+	}
+	fault := AddFault(FaultServiceStopped, unit, ttl, func() error {
+		return systemctl(ServiceStart, unit)
+	})
+	return &fault, nil
+}
+
+// ParseSignal returns the signal with a name e.g. KILL or SIGKILL, or a number
+func ParseSignal(name string) (syscall.Signal, error) {
+	if number, err := strconv.Atoi(name); err == nil && number > 0 {
+		return syscall.Signal(number), nil
+	}
+	if signal, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return signal, nil
+	}
+	return 0, fmt.Errorf("invalid signal %s", name)
+}
+
+// FindProcesses returns the pids of the processes with the name, as pidof does
+// the name is compared with the command name which is truncated to 15 characters
+func FindProcesses(name string) ([]int, error) {
+	const commLen = 15
+	if len(name) > commLen {
+		name = name[:commLen]
+	}
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// the process may have exited
+		comm, err := ioutil.ReadFile("/proc/" + entry.Name() + "/comm")
+		if err == nil && strings.TrimSpace(string(comm)) == name {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func signalPids(pids []int, signal syscall.Signal) error {
+	var errs []error
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, signal); err != nil {
+			errs = append(errs, fmt.Errorf("pid %d: %v", pid, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to send %v: %v", signal, errs)
+	}
+	return nil
+}
+
+// continuePids continues stopped processes, processes which no longer exist are ignored
+func continuePids(pids []int) error {
+	for _, pid := range pids {
+		// the process may have been killed while stopped
+		if err := syscall.Kill(pid, syscall.SIGCONT); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to continue pid %d: %v", pid, err)
+		}
+	}
+	return nil
+}
+
+// continueProcesses continues all processes with the name
+func continueProcesses(name string) error {
+	pids, err := FindProcesses(name)
+	if err != nil {
+		return err
+	}
+	return signalPids(pids, syscall.SIGCONT)
+}
+
+// SignalProcesses sends a signal to all processes with the name, returns the pids
+// of the processes. Stopping processes is a fault which is reverted when ttl expires,
+// continuing the processes reverts it. Returns the fault if the processes were stopped.
+func SignalProcesses(name string, signal syscall.Signal, ttl time.Duration) ([]int, *Fault, error) {
+	pids, err := FindProcesses(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pids) == 0 {
+		return nil, nil, fmt.Errorf("no process %s", name)
+	}
+	log.Printf("Send %v to %s %v", signal, name, pids)
+	if err = signalPids(pids, signal); err != nil {
+		if signal == syscall.SIGSTOP {
+			// processes are stopped all or none
+			if contErr := continuePids(pids); contErr != nil {
+				err = fmt.Errorf("%v, stopped processes not continued: %v", err, contErr)
+			}
+		}
+		return pids, nil, err
+	}
+	switch signal {
+	case syscall.SIGCONT:
+		for ResolveFault(FaultProcessStopped, name) {
+		}
+	case syscall.SIGSTOP:
+		fault := AddFault(FaultProcessStopped, name, ttl, func() error {
+			return continuePids(pids)
+		})
+		return pids, &fault, nil
+	}
+	return pids, nil, nil
+}